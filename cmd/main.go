@@ -25,6 +25,8 @@ func RunCLI() error {
 	app.Version = summon.FullVersionName
 	app.Writer = CLIWriter
 	app.Flags = command.Flags
+	app.Commands = command.Commands
+	app.Before = command.Before(CLIArgs)
 	app.Action = command.Action
 
 	return app.Run(CLIArgs)
@@ -3,6 +3,7 @@ package command
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -49,7 +50,26 @@ var Action = func(c *cli.Context) {
 		os.Exit(127)
 	}
 
-	provider, err := prov.Resolve(c.String("provider"))
+	var stats *summon.Stats
+	if c.Bool("stats") || c.IsSet("stats-format") || c.IsSet("stats-file") {
+		if err := checkStatsFormat(c.String("stats-format")); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(127)
+		}
+		stats = &summon.Stats{}
+	}
+
+	settings, err := loadSettings(c)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(127)
+	}
+	if err := settings.applyTimeout(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(127)
+	}
+
+	providerName, err := prov.Resolve(settings.Provider)
 	// It's okay to not throw this error here, because `Resolve()` throws an
 	// error if there are multiple unspecified providers. `all-provider-versions`
 	// doesn't care about this and just looks in the default provider dir
@@ -59,43 +79,59 @@ var Action = func(c *cli.Context) {
 	}
 
 	if c.Bool("all-provider-versions") {
-		if err := runPrintProviderVersions(); err != nil {
+		if err := runPrintProviderVersions(settings); err != nil {
 			fmt.Println(err.Error())
 			os.Exit(127)
 		}
 		return
 	}
 
+	provider, err := settings.newProviderChain(providerName)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(127)
+	}
+
 	code, err := summon.RunSubprocess(&summon.SubprocessConfig{
 		Args:        c.Args(),
-		Environment: c.String("environment"),
-		Filepath:    c.String("f"),
+		Environment: settings.Environment,
+		Filepath:    settings.File,
 		YamlInline:  c.String("yaml"),
-		Ignores:     c.StringSlice("ignore"),
-		IgnoreAll:   c.Bool("ignore-all"),
-		RecurseUp:   c.Bool("up"),
-		Subs:        c.StringSlice("D"),
+		Ignores:     settings.Ignores,
+		IgnoreAll:   settings.IgnoreAll,
+		RecurseUp:   settings.Up,
+		Subs:        settings.subs(),
+		EnvPrefix:   c.String("prefix"),
+		EnvCase:     c.String("case"),
 		Provider:    provider,
-		FetchSecret: func(secretId string) ([]byte, error) {
-			s, err := prov.Call(provider, secretId)
-			return []byte(s), err
-		},
+		NewProvider: settings.newProvider,
+		Stats:       stats,
 	})
 
+	if stats != nil {
+		if err := reportStats(stats, c.String("stats-format"), c.String("stats-file")); err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+
 	if err != nil {
 		fmt.Println(err.Error())
+		var interrupted *summon.InterruptedError
+		if errors.As(err, &interrupted) {
+			os.Exit(interrupted.ExitCode())
+		}
 		os.Exit(127)
 	}
 
 	os.Exit(code)
 }
 
-func runPrintProviderVersions() error {
+func runPrintProviderVersions(s *settings) error {
 	defaultPath, err := prov.GetDefaultPath()
 	if err != nil {
 		return err
 	}
-	output, err := printProviderVersions(defaultPath)
+	output, err := printProviderVersions(defaultPath, s)
 	if err != nil {
 		return err
 	}
@@ -104,8 +140,9 @@ func runPrintProviderVersions() error {
 	return nil
 }
 
-// printProviderVersions returns a string of all provider versions
-func printProviderVersions(providerPath string) (string, error) {
+// printProviderVersions returns a string of all provider versions. Providers
+// that don't match the checksum pinned in s aren't run.
+func printProviderVersions(providerPath string, s *settings) (string, error) {
 	var providerVersions bytes.Buffer
 
 	fmt.Fprintf(&providerVersions, "Provider versions in %s:\n", providerPath)
@@ -116,7 +153,14 @@ func printProviderVersions(providerPath string) (string, error) {
 	}
 
 	for _, provider := range providers {
-		version, err := exec.Command(filepath.Join(providerPath, provider), "--version").Output()
+		path := filepath.Join(providerPath, provider)
+		if pinned := s.providerConfig(path).SHA256; pinned != "" {
+			if sum, err := prov.Checksum(path); err != nil || !strings.EqualFold(sum, pinned) {
+				fmt.Fprintf(&providerVersions, "%s: checksum mismatch, not run\n", provider)
+				continue
+			}
+		}
+		version, err := exec.Command(path, "--version").Output()
 		if err != nil {
 			fmt.Fprintf(&providerVersions, "%s: unknown version\n", provider)
 			continue
@@ -4,8 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,17 +16,15 @@ func TestPrintProviderVersions(t *testing.T) {
 	}
 
 	t.Run("printProviderVersions should return a string of all of the providers in the defaultPath", func(t *testing.T) {
-		pathTo, err := os.Getwd()
-		assert.NoError(t, err)
-		pathToTest := filepath.Join(pathTo, "testversions")
+		pathToTest := writeTestProviders(t)
 
 		//test1 - regular formating and appending of version # to string
 		//test2 - chopping off of trailing newline
 		//test3 - failed `--version` call
-		output, err := printProviderVersions(pathToTest)
+		output, err := printProviderVersions(pathToTest, &settings{})
 		assert.NoError(t, err)
 
-		expected := `Provider versions in /summon/pkg/command/testversions:
+		expected := `Provider versions in ` + pathToTest + `:
 testprovider version 1.2.3
 testprovider-noversionsupport: unknown version
 testprovider-trailingnewline version 3.2.1
@@ -35,6 +32,16 @@ testprovider-trailingnewline version 3.2.1
 
 		assert.Equal(t, expected, output)
 	})
+
+	t.Run("printProviderVersions doesn't run providers that don't match their pinned checksum", func(t *testing.T) {
+		pathToTest := writeTestProviders(t)
+		s := &settings{Providers: map[string]providerConfig{"testprovider": {SHA256: strings.Repeat("0", 64)}}}
+
+		output, err := printProviderVersions(pathToTest, s)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "testprovider: checksum mismatch, not run\n")
+		assert.Contains(t, output, "testprovider-trailingnewline version 3.2.1\n")
+	})
 }
 
 func TestConfigureDebugLogging(t *testing.T) {
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"slices"
+
+	"github.com/urfave/cli"
+)
+
+// Commands define the commands summon provides besides running a command
+// with secrets in its environment. They are all subcommands of tool (e.g.
+// `summon tool fmt`), so that summon keeps wrapping programs of any other
+// name, e.g. `summon tool diff a.txt b.txt`. See Before for how a program named
+// tool is wrapped.
+var Commands = []cli.Command{
+	{
+		Name:  "tool",
+		Usage: "Work with secrets.yml, configuration files, stores and providers",
+		Subcommands: []cli.Command{
+			convertCommand,
+			fmtCommand,
+			initCommand,
+			diffCommand,
+			configCommand,
+			storeCommand,
+			providersCommand,
+			doctorCommand,
+		},
+	},
+}
+
+// Before returns the Before hook of the summon app run with arguments. It
+// lets programs named like one of the Commands be wrapped: the program runs
+// instead of the command when its name follows `--` (e.g. `summon -- tool
+// x`), or when the arguments after it don't start with a subcommand of the
+// command or help (e.g. `summon tool --version`).
+func Before(arguments []string) cli.BeforeFunc {
+	return func(c *cli.Context) error {
+		args := c.Args()
+		if !args.Present() {
+			return nil
+		}
+		command := c.App.Command(args.First())
+		if command == nil {
+			return nil
+		}
+
+		first := len(arguments) - len(args)
+		escaped := first > 0 && arguments[first-1] == "--"
+		if escaped || !invokesSubcommand(command, args.Tail()) {
+			c.App.Commands = slices.DeleteFunc(slices.Clone(c.App.Commands), func(other cli.Command) bool {
+				return other.Name == command.Name
+			})
+		}
+		return nil
+	}
+}
+
+// invokesSubcommand tells whether args, following the name of command, are
+// meant for command rather than for a program of the same name.
+func invokesSubcommand(command *cli.Command, args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	switch args[0] {
+	case "help", "h", "-h", "--help":
+		return true
+	}
+	return slices.ContainsFunc(command.Subcommands, func(subcommand cli.Command) bool {
+		return subcommand.HasName(args[0])
+	})
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestBefore(t *testing.T) {
+	run := func(arguments ...string) string {
+		ran := ""
+		app := cli.NewApp()
+		app.Writer = io.Discard
+		app.Flags = []cli.Flag{cli.StringFlag{Name: "p, provider"}}
+		app.Commands = []cli.Command{
+			{
+				Name: "tool",
+				Subcommands: []cli.Command{
+					{
+						Name:   "fmt",
+						Flags:  []cli.Flag{cli.BoolFlag{Name: "w, write"}},
+						Action: func(*cli.Context) { ran = "subcommand" },
+					},
+					{
+						Name:   "diff",
+						Action: func(*cli.Context) { ran = "subcommand" },
+					},
+				},
+			},
+		}
+		app.Action = func(c *cli.Context) { ran = "program " + c.Args().First() }
+		arguments = append([]string{"summon"}, arguments...)
+		app.Before = Before(arguments)
+
+		assert.NoError(t, app.Run(arguments))
+		return ran
+	}
+
+	t.Run("runs subcommands", func(t *testing.T) {
+		assert.Equal(t, "subcommand", run("tool", "fmt"))
+		assert.Equal(t, "subcommand", run("tool", "fmt", "-w"))
+		assert.Equal(t, "subcommand", run("-p", "vault", "tool", "diff", "staging", "production"))
+	})
+
+	t.Run("runs programs named like subcommands", func(t *testing.T) {
+		assert.Equal(t, "program diff", run("diff", "a.txt", "b.txt"))
+		assert.Equal(t, "program init", run("init", "--version"))
+		assert.Equal(t, "program fmt", run("-p", "vault", "fmt"))
+		assert.Equal(t, "program tool", run("tool", "--all"))
+		assert.Equal(t, "program tool", run("tool", "list"))
+		assert.Equal(t, "program tool", run("--", "tool", "fmt"))
+	})
+}
@@ -0,0 +1,600 @@
+package command
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v3"
+)
+
+// Locations of the configuration files supplying flag defaults. The system
+// file has the lowest precedence, then the user file, then the closest
+// .summonrc found in the current directory or above.
+var (
+	systemConfigPath  = "/etc/summon/config.yml"
+	projectConfigName = ".summonrc"
+)
+
+// sha256Pattern matches the checksums pinned in the providers section.
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// sourceDefault is the source of settings nothing sets.
+const sourceDefault = "default"
+
+var configCommand = cli.Command{
+	Name:  "config",
+	Usage: "Inspect the settings read from summon configuration files",
+	Subcommands: []cli.Command{
+		{
+			Name:      "show",
+			Usage:     "Print the effective settings and where each of them comes from",
+			ArgsUsage: " ",
+			Action: func(c *cli.Context) error {
+				s, err := loadSettings(globalFlags{c})
+				if err != nil {
+					return err
+				}
+				return s.print(c.App.Writer)
+			},
+		},
+	},
+}
+
+// configFile is the content of a configuration file: defaults for flags.
+type configFile struct {
+	Provider      string            `yaml:"provider"`
+	Fallback      []string          `yaml:"fallback"` // Providers tried in order for the secrets the provider fails to fetch
+	Environment   string            `yaml:"environment"`
+	File          string            `yaml:"file"`
+	Up            *bool             `yaml:"up"`
+	Ignore        []string          `yaml:"ignore"`
+	IgnoreAll     *bool             `yaml:"ignore-all"`
+	Timeout       int               `yaml:"timeout"` // Seconds to wait for providers in interactive mode
+	Substitutions map[string]string `yaml:"substitutions"`
+
+	// Providers holds settings for provider executables, keyed by name
+	// (e.g. summon-conjur) or absolute path.
+	Providers map[string]providerConfig `yaml:"providers"`
+}
+
+// providerConfig holds the settings of a provider executable.
+type providerConfig struct {
+	Protocol int               `yaml:"protocol"` // Version of the interactive protocol, see provider.ExecOptions
+	Stderr   prov.StderrPolicy `yaml:"stderr"`   // What output on stderr means, see provider.StderrPolicy
+
+	// Environment of the provider processes, see provider.EnvPolicy
+	Inherit prov.Inherit      `yaml:"inherit"`
+	Allow   []string          `yaml:"allow"`
+	Env     map[string]string `yaml:"env"`
+
+	// SHA256 pins the checksum of the provider executable, see
+	// provider.ExecOptions and `summon tool providers pin`
+	SHA256 string `yaml:"sha256"`
+}
+
+// settings are the effective values of the flags that configuration files
+// can set. Flags win over environment variables, which win over
+// configuration files.
+type settings struct {
+	Provider    string
+	Fallback    []string
+	Environment string
+	File        string
+	Up          bool
+	Ignores     []string
+	IgnoreAll   bool
+	Timeout     int
+	Subs        map[string]string
+	Providers   map[string]providerConfig
+
+	// sources maps each setting, ignore and substitution (e.g.
+	// "substitutions.ENV") to where its value comes from.
+	sources map[string]string
+}
+
+// flagValues reads the flags given on the command line, see globalFlags.
+type flagValues interface {
+	IsSet(name string) bool
+	String(name string) string
+	Bool(name string) bool
+	StringSlice(name string) []string
+}
+
+// globalFlags reads the flags given before a subcommand.
+type globalFlags struct {
+	c *cli.Context
+}
+
+func (g globalFlags) IsSet(name string) bool           { return g.c.GlobalIsSet(name) }
+func (g globalFlags) String(name string) string        { return g.c.GlobalString(name) }
+func (g globalFlags) Bool(name string) bool            { return g.c.GlobalBool(name) }
+func (g globalFlags) StringSlice(name string) []string { return g.c.GlobalStringSlice(name) }
+
+// loadSettings merges the configuration files, the SUMMON_PROVIDER and
+// CONJUR_HTTP_TIMEOUT environment variables and flags.
+func loadSettings(flags flagValues) (*settings, error) {
+	s := &settings{
+		File:      "secrets.yml",
+		Timeout:   prov.DefaultInteractiveTimeout,
+		Subs:      map[string]string{},
+		Providers: map[string]providerConfig{},
+		sources:   map[string]string{},
+	}
+	for _, name := range []string{"provider", "fallback", "environment", "file", "up", "ignore-all", "timeout"} {
+		s.sources[name] = sourceDefault
+	}
+
+	paths, err := configPaths()
+	if err != nil {
+		return nil, err
+	}
+	var project string
+	for _, path := range paths {
+		config, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			continue
+		}
+		if filepath.Base(path) == projectConfigName {
+			if err := s.checkProjectProviders(config, path); err != nil {
+				return nil, err
+			}
+			project = path
+		}
+		s.merge(config, path)
+	}
+
+	if provider := os.Getenv("SUMMON_PROVIDER"); provider != "" {
+		s.Provider, s.sources["provider"] = provider, "$SUMMON_PROVIDER"
+	}
+	if timeout, err := strconv.Atoi(os.Getenv(prov.InteractiveTimeoutEnvVar)); err == nil && timeout > 0 {
+		s.Timeout, s.sources["timeout"] = timeout, "$"+prov.InteractiveTimeoutEnvVar
+	}
+
+	if flags.IsSet("provider") {
+		s.Provider, s.sources["provider"] = flags.String("provider"), "--provider"
+	}
+	if flags.IsSet("fallback") {
+		s.Fallback, s.sources["fallback"] = flags.StringSlice("fallback"), "--fallback"
+	}
+	if flags.IsSet("environment") {
+		s.Environment, s.sources["environment"] = flags.String("environment"), "--environment"
+	}
+	if flags.IsSet("f") {
+		s.File, s.sources["file"] = flags.String("f"), "-f"
+	}
+	if flags.IsSet("up") {
+		s.Up, s.sources["up"] = flags.Bool("up"), "--up"
+	}
+	if flags.IsSet("ignore-all") {
+		s.IgnoreAll, s.sources["ignore-all"] = flags.Bool("ignore-all"), "--ignore-all"
+	}
+	for _, ignore := range flags.StringSlice("ignore") {
+		s.addIgnore(ignore, "--ignore")
+	}
+	for _, sub := range flags.StringSlice("D") {
+		name, value, ok := strings.Cut(sub, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid substitution format: %q (expected key=value)", sub)
+		}
+		s.Subs[name], s.sources["substitutions."+name] = value, "-D"
+	}
+
+	if project != "" {
+		if err := s.checkProjectSelection(project); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// merge applies the settings of a configuration file read from source.
+func (s *settings) merge(config *configFile, source string) {
+	if config.Provider != "" {
+		s.Provider, s.sources["provider"] = config.Provider, source
+	}
+	if config.Fallback != nil {
+		s.Fallback, s.sources["fallback"] = config.Fallback, source
+	}
+	if config.Environment != "" {
+		s.Environment, s.sources["environment"] = config.Environment, source
+	}
+	if config.File != "" {
+		s.File, s.sources["file"] = config.File, source
+	}
+	if config.Up != nil {
+		s.Up, s.sources["up"] = *config.Up, source
+	}
+	if config.IgnoreAll != nil {
+		s.IgnoreAll, s.sources["ignore-all"] = *config.IgnoreAll, source
+	}
+	if config.Timeout > 0 {
+		s.Timeout, s.sources["timeout"] = config.Timeout, source
+	}
+	for _, ignore := range config.Ignore {
+		s.addIgnore(ignore, source)
+	}
+	for name, value := range config.Substitutions {
+		s.Subs[name], s.sources["substitutions."+name] = value, source
+	}
+	for name, provider := range config.Providers {
+		merged := s.Providers[name]
+		if provider.Protocol != 0 {
+			merged.Protocol, s.sources["providers."+name+".protocol"] = provider.Protocol, source
+		}
+		if provider.Stderr != "" {
+			merged.Stderr, s.sources["providers."+name+".stderr"] = provider.Stderr, source
+		}
+		if provider.Inherit != "" {
+			merged.Inherit, s.sources["providers."+name+".inherit"] = provider.Inherit, source
+		}
+		if provider.Allow != nil {
+			merged.Allow, s.sources["providers."+name+".allow"] = provider.Allow, source
+		}
+		if provider.SHA256 != "" {
+			merged.SHA256, s.sources["providers."+name+".sha256"] = provider.SHA256, source
+		}
+		for variable, value := range provider.Env {
+			if merged.Env == nil {
+				merged.Env = map[string]string{}
+			}
+			merged.Env[variable], s.sources["providers."+name+".env."+variable] = value, source
+		}
+		s.Providers[name] = merged
+	}
+}
+
+// checkProjectProviders rejects the provider settings of the project
+// configuration file at path that loosen the sha256, inherit or allow settings
+// of the system or user configuration files, or that add env entries to a
+// provider they restrict: whoever can write to a project must not be able to
+// run other executables, or to pass them more of summon's environment. A
+// project entry for the path of a provider that is configured by name gets
+// the restrictions of that name, as it takes precedence over it.
+func (s *settings) checkProjectProviders(config *configFile, path string) error {
+	for name, provider := range config.Providers {
+		trustedName := name
+		trusted, ok := s.Providers[name]
+		if !ok && filepath.Base(name) != name {
+			trustedName = filepath.Base(name)
+			trusted = s.Providers[trustedName]
+			provider.SHA256 = cmp.Or(provider.SHA256, trusted.SHA256)
+			provider.Inherit = cmp.Or(provider.Inherit, trusted.Inherit)
+			if provider.Allow == nil {
+				provider.Allow = trusted.Allow
+			}
+			config.Providers[name] = provider
+		}
+		if len(provider.Env) > 0 && (trusted.SHA256 != "" || trusted.Inherit != "" || trusted.Allow != nil) {
+			restricted := cmp.Or(s.sources["providers."+trustedName+".sha256"],
+				s.sources["providers."+trustedName+".inherit"], s.sources["providers."+trustedName+".allow"])
+			return fmt.Errorf("Unable to read configuration from %s: providers.%s is restricted in %s and a project can't set its env",
+				path, name, restricted)
+		}
+		if provider.SHA256 != "" && trusted.SHA256 != "" && !strings.EqualFold(provider.SHA256, trusted.SHA256) {
+			return fmt.Errorf("Unable to read configuration from %s: providers.%s.sha256 is pinned in %s and can't be changed by a project",
+				path, name, s.sources["providers."+trustedName+".sha256"])
+		}
+		if provider.Inherit != "" && inheritRank(provider.Inherit) > inheritRank(trusted.Inherit) {
+			return fmt.Errorf("Unable to read configuration from %s: providers.%s.inherit is %s in %s and can't be loosened by a project",
+				path, name, trusted.Inherit, s.sources["providers."+trustedName+".inherit"])
+		}
+		if trusted.Allow == nil {
+			continue
+		}
+		for _, pattern := range provider.Allow {
+			if !slices.Contains(trusted.Allow, pattern) {
+				return fmt.Errorf("Unable to read configuration from %s: providers.%s.allow is set in %s and a project can only remove patterns from it",
+					path, name, s.sources["providers."+trustedName+".allow"])
+			}
+		}
+	}
+	return nil
+}
+
+// checkProjectSelection rejects the provider and fallback settings of the
+// project configuration file at path that name an executable without a
+// sha256 pin of the system or user configuration files, as a project could
+// otherwise run any executable of the provider path, or of its own tree.
+// Builtin providers and the ones a project setting doesn't select (flags
+// and $SUMMON_PROVIDER override it) are left alone.
+func (s *settings) checkProjectSelection(path string) error {
+	var names []string
+	if s.sources["provider"] == path {
+		names = append(names, s.Provider)
+	}
+	if s.sources["fallback"] == path {
+		names = append(names, s.Fallback...)
+	}
+	for _, name := range names {
+		resolved, err := prov.Resolve(name)
+		if err != nil || strings.HasPrefix(resolved, prov.BuiltinPrefix) {
+			// A provider that can't be resolved never runs.
+			continue
+		}
+		pinned := slices.ContainsFunc([]string{resolved, filepath.Base(resolved)}, func(key string) bool {
+			source, ok := s.sources["providers."+key+".sha256"]
+			return ok && source != path
+		})
+		if !pinned {
+			return fmt.Errorf("Unable to read configuration from %s: provider %q isn't pinned by the system or user configuration, so a project can't select it (pin it with summon tool providers pin)",
+				path, name)
+		}
+	}
+	return nil
+}
+
+// inheritRank orders environment policies from the most restrictive one.
+func inheritRank(inherit prov.Inherit) int {
+	switch inherit {
+	case prov.InheritNone:
+		return 0
+	case prov.InheritAllowlist:
+		return 1
+	}
+	return 2
+}
+
+// newProvider creates the provider for a name returned by provider.Resolve,
+// applying the settings of the providers section that match the name, the
+// executable's base name or its path.
+func (s *settings) newProvider(name string) (prov.Provider, error) {
+	if strings.HasPrefix(name, prov.BuiltinPrefix) {
+		return prov.New(name)
+	}
+	return prov.NewExecWithOptions(name, s.execOptions(name)), nil
+}
+
+// newProviderChain creates the provider for a name returned by
+// provider.Resolve, falling back to the providers of the fallback setting
+// for the secrets it fails to fetch (see provider.NewChain).
+func (s *settings) newProviderChain(name string) (prov.Provider, error) {
+	provider, err := s.newProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	providers := []prov.Provider{provider}
+	for _, fallback := range s.Fallback {
+		resolved, err := prov.Resolve(fallback)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to resolve fallback provider %q: %w", fallback, err)
+		}
+		provider, err := s.newProvider(resolved)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return prov.NewChain(providers...), nil
+}
+
+// execOptions returns the options of the provider executable at path.
+func (s *settings) execOptions(path string) prov.ExecOptions {
+	config := s.providerConfig(path)
+	return prov.ExecOptions{
+		Protocol: config.Protocol,
+		Stderr:   config.Stderr,
+		Env:      prov.EnvPolicy{Inherit: config.Inherit, Allow: config.Allow, Env: config.Env},
+		SHA256:   config.SHA256,
+	}
+}
+
+// providerConfig returns the settings of the provider executable at path,
+// given either by path or by base name.
+func (s *settings) providerConfig(path string) providerConfig {
+	config, ok := s.Providers[path]
+	if !ok {
+		config = s.Providers[filepath.Base(path)]
+	}
+	return config
+}
+
+// addIgnore adds an ignored key, unless it is already ignored.
+func (s *settings) addIgnore(ignore, source string) {
+	if !slices.Contains(s.Ignores, ignore) {
+		s.Ignores = append(s.Ignores, ignore)
+		s.sources["ignore."+ignore] = source
+	}
+}
+
+// subs returns the substitutions in the `name=value` form of the -D flag.
+func (s *settings) subs() []string {
+	subs := make([]string, 0, len(s.Subs))
+	for _, name := range slices.Sorted(maps.Keys(s.Subs)) {
+		subs = append(subs, name+"="+s.Subs[name])
+	}
+	return subs
+}
+
+// applyTimeout exports a timeout read from a configuration file through
+// CONJUR_HTTP_TIMEOUT, which both summon and providers read.
+func (s *settings) applyTimeout() error {
+	switch s.sources["timeout"] {
+	case sourceDefault, "$" + prov.InteractiveTimeoutEnvVar:
+		return nil
+	}
+	return os.Setenv(prov.InteractiveTimeoutEnvVar, strconv.Itoa(s.Timeout))
+}
+
+// print writes the settings as YAML, with the source of each value in a
+// comment.
+func (s *settings) print(out io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(parent *yaml.Node, key, value, tag, source string) {
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, LineComment: source})
+	}
+
+	add(root, "provider", s.Provider, "!!str", s.sources["provider"])
+	fallback := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle, LineComment: s.sources["fallback"]}
+	for _, name := range s.Fallback {
+		fallback.Content = append(fallback.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name})
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "fallback"}, fallback)
+	add(root, "environment", s.Environment, "!!str", s.sources["environment"])
+	add(root, "file", s.File, "!!str", s.sources["file"])
+	add(root, "up", strconv.FormatBool(s.Up), "!!bool", s.sources["up"])
+	add(root, "ignore-all", strconv.FormatBool(s.IgnoreAll), "!!bool", s.sources["ignore-all"])
+	add(root, "timeout", strconv.Itoa(s.Timeout), "!!int", s.sources["timeout"])
+
+	ignores := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, ignore := range s.Ignores {
+		ignores.Content = append(ignores.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ignore, LineComment: s.sources["ignore."+ignore]})
+	}
+	if len(ignores.Content) == 0 {
+		ignores.Style = yaml.FlowStyle
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "ignore"}, ignores)
+
+	subs := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range slices.Sorted(maps.Keys(s.Subs)) {
+		add(subs, name, s.Subs[name], "!!str", s.sources["substitutions."+name])
+	}
+	if len(subs.Content) == 0 {
+		subs.Style = yaml.FlowStyle
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "substitutions"}, subs)
+
+	providers := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range slices.Sorted(maps.Keys(s.Providers)) {
+		provider := &yaml.Node{Kind: yaml.MappingNode}
+		if protocol := s.Providers[name].Protocol; protocol != 0 {
+			add(provider, "protocol", strconv.Itoa(protocol), "!!int", s.sources["providers."+name+".protocol"])
+		}
+		if stderr := s.Providers[name].Stderr; stderr != "" {
+			add(provider, "stderr", string(stderr), "!!str", s.sources["providers."+name+".stderr"])
+		}
+		if inherit := s.Providers[name].Inherit; inherit != "" {
+			add(provider, "inherit", string(inherit), "!!str", s.sources["providers."+name+".inherit"])
+		}
+		if allow := s.Providers[name].Allow; allow != nil {
+			allowed := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle, LineComment: s.sources["providers."+name+".allow"]}
+			for _, pattern := range allow {
+				allowed.Content = append(allowed.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: pattern})
+			}
+			provider.Content = append(provider.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "allow"}, allowed)
+		}
+		if env := s.Providers[name].Env; len(env) > 0 {
+			variables := &yaml.Node{Kind: yaml.MappingNode}
+			for _, variable := range slices.Sorted(maps.Keys(env)) {
+				add(variables, variable, env[variable], "!!str", s.sources["providers."+name+".env."+variable])
+			}
+			provider.Content = append(provider.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "env"}, variables)
+		}
+		if sum := s.Providers[name].SHA256; sum != "" {
+			add(provider, "sha256", sum, "!!str", s.sources["providers."+name+".sha256"])
+		}
+		providers.Content = append(providers.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, provider)
+	}
+	if len(providers.Content) == 0 {
+		providers.Style = yaml.FlowStyle
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "providers"}, providers)
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// configPaths returns the configuration files to read, from lowest to
+// highest precedence. They may not exist.
+func configPaths() ([]string, error) {
+	paths := []string{systemConfigPath}
+	if userConfig := userConfigPath(); userConfig != "" {
+		paths = append(paths, userConfig)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return append(paths, path), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return paths, nil
+		}
+		dir = parent
+	}
+}
+
+// userConfigPath returns the path of the user's configuration file, or ""
+// when the user has no home directory.
+func userConfigPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "summon", "config.yml")
+}
+
+// readConfigFile reads the configuration file at path, returning nil when
+// it doesn't exist. Unknown settings are errors, to catch typos.
+func readConfigFile(path string) (*configFile, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var config configFile
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Unable to read configuration from %s: %w", path, err)
+	}
+	for name, provider := range config.Providers {
+		if provider.Protocol != 0 && provider.Protocol != 1 && provider.Protocol != 2 {
+			return nil, fmt.Errorf("Unable to read configuration from %s: providers.%s.protocol must be 1 or 2", path, name)
+		}
+		switch provider.Stderr {
+		case "", prov.StderrFallback, prov.StderrLog, prov.StderrFail:
+		default:
+			return nil, fmt.Errorf("Unable to read configuration from %s: providers.%s.stderr must be %s, %s or %s",
+				path, name, prov.StderrFallback, prov.StderrLog, prov.StderrFail)
+		}
+		switch provider.Inherit {
+		case "", prov.InheritAll, prov.InheritNone, prov.InheritAllowlist:
+		default:
+			return nil, fmt.Errorf("Unable to read configuration from %s: providers.%s.inherit must be %s, %s or %s",
+				path, name, prov.InheritAll, prov.InheritNone, prov.InheritAllowlist)
+		}
+		if provider.SHA256 != "" && !sha256Pattern.MatchString(provider.SHA256) {
+			return nil, fmt.Errorf("Unable to read configuration from %s: providers.%s.sha256 must be 64 hexadecimal digits", path, name)
+		}
+		for _, pattern := range provider.Allow {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Unable to read configuration from %s: providers.%s.allow: invalid pattern %q", path, name, pattern)
+			}
+		}
+	}
+	return &config, nil
+}
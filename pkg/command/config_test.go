@@ -0,0 +1,362 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFlags are flags given on the command line, keyed by name.
+type fakeFlags map[string]interface{}
+
+func (f fakeFlags) IsSet(name string) bool { _, ok := f[name]; return ok }
+func (f fakeFlags) String(name string) string {
+	s, _ := f[name].(string)
+	return s
+}
+func (f fakeFlags) Bool(name string) bool {
+	b, _ := f[name].(bool)
+	return b
+}
+func (f fakeFlags) StringSlice(name string) []string {
+	s, _ := f[name].([]string)
+	return s
+}
+
+// setupConfigFiles writes the system, user and project configuration files
+// that aren't empty and moves to a subdirectory of the project.
+func setupConfigFiles(t *testing.T, system, user, project string) (string, string, string) {
+	dir := t.TempDir()
+	systemPath := filepath.Join(dir, "etc", "config.yml")
+	userPath := filepath.Join(dir, "home", "summon", "config.yml")
+	projectPath := filepath.Join(dir, "project", projectConfigName)
+	for path, content := range map[string]string{systemPath: system, userPath: user, projectPath: project} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		if content != "" {
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		}
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "project", "sub"), 0o755))
+
+	previous := systemConfigPath
+	systemConfigPath = systemPath
+	t.Cleanup(func() { systemConfigPath = previous })
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "home"))
+	t.Setenv("SUMMON_PROVIDER", "")
+	t.Setenv("CONJUR_HTTP_TIMEOUT", "")
+	t.Chdir(filepath.Join(dir, "project", "sub"))
+	return systemPath, userPath, projectPath
+}
+
+func TestLoadSettings(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		setupConfigFiles(t, "", "", "")
+
+		s, err := loadSettings(fakeFlags{})
+		require.NoError(t, err)
+		assert.Equal(t, "secrets.yml", s.File)
+		assert.Equal(t, 60, s.Timeout)
+		assert.Equal(t, sourceDefault, s.sources["provider"])
+		assert.Empty(t, s.subs())
+	})
+
+	t.Run("layers configuration files, environment and flags", func(t *testing.T) {
+		system, user, project := setupConfigFiles(t,
+			"provider: system-provider\ntimeout: 10\nignore: [A]\nsubstitutions: {REGION: us-east-1, ENV: dev}\n",
+			"provider: user-provider\nenvironment: staging\nignore: [B]\n",
+			"environment: prod\nfile: config/secrets.yml\nup: true\nsubstitutions: {ENV: prod}\n")
+		t.Setenv("CONJUR_HTTP_TIMEOUT", "30")
+
+		s, err := loadSettings(fakeFlags{"environment": "qa", "ignore": []string{"A", "C"}, "D": []string{"REGION=eu-west-1"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, "user-provider", s.Provider)
+		assert.Equal(t, user, s.sources["provider"])
+		assert.Equal(t, "qa", s.Environment)
+		assert.Equal(t, "--environment", s.sources["environment"])
+		assert.Equal(t, "config/secrets.yml", s.File)
+		assert.True(t, s.Up)
+		assert.Equal(t, project, s.sources["up"])
+		assert.Equal(t, 30, s.Timeout)
+		assert.Equal(t, "$CONJUR_HTTP_TIMEOUT", s.sources["timeout"])
+		assert.Equal(t, []string{"A", "B", "C"}, s.Ignores)
+		assert.Equal(t, system, s.sources["ignore.A"])
+		assert.Equal(t, []string{"ENV=prod", "REGION=eu-west-1"}, s.subs())
+		assert.Equal(t, "-D", s.sources["substitutions.REGION"])
+	})
+
+	t.Run("SUMMON_PROVIDER overrides configuration files", func(t *testing.T) {
+		setupConfigFiles(t, "provider: system-provider\n", "", "")
+		t.Setenv("SUMMON_PROVIDER", "env-provider")
+
+		s, err := loadSettings(fakeFlags{})
+		require.NoError(t, err)
+		assert.Equal(t, "env-provider", s.Provider)
+
+		s, err = loadSettings(fakeFlags{"provider": "flag-provider"})
+		require.NoError(t, err)
+		assert.Equal(t, "flag-provider", s.Provider)
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		_, _, project := setupConfigFiles(t, "", "", "enviroment: prod\n")
+
+		_, err := loadSettings(fakeFlags{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Unable to read configuration from "+project)
+		assert.Contains(t, err.Error(), "field enviroment not found")
+	})
+
+	t.Run("rejects invalid substitutions", func(t *testing.T) {
+		setupConfigFiles(t, "", "", "")
+
+		_, err := loadSettings(fakeFlags{"D": []string{"REGION"}})
+		assert.EqualError(t, err, `invalid substitution format: "REGION" (expected key=value)`)
+	})
+}
+
+func TestSettingsApplyTimeout(t *testing.T) {
+	_, _, project := setupConfigFiles(t, "", "", "timeout: 120\n")
+
+	s, err := loadSettings(fakeFlags{})
+	require.NoError(t, err)
+	assert.Equal(t, project, s.sources["timeout"])
+	require.NoError(t, s.applyTimeout())
+	assert.Equal(t, "120", os.Getenv("CONJUR_HTTP_TIMEOUT"))
+}
+
+func TestSettingsPrint(t *testing.T) {
+	_, _, project := setupConfigFiles(t, "", "", "environment: prod\nignore: [A]\n")
+
+	s, err := loadSettings(fakeFlags{"D": []string{"ENV=prod"}})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, s.print(&out))
+	assert.Equal(t, `provider: "" # default
+fallback: [] # default
+environment: prod # `+project+`
+file: secrets.yml # default
+up: false # default
+ignore-all: false # default
+timeout: 60 # default
+ignore:
+  - A # `+project+`
+substitutions:
+  ENV: prod # -D
+providers: {}
+`, out.String())
+}
+
+func TestSettingsFallback(t *testing.T) {
+	sum := strings.Repeat("a", 64)
+	_, _, project := setupConfigFiles(t, "",
+		"providers:\n  summon-vault: {sha256: "+sum+"}\n  summon-vault-legacy: {sha256: "+sum+"}\n",
+		"provider: summon-vault\nfallback: [summon-vault-legacy]\n")
+	providerDir := t.TempDir()
+	for _, name := range []string{"summon-vault", "summon-vault-legacy", "summon-conjur"} {
+		require.NoError(t, os.WriteFile(filepath.Join(providerDir, name), []byte("#!/bin/sh\n"), 0o755))
+	}
+	t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+
+	s, err := loadSettings(fakeFlags{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"summon-vault-legacy"}, s.Fallback)
+	assert.Equal(t, project, s.sources["fallback"])
+
+	t.Run("chains the fallback providers", func(t *testing.T) {
+		vault := filepath.Join(providerDir, "summon-vault")
+		provider, err := s.newProviderChain(vault)
+		require.NoError(t, err)
+		assert.Equal(t, vault+", "+filepath.Join(providerDir, "summon-vault-legacy"), provider.Name())
+	})
+
+	t.Run("flags override configuration files", func(t *testing.T) {
+		s, err := loadSettings(fakeFlags{"fallback": []string{"summon-conjur", "summon-vault-legacy"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"summon-conjur", "summon-vault-legacy"}, s.Fallback)
+		assert.Equal(t, "--fallback", s.sources["fallback"])
+
+		var out bytes.Buffer
+		require.NoError(t, s.print(&out))
+		assert.Contains(t, out.String(), "fallback: [summon-conjur, summon-vault-legacy] # --fallback\n")
+	})
+
+	t.Run("rejects unknown fallback providers", func(t *testing.T) {
+		s := &settings{Fallback: []string{"summon-missing"}}
+		_, err := s.newProviderChain(filepath.Join(providerDir, "summon-vault"))
+		assert.ErrorContains(t, err, `Unable to resolve fallback provider "summon-missing": `)
+	})
+}
+
+func TestSettingsProviders(t *testing.T) {
+	system, _, project := setupConfigFiles(t,
+		"providers:\n  summon-conjur: {protocol: 2, env: {CONJUR_ACCOUNT: acme, CONJUR_APPLIANCE_URL: https://conjur}}\n  /opt/provider: {protocol: 2}\n",
+		"",
+		"providers:\n  /opt/provider: {protocol: 1, stderr: log, sha256: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa}\n  summon-conjur: {inherit: allowlist, allow: [PATH, CONJUR_*], env: {CONJUR_ACCOUNT: dev}}\n")
+	sum := strings.Repeat("a", 64)
+	conjur := providerConfig{
+		Protocol: 2,
+		Inherit:  prov.InheritAllowlist,
+		Allow:    []string{"PATH", "CONJUR_*"},
+		Env:      map[string]string{"CONJUR_ACCOUNT": "dev", "CONJUR_APPLIANCE_URL": "https://conjur"},
+	}
+
+	s, err := loadSettings(fakeFlags{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]providerConfig{"summon-conjur": conjur, "/opt/provider": {Protocol: 1, Stderr: prov.StderrLog, SHA256: sum}}, s.Providers)
+	assert.Equal(t, system, s.sources["providers.summon-conjur.protocol"])
+	assert.Equal(t, project, s.sources["providers./opt/provider.protocol"])
+	assert.Equal(t, project, s.sources["providers./opt/provider.stderr"])
+	assert.Equal(t, project, s.sources["providers.summon-conjur.env.CONJUR_ACCOUNT"])
+	assert.Equal(t, system, s.sources["providers.summon-conjur.env.CONJUR_APPLIANCE_URL"])
+
+	t.Run("configures providers by name or path", func(t *testing.T) {
+		for name, opts := range map[string]prov.ExecOptions{
+			"/usr/local/lib/summon/summon-conjur": {Protocol: 2, Env: prov.EnvPolicy{Inherit: conjur.Inherit, Allow: conjur.Allow, Env: conjur.Env}},
+			"/opt/provider":                       {Protocol: 1, Stderr: prov.StderrLog, SHA256: sum},
+			"/opt/other":                          {},
+		} {
+			provider, err := s.newProvider(name)
+			require.NoError(t, err)
+			assert.Equal(t, prov.NewExecWithOptions(name, opts), provider, name)
+		}
+	})
+
+	t.Run("prints provider settings", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, s.print(&out))
+		assert.Contains(t, out.String(), `providers:
+  /opt/provider:
+    protocol: 1 # `+project+`
+    stderr: log # `+project+`
+    sha256: `+sum+` # `+project+`
+  summon-conjur:
+    protocol: 2 # `+system+`
+    inherit: allowlist # `+project+`
+    allow: [PATH, CONJUR_*] # `+project+`
+    env:
+      CONJUR_ACCOUNT: dev # `+project+`
+      CONJUR_APPLIANCE_URL: https://conjur # `+system+`
+`)
+	})
+
+	t.Run("rejects unknown protocols", func(t *testing.T) {
+		_, _, project := setupConfigFiles(t, "", "", "providers:\n  summon-conjur: {protocol: 3}\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.protocol must be 1 or 2")
+	})
+
+	t.Run("rejects unknown stderr policies", func(t *testing.T) {
+		_, _, project := setupConfigFiles(t, "", "", "providers:\n  summon-conjur: {stderr: ignore}\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.stderr must be fallback, log or fail")
+	})
+
+	t.Run("rejects invalid environment policies", func(t *testing.T) {
+		_, _, project := setupConfigFiles(t, "", "", "providers:\n  summon-conjur: {inherit: some}\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.inherit must be all, none or allowlist")
+
+		_, _, project = setupConfigFiles(t, "", "", "providers:\n  summon-conjur: {inherit: allowlist, allow: [\"CONJUR_[\"]}\n")
+
+		_, err = loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+`: providers.summon-conjur.allow: invalid pattern "CONJUR_["`)
+	})
+
+	t.Run("doesn't let projects loosen trusted settings", func(t *testing.T) {
+		other := strings.Repeat("b", 64)
+		system, user, project := setupConfigFiles(t,
+			"providers:\n  summon-conjur: {sha256: "+sum+"}\n",
+			"providers:\n  summon-conjur: {inherit: allowlist, allow: [PATH, CONJUR_*]}\n",
+			"providers:\n  summon-conjur: {sha256: "+other+"}\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.sha256 is pinned in "+system+" and can't be changed by a project")
+
+		require.NoError(t, os.WriteFile(project, []byte("providers:\n  summon-conjur: {inherit: all}\n"), 0o600))
+		_, err = loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.inherit is allowlist in "+user+" and can't be loosened by a project")
+
+		require.NoError(t, os.WriteFile(project, []byte("providers:\n  summon-conjur: {allow: [PATH, HOME]}\n"), 0o600))
+		_, err = loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.allow is set in "+user+" and a project can only remove patterns from it")
+
+		require.NoError(t, os.WriteFile(project, []byte("providers:\n  summon-conjur: {sha256: "+strings.ToUpper(sum)+", inherit: none, allow: [PATH]}\n"), 0o600))
+		s, err := loadSettings(fakeFlags{})
+		require.NoError(t, err)
+		assert.Equal(t, providerConfig{SHA256: strings.ToUpper(sum), Inherit: prov.InheritNone, Allow: []string{"PATH"}}, s.Providers["summon-conjur"])
+
+		require.NoError(t, os.WriteFile(project, []byte("providers:\n  /opt/summon-conjur: {protocol: 2}\n"), 0o600))
+		s, err = loadSettings(fakeFlags{})
+		require.NoError(t, err)
+		assert.Equal(t, prov.ExecOptions{Protocol: 2, SHA256: sum, Env: prov.EnvPolicy{Inherit: prov.InheritAllowlist, Allow: []string{"PATH", "CONJUR_*"}}},
+			s.execOptions("/opt/summon-conjur"), "a path entry keeps the restrictions of the name")
+	})
+
+	t.Run("doesn't let projects set the env of restricted providers", func(t *testing.T) {
+		_, user, project := setupConfigFiles(t, "",
+			"providers:\n  summon-conjur: {inherit: none}\n",
+			"providers:\n  summon-conjur: {env: {LD_PRELOAD: ./evil.so}}\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur is restricted in "+user+" and a project can't set its env")
+
+		require.NoError(t, os.WriteFile(project, []byte("providers:\n  /opt/summon-conjur: {env: {PATH: .}}\n"), 0o600))
+		_, err = loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers./opt/summon-conjur is restricted in "+user+" and a project can't set its env")
+
+		require.NoError(t, os.WriteFile(project, []byte("providers:\n  summon-aws: {env: {AWS_REGION: eu-west-1}}\n"), 0o600))
+		s, err := loadSettings(fakeFlags{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"AWS_REGION": "eu-west-1"}, s.Providers["summon-aws"].Env)
+	})
+
+	t.Run("doesn't let projects select unpinned providers", func(t *testing.T) {
+		providerDir := t.TempDir()
+		for _, name := range []string{"summon-conjur", "summon-vault"} {
+			require.NoError(t, os.WriteFile(filepath.Join(providerDir, name), []byte("#!/bin/sh\n"), 0o755))
+		}
+		t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+		_, _, project := setupConfigFiles(t, "",
+			"providers:\n  summon-conjur: {sha256: "+sum+"}\n",
+			"provider: summon-vault\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+`: provider "summon-vault" isn't pinned by the system or user configuration, so a project can't select it (pin it with summon tool providers pin)`)
+
+		require.NoError(t, os.WriteFile(project, []byte("provider: summon-conjur\nfallback: [summon-vault]\n"), 0o600))
+		_, err = loadSettings(fakeFlags{})
+		assert.ErrorContains(t, err, `provider "summon-vault" isn't pinned`)
+
+		require.NoError(t, os.WriteFile(project, []byte("provider: summon-vault\nproviders:\n  summon-vault: {sha256: "+sum+"}\n"), 0o600))
+		_, err = loadSettings(fakeFlags{})
+		assert.ErrorContains(t, err, `provider "summon-vault" isn't pinned`, "a project can't pin what it selects")
+
+		require.NoError(t, os.WriteFile(project, []byte("provider: summon-conjur\nfallback: [mock:fixtures.yml]\n"), 0o600))
+		s, err := loadSettings(fakeFlags{})
+		require.NoError(t, err)
+		assert.Equal(t, "summon-conjur", s.Provider)
+
+		require.NoError(t, os.WriteFile(project, []byte("provider: summon-vault\n"), 0o600))
+		s, err = loadSettings(fakeFlags{"provider": "summon-vault"})
+		require.NoError(t, err, "the flag overrides the project's choice")
+		assert.Equal(t, "--provider", s.sources["provider"])
+	})
+
+	t.Run("rejects invalid checksums", func(t *testing.T) {
+		_, _, project := setupConfigFiles(t, "", "", "providers:\n  summon-conjur: {sha256: abc}\n")
+
+		_, err := loadSettings(fakeFlags{})
+		assert.EqualError(t, err, "Unable to read configuration from "+project+": providers.summon-conjur.sha256 must be 64 hexadecimal digits")
+	})
+}
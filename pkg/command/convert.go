@@ -0,0 +1,55 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/urfave/cli"
+)
+
+var convertCommand = cli.Command{
+	Name:      "convert",
+	Usage:     "Rewrite a secrets.yml file in the structured (summon.version: 2) format",
+	ArgsUsage: " ",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "f",
+			Value: "secrets.yml",
+			Usage: "Path to secrets.yml",
+		},
+		cli.BoolFlag{
+			Name:  "w, write",
+			Usage: "Write the result back to the file instead of printing it",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		return runConvert(c.String("f"), c.Bool("write"), c.App.Writer)
+	},
+}
+
+// runConvert converts the secrets.yml file at path to the version 2 format,
+// either printing the result to out or writing it back to the file.
+func runConvert(path string, write bool, out io.Writer) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	converted, err := secretsyml.ConvertToV2(content)
+	if err != nil {
+		return fmt.Errorf("Unable to convert %s: %w", path, err)
+	}
+
+	if !write {
+		_, err = out.Write(converted)
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, converted, info.Mode().Perm())
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunConvert(t *testing.T) {
+	input := "KEY: !var:file path/to/key\n"
+	expected := "summon.version: 2\nKEY: {path: path/to/key, file: true}\n"
+
+	t.Run("prints the converted file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secrets.yml")
+		assert.NoError(t, os.WriteFile(path, []byte(input), 0o640))
+
+		var out bytes.Buffer
+		assert.NoError(t, runConvert(path, false, &out))
+		assert.Equal(t, expected, out.String())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, input, string(content))
+	})
+
+	t.Run("writes the converted file in place", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secrets.yml")
+		assert.NoError(t, os.WriteFile(path, []byte(input), 0o640))
+
+		var out bytes.Buffer
+		assert.NoError(t, runConvert(path, true, &out))
+		assert.Empty(t, out.String())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("reports files that can't be read", func(t *testing.T) {
+		err := runConvert(filepath.Join(t.TempDir(), "missing.yml"), false, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "no such file or directory")
+	})
+}
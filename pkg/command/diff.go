@@ -0,0 +1,198 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/urfave/cli"
+)
+
+var diffCommand = cli.Command{
+	Name:      "diff",
+	Usage:     "Compare the keys, tags and paths of two secrets configurations without fetching them",
+	ArgsUsage: "[FROM_ENVIRONMENT TO_ENVIRONMENT]",
+	Description: `Compares two environments of a secrets.yml file, two files, or a file at
+   two git revisions, e.g.:
+
+     summon tool diff staging production
+     summon tool diff --from-rev main
+     summon tool diff --from-file old.yml --to-file new.yml`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "f",
+			Value: "secrets.yml",
+			Usage: "Path to secrets.yml, for the sides without --from-file/--to-file",
+		},
+		cli.StringFlag{Name: "from-env", Usage: "Environment of the old side"},
+		cli.StringFlag{Name: "to-env", Usage: "Environment of the new side"},
+		cli.StringFlag{Name: "from-file", Usage: "Configuration file of the old side"},
+		cli.StringFlag{Name: "to-file", Usage: "Configuration file of the new side"},
+		cli.StringFlag{Name: "from-rev", Usage: "Git revision to read the old side at"},
+		cli.StringFlag{Name: "to-rev", Usage: "Git revision to read the new side at, instead of the working tree"},
+		cli.BoolFlag{Name: "json", Usage: "Print the differences as JSON"},
+		cli.BoolFlag{Name: "exit-code", Usage: "Exit with status 1 when there are differences"},
+	},
+	Action: func(c *cli.Context) error {
+		from := diffSide{file: c.String("f"), rev: c.String("from-rev"), env: c.String("from-env")}
+		to := diffSide{file: c.String("f"), rev: c.String("to-rev"), env: c.String("to-env")}
+		if c.IsSet("from-file") {
+			from.file = c.String("from-file")
+		}
+		if c.IsSet("to-file") {
+			to.file = c.String("to-file")
+		}
+
+		switch c.NArg() {
+		case 0:
+		case 2:
+			from.env, to.env = c.Args().Get(0), c.Args().Get(1)
+		default:
+			return cli.NewExitError("summon tool diff takes either no environments or two of them", 1)
+		}
+
+		return runDiff(from, to, diffOptions{json: c.Bool("json"), exitCode: c.Bool("exit-code")}, c.App.Writer)
+	},
+}
+
+// diffOptions holds the output flags of the diff command.
+type diffOptions struct {
+	json     bool
+	exitCode bool
+}
+
+// diffSide locates one of the configurations compared by the diff command.
+type diffSide struct {
+	file string // Configuration file
+	rev  string // Git revision to read file at; the working tree when empty
+	env  string // Environment section to compare
+}
+
+func (s diffSide) String() string {
+	name := s.file
+	if s.rev != "" {
+		name = s.rev + ":" + name
+	}
+	if s.env != "" {
+		name += " (" + s.env + ")"
+	}
+	return name
+}
+
+// runDiff prints the differences between the configurations at from and to.
+func runDiff(from, to diffSide, opts diffOptions, out io.Writer) error {
+	fromConfig, err := from.parse()
+	if err != nil {
+		return err
+	}
+	toConfig, err := to.parse()
+	if err != nil {
+		return err
+	}
+
+	diff := secretsyml.Diff(fromConfig, toConfig)
+	if opts.json {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else {
+		err = printDiff(out, from, to, diff)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.exitCode && !diff.Empty() {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+// parse reads and parses the configuration, leaving $-substitutions as they
+// are written.
+func (s diffSide) parse() (*secretsyml.ParsedConfig, error) {
+	var config *secretsyml.ParsedConfig
+	var err error
+	if s.rev == "" {
+		config, err = secretsyml.ParseFromFile(s.file, s.env, nil)
+	} else {
+		var content []byte
+		content, err = gitShow(s.rev, s.file)
+		if err == nil {
+			config, err = secretsyml.ParseFromString(string(content), s.env, nil)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %w", s, err)
+	}
+	return config, nil
+}
+
+// gitShow returns the content of file at a git revision.
+func gitShow(rev, file string) ([]byte, error) {
+	if filepath.IsAbs(file) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if file, err = filepath.Rel(cwd, file); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := exec.Command("git", "show", rev+":./"+filepath.ToSlash(file)).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("git show: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return content, err
+}
+
+// printDiff prints diff in a unified-diff-like layout: `+` for additions,
+// `-` for removals and `~` for changes.
+func printDiff(out io.Writer, from, to diffSide, diff secretsyml.ConfigDiff) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+	if diff.Empty() {
+		b.WriteString("No differences\n")
+	}
+
+	writeSecretChanges(&b, "", diff.Secrets)
+	for _, file := range diff.Files {
+		switch file.Change {
+		case secretsyml.ChangeAdded:
+			fmt.Fprintf(&b, "+ summon.files %s\n", file.Path)
+		case secretsyml.ChangeRemoved:
+			fmt.Fprintf(&b, "- summon.files %s\n", file.Path)
+		default:
+			fmt.Fprintf(&b, "~ summon.files %s\n", file.Path)
+			for _, field := range file.Fields {
+				fmt.Fprintf(&b, "    ~ %s: %q -> %q\n", field.Name, field.From, field.To)
+			}
+			writeSecretChanges(&b, "    ", file.Secrets)
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// writeSecretChanges writes one line per secret change.
+func writeSecretChanges(b *strings.Builder, indent string, changes []secretsyml.SecretChange) {
+	for _, change := range changes {
+		switch change.Change {
+		case secretsyml.ChangeAdded:
+			fmt.Fprintf(b, "%s+ %s: %s\n", indent, change.Key, change.To)
+		case secretsyml.ChangeRemoved:
+			fmt.Fprintf(b, "%s- %s: %s\n", indent, change.Key, change.From)
+		default:
+			fmt.Fprintf(b, "%s~ %s: %s -> %s\n", indent, change.Key, change.From, change.To)
+		}
+	}
+}
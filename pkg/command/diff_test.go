@@ -0,0 +1,94 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`staging:
+  DB_PASSWORD: !var staging/db/password
+  DEBUG: "true"
+production:
+  DB_PASSWORD: !var production/db/password
+  SSL_CERT: !var:file production/ssl/cert
+`), 0o600))
+
+	staging := diffSide{file: path, env: "staging"}
+	production := diffSide{file: path, env: "production"}
+
+	t.Run("prints a text diff", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runDiff(staging, production, diffOptions{}, &out))
+		assert.Equal(t, "--- "+path+" (staging)\n+++ "+path+" (production)\n"+
+			"~ DB_PASSWORD: !var staging/db/password -> !var production/db/password\n"+
+			"- DEBUG: true\n"+
+			"+ SSL_CERT: !var:file production/ssl/cert\n", out.String())
+	})
+
+	t.Run("prints a JSON diff", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runDiff(staging, production, diffOptions{json: true}, &out))
+
+		var diff secretsyml.ConfigDiff
+		require.NoError(t, json.Unmarshal(out.Bytes(), &diff))
+		assert.Len(t, diff.Secrets, 3)
+		assert.Equal(t, "SSL_CERT", diff.Secrets[2].Key)
+		assert.Equal(t, secretsyml.ChangeAdded, diff.Secrets[2].Change)
+		assert.Empty(t, diff.Files)
+	})
+
+	t.Run("exits with status 1 on differences when asked", func(t *testing.T) {
+		err := runDiff(staging, production, diffOptions{exitCode: true}, &bytes.Buffer{})
+		if exitErr, ok := err.(cli.ExitCoder); assert.True(t, ok) {
+			assert.Equal(t, 1, exitErr.ExitCode())
+		}
+
+		var out bytes.Buffer
+		assert.NoError(t, runDiff(staging, staging, diffOptions{exitCode: true}, &out))
+		assert.Contains(t, out.String(), "No differences\n")
+	})
+
+	t.Run("reports parse errors", func(t *testing.T) {
+		err := runDiff(staging, diffSide{file: path, env: "qa"}, diffOptions{}, &bytes.Buffer{})
+		assert.EqualError(t, err, "Unable to parse "+path+" (qa): No such environment 'qa' found in secrets file")
+	})
+}
+
+func TestRunDiff_GitRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	git("init", "-q")
+	require.NoError(t, os.WriteFile("secrets.yml", []byte("A: !var old/a\n"), 0o600))
+	git("add", "secrets.yml")
+	git("commit", "-q", "-m", "initial")
+	require.NoError(t, os.WriteFile("secrets.yml", []byte("A: !var new/a\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, runDiff(diffSide{file: "secrets.yml", rev: "HEAD"}, diffSide{file: "secrets.yml"}, diffOptions{}, &out))
+	assert.Equal(t, "--- HEAD:secrets.yml\n+++ secrets.yml\n~ A: !var old/a -> !var new/a\n", out.String())
+
+	err := runDiff(diffSide{file: "secrets.yml", rev: "nope"}, diffSide{file: "secrets.yml"}, diffOptions{}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "Unable to parse nope:secrets.yml: git show:")
+}
@@ -0,0 +1,271 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/cyberark/summon/pkg/summon"
+	"github.com/urfave/cli"
+)
+
+var doctorCommand = cli.Command{
+	Name:      "doctor",
+	Usage:     "Check the provider, secrets.yml and temporary files setup, suggesting fixes",
+	ArgsUsage: " ",
+	Description: "Takes the flags summon would be run with, e.g. `summon -p summon-conjur --up doctor`.\n" +
+		"   Exits with status 1 when a check fails.",
+	Action: func(c *cli.Context) error {
+		return runDoctor(globalFlags{c}, c.App.Writer)
+	},
+}
+
+// Outcomes of the checks of summon tool doctor.
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// checkResult is the outcome of a check of summon tool doctor, with a suggested
+// fix unless it passed.
+type checkResult struct {
+	status  string
+	name    string
+	message string
+	fix     string
+}
+
+func pass(name, message string) checkResult {
+	return checkResult{status: checkPass, name: name, message: message}
+}
+
+func warn(name, message, fix string) checkResult {
+	return checkResult{status: checkWarn, name: name, message: message, fix: fix}
+}
+
+func fail(name, message, fix string) checkResult {
+	return checkResult{status: checkFail, name: name, message: message, fix: fix}
+}
+
+// runDoctor checks what summon run with flags needs, and prints a report.
+func runDoctor(flags flagValues, out io.Writer) error {
+	var results []checkResult
+	s, err := loadSettings(flags)
+	if err != nil {
+		results = append(results, fail("configuration", err.Error(), "fix the configuration file or flag"))
+	} else {
+		results = append(results, checkConfiguration())
+		results = append(results, checkTimeout(s))
+		results = append(results, checkProvider(s)...)
+		results = append(results, checkSecretsFile(s))
+	}
+	results = append(results, checkTempFiles()...)
+
+	failed := false
+	for _, result := range results {
+		fmt.Fprintf(out, "%s  %-14s %s\n", result.status, result.name, result.message)
+		if result.fix != "" {
+			fmt.Fprintf(out, "      %-14s fix: %s\n", "", result.fix)
+		}
+		failed = failed || result.status == checkFail
+	}
+	if failed {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+// checkConfiguration reports the configuration files read.
+func checkConfiguration() checkResult {
+	paths, err := configPaths()
+	if err != nil {
+		return warn("configuration", err.Error(), "")
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		_, err := os.Stat(path)
+		return err != nil
+	})
+	if len(paths) == 0 {
+		return pass("configuration", "no configuration files, flag defaults apply")
+	}
+	return pass("configuration", "read "+strings.Join(paths, ", "))
+}
+
+// checkTimeout reports the interactive mode timeout, and CONJUR_HTTP_TIMEOUT
+// values summon ignores.
+func checkTimeout(s *settings) checkResult {
+	message := fmt.Sprintf("providers get %ds to answer in interactive mode (%s)", s.Timeout, s.sources["timeout"])
+	if value, ok := os.LookupEnv(prov.InteractiveTimeoutEnvVar); ok && value != "" {
+		if seconds, err := strconv.Atoi(value); err != nil || seconds <= 0 {
+			return warn("timeout", fmt.Sprintf("%s=%q is not a positive number of seconds and is ignored: %s",
+				prov.InteractiveTimeoutEnvVar, value, message),
+				fmt.Sprintf("set %s to a number of seconds, e.g. %s=120", prov.InteractiveTimeoutEnvVar, prov.InteractiveTimeoutEnvVar))
+		}
+	}
+	return pass("timeout", message)
+}
+
+// checkProvider checks that the provider can be found and run, with a test
+// call that doesn't fetch any secret.
+func checkProvider(s *settings) []checkResult {
+	var results []checkResult
+	// Providers given by path, builtin and mock providers don't need the
+	// provider path
+	if !strings.ContainsAny(s.Provider, ":"+string(os.PathSeparator)) {
+		result := checkProviderPath()
+		if result.status == checkFail && s.Provider == "" {
+			return []checkResult{result}
+		}
+		results = append(results, result)
+	}
+
+	path, err := prov.Resolve(s.Provider)
+	if err != nil {
+		message, fix := strings.TrimSpace(err.Error()), "check the name or path of the provider"
+		switch {
+		case errors.Is(err, prov.ErrMultipleProviders):
+			fix = "choose one with -p, SUMMON_PROVIDER or `provider:` in a configuration file"
+		case errors.Is(err, prov.ErrNoProvider):
+			fix = "install a provider in the provider path, or give one with -p or SUMMON_PROVIDER"
+		}
+		return append(results, fail("provider", message, fix))
+	}
+
+	if strings.HasPrefix(path, prov.BuiltinPrefix) {
+		results = append(results, pass("provider", path+" (compiled into summon)"))
+		if _, err := prov.New(path); err != nil {
+			return append(results, fail("test call", err.Error(), "check the argument of the builtin provider"))
+		}
+		return append(results, pass("test call", "the provider can be created"))
+	}
+	source := s.sources["provider"]
+	if source == sourceDefault {
+		source = "the only provider of the provider path"
+	}
+	results = append(results, pass("provider", fmt.Sprintf("%s (%s)", path, source)))
+
+	stat, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return append(results, fail("executable", err.Error(), "reinstall the provider"))
+	case stat.IsDir():
+		return append(results, fail("executable", path+" is a directory", "give the path of the provider executable"))
+	case runtime.GOOS != "windows" && stat.Mode().Perm()&0o111 == 0:
+		return append(results, fail("executable", fmt.Sprintf("%s is not executable (%s)", path, stat.Mode()), "chmod +x "+path))
+	}
+	results = append(results, pass("executable", stat.Mode().String()))
+
+	timeout := time.Duration(s.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	info := prov.Inspect(ctx, path, s.execOptions(path))
+	elapsed := time.Since(start)
+	// A provider killed on timeout may also have left an error in info
+	switch {
+	case ctx.Err() != nil:
+		return append(results, fail("test call", fmt.Sprintf("the provider didn't answer within %s", timeout),
+			"check the provider's connectivity, or raise the timeout with CONJUR_HTTP_TIMEOUT or `timeout:`"))
+	case info.Error != "":
+		return append(results, fail("test call", info.Error, "reinstall the provider, or pin it again if it was upgraded on purpose"))
+	}
+
+	version := "unknown version"
+	if info.Version != "" {
+		version = "version " + info.Version
+	}
+	message := fmt.Sprintf("%s, protocols: %s, answered in %s", version, strings.Join(info.Protocols, ", "), elapsed.Round(time.Millisecond))
+	switch {
+	case len(info.Protocols) < 2:
+		results = append(results, warn("test call", message+"; no interactive mode, summon runs the provider once per secret",
+			"upgrade the provider to a version supporting interactive mode"))
+	case elapsed > timeout/2:
+		results = append(results, warn("test call", message+fmt.Sprintf(", close to the %s timeout", timeout),
+			"raise the timeout with CONJUR_HTTP_TIMEOUT or `timeout:` in a configuration file"))
+	default:
+		results = append(results, pass("test call", message))
+	}
+	return results
+}
+
+// checkProviderPath reports the directory providers are searched in.
+func checkProviderPath() checkResult {
+	dir, err := prov.GetDefaultPath()
+	if err != nil {
+		return fail("provider path", "no provider directory found",
+			"set SUMMON_PROVIDER_PATH to the directory containing providers, or install one in /usr/local/lib/summon")
+	}
+	source := ""
+	for _, path := range prov.SearchPaths() {
+		if path.Path == dir {
+			source = path.Source
+			break
+		}
+	}
+	providers, err := prov.GetAllProviders(dir)
+	switch {
+	case err != nil:
+		return fail("provider path", err.Error(), "create the directory or fix SUMMON_PROVIDER_PATH")
+	case len(providers) == 0:
+		return warn("provider path", fmt.Sprintf("%s (%s) holds no providers", dir, source), "install a provider, e.g. summon-conjur, in "+dir)
+	}
+	return pass("provider path", fmt.Sprintf("%s (%s): %s", dir, source, strings.Join(providers, ", ")))
+}
+
+// checkSecretsFile checks that the secrets configuration can be found and
+// parsed.
+func checkSecretsFile(s *settings) checkResult {
+	if s.File == "-" {
+		return pass("secrets file", "read from stdin")
+	}
+	path, err := summon.LocateConfig(s.File, s.Up)
+	if err != nil {
+		return fail("secrets file", err.Error(), "create it, e.g. with `summon tool init`, or run summon from the directory holding it")
+	}
+	config, err := secretsyml.ParseFromFile(path, s.Environment, s.Subs)
+	if err != nil {
+		fix := "create it, e.g. with `summon tool init`, give its path with -f, or look for it in parent directories with --up"
+		if !os.IsNotExist(err) {
+			fix = "fix the file, see `summon tool fmt --check`"
+		}
+		return fail("secrets file", fmt.Sprintf("Unable to parse %s: %v", path, err), fix)
+	}
+	return pass("secrets file", fmt.Sprintf("%s: %d secrets, %d files", path, len(config.EnvSecrets), len(config.Files)))
+}
+
+// checkTempFiles checks where the temporary files backing !file secrets and
+// @SUMMONENVFILE are written, and that they can be.
+func checkTempFiles() []checkResult {
+	var results []checkResult
+	dir := summon.SharedMemoryDir()
+	if dir != "" {
+		results = append(results, pass("/dev/shm", "temporary files holding secrets are kept in memory in "+dir))
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dir = home
+		results = append(results, warn("/dev/shm", "not available: temporary files holding secrets are written under "+dir,
+			"mount a tmpfs on /dev/shm where possible (it isn't on macOS)"))
+	}
+
+	f, err := os.CreateTemp(dir, ".summon-doctor")
+	if err != nil {
+		return append(results, fail("env file", fmt.Sprintf("@SUMMONENVFILE and !file secrets can't be written: %v", err),
+			"make "+dir+" writable, or free up space"))
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return append(results, pass("env file", "@SUMMONENVFILE and !file secrets can be written to "+dir))
+}
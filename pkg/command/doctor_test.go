@@ -0,0 +1,81 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDoctor(t *testing.T) {
+	// Answers --version, and supports interactive mode
+	const script = "#!/bin/bash\n" +
+		"if [ \"$1\" = --version ]; then echo 1.0.0; exit; fi\n" +
+		"if [ $# -eq 0 ]; then while read -r path; do echo -n value | base64; done; exit; fi\n" +
+		"echo value\n"
+
+	setup := func(t *testing.T, mode os.FileMode) (string, string) {
+		_, _, projectPath := setupConfigFiles(t, "", "", "")
+		projectDir := filepath.Dir(projectPath)
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "secrets.yml"), []byte("A: !var a\nB: !var b\n"), 0o600))
+		providerDir := t.TempDir()
+		provider := filepath.Join(providerDir, "summon-test")
+		require.NoError(t, os.WriteFile(provider, []byte(script), mode))
+		t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+		return projectDir, provider
+	}
+
+	t.Run("passes with a working setup", func(t *testing.T) {
+		projectDir, provider := setup(t, 0o755)
+
+		var out bytes.Buffer
+		require.NoError(t, runDoctor(fakeFlags{"up": true}, &out))
+		assert.Regexp(t, `(?m)^PASS  provider path +`+filepath.Dir(provider)+` \(SUMMON_PROVIDER_PATH\): summon-test$`, out.String())
+		assert.Regexp(t, `(?m)^PASS  provider +`+provider+` \(the only provider of the provider path\)$`, out.String())
+		assert.Regexp(t, `(?m)^PASS  executable +-rwxr-xr-x$`, out.String())
+		assert.Regexp(t, `(?m)^PASS  test call +version 1\.0\.0, protocols: single, interactive, answered in `, out.String())
+		assert.Regexp(t, `(?m)^PASS  secrets file +`+filepath.Join(projectDir, "secrets.yml")+`: 2 secrets, 0 files$`, out.String())
+		assert.Regexp(t, `(?m)^PASS  env file +`, out.String())
+		assert.NotContains(t, out.String(), "FAIL")
+	})
+
+	t.Run("reports problems with suggested fixes", func(t *testing.T) {
+		_, provider := setup(t, 0o644)
+		t.Setenv("CONJUR_HTTP_TIMEOUT", "soon")
+
+		var out bytes.Buffer
+		err := runDoctor(fakeFlags{}, &out)
+		require.Error(t, err)
+		assert.Contains(t, out.String(), "WARN  timeout        CONJUR_HTTP_TIMEOUT=\"soon\" is not a positive number of seconds and is ignored")
+		assert.Contains(t, out.String(), "FAIL  executable     "+provider+" is not executable (-rw-r--r--)\n"+
+			"                     fix: chmod +x "+provider+"\n")
+		assert.NotContains(t, out.String(), "test call")
+		assert.Contains(t, out.String(), "FAIL  secrets file   Unable to parse secrets.yml: open secrets.yml: no such file or directory\n"+
+			"                     fix: create it, e.g. with `summon tool init`, give its path with -f, or look for it in parent directories with --up\n")
+	})
+
+	t.Run("asks to choose between providers", func(t *testing.T) {
+		_, provider := setup(t, 0o755)
+		require.NoError(t, os.WriteFile(provider+"-2", []byte(script), 0o755))
+
+		var out bytes.Buffer
+		require.Error(t, runDoctor(fakeFlags{"up": true}, &out))
+		assert.Contains(t, out.String(), "FAIL  provider       More than one provider found in "+filepath.Dir(provider)+", please specify one\n"+
+			"                     fix: choose one with -p, SUMMON_PROVIDER or `provider:` in a configuration file\n")
+
+		out.Reset()
+		require.NoError(t, runDoctor(fakeFlags{"up": true, "provider": "summon-test-2"}, &out))
+		assert.Regexp(t, `(?m)^PASS  provider +`+provider+`-2 \(--provider\)$`, out.String())
+	})
+
+	t.Run("reports configuration errors", func(t *testing.T) {
+		setupConfigFiles(t, "", "", "timeout: never\n")
+
+		var out bytes.Buffer
+		require.Error(t, runDoctor(fakeFlags{}, &out))
+		assert.Regexp(t, `^FAIL  configuration  `, out.String())
+	})
+}
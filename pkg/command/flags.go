@@ -10,6 +10,11 @@ var Flags = []cli.Flag{
 		Name:  "p, provider",
 		Usage: "Path to provider for fetching secrets",
 	},
+	cli.StringSliceFlag{
+		Name:  "fallback",
+		Value: &cli.StringSlice{},
+		Usage: "Provider to fetch the secrets the provider fails to fetch from, repeatable to try several in order",
+	},
 	cli.StringFlag{
 		Name:  "e, environment",
 		Usage: "Specify section/environment to parse from secrets.yaml",
@@ -17,7 +22,7 @@ var Flags = []cli.Flag{
 	cli.StringFlag{
 		Name:  "f",
 		Value: "secrets.yml",
-		Usage: "Path to secrets.yml",
+		Usage: "Path to secrets.yml, or - to read it from stdin",
 	},
 	cli.BoolFlag{
 		Name:  "up",
@@ -41,9 +46,30 @@ var Flags = []cli.Flag{
 		Name:  "ignore-all, I",
 		Usage: "Ignore inaccessible or missing keys",
 	},
+	cli.StringFlag{
+		Name:  "prefix",
+		Usage: "Prefix the names of the exported variables, overriding summon.env_prefix",
+	},
+	cli.StringFlag{
+		Name:  "case",
+		Usage: "Change the case of the exported variable names to upper or lower, overriding summon.env_case",
+	},
 	cli.BoolFlag{
 		Name:  "all-provider-versions, V",
-		Usage: "List of all of the providers in the default path and their versions(if they have the --version tag)",
+		Usage: "List the providers in the provider path with their versions (see also `summon tool providers list`)",
+	},
+	cli.BoolFlag{
+		Name:  "stats",
+		Usage: "Report the time spent parsing, fetching and writing files, and how providers were used, on stderr",
+	},
+	cli.StringFlag{
+		Name:  "stats-format",
+		Value: statsText,
+		Usage: "Format of the --stats report: text or json",
+	},
+	cli.StringFlag{
+		Name:  "stats-file",
+		Usage: "Write the --stats report to a file instead of stderr",
 	},
 	cli.BoolFlag{
 		Name:  "debug, d",
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/urfave/cli"
+)
+
+var fmtCommand = cli.Command{
+	Name:      "fmt",
+	Usage:     "Rewrite a secrets.yml file in its canonical format",
+	ArgsUsage: " ",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "f",
+			Value: "secrets.yml",
+			Usage: "Path to secrets.yml",
+		},
+		cli.BoolFlag{
+			Name:  "w, write",
+			Usage: "Write the result back to the file instead of printing it",
+		},
+		cli.BoolFlag{
+			Name:  "check",
+			Usage: "Exit with status 1 if the file isn't formatted, without changing it",
+		},
+		cli.BoolFlag{
+			Name:  "sort-keys",
+			Usage: "Sort secrets by name, changing their declaration order",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		return runFmt(c.String("f"), fmtOptions{
+			write:    c.Bool("write"),
+			check:    c.Bool("check"),
+			sortKeys: c.Bool("sort-keys"),
+		}, c.App.Writer)
+	},
+}
+
+// fmtOptions holds the flags of the fmt command.
+type fmtOptions struct {
+	write    bool
+	check    bool
+	sortKeys bool
+}
+
+// runFmt formats the secrets.yml file at path, either printing the result
+// to out, writing it back to the file or, with check, reporting whether the
+// file is formatted.
+func runFmt(path string, opts fmtOptions, out io.Writer) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := secretsyml.Reformat(content, opts.sortKeys)
+	if err != nil {
+		return fmt.Errorf("Unable to format %s: %w", path, err)
+	}
+
+	switch {
+	case opts.check:
+		if !bytes.Equal(content, formatted) {
+			return cli.NewExitError(fmt.Sprintf("%s is not formatted, run `summon tool fmt -w -f %s`", path, path), 1)
+		}
+		return nil
+	case opts.write:
+		if bytes.Equal(content, formatted) {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, formatted, info.Mode().Perm())
+	default:
+		_, err = out.Write(formatted)
+		return err
+	}
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestRunFmt(t *testing.T) {
+	input := "KEY: !file:var path/to/key\nsection:\n    OTHER: value\n"
+	expected := "KEY: !var:file path/to/key\nsection:\n  OTHER: value\n"
+
+	writeInput := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "secrets.yml")
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o640))
+		return path
+	}
+
+	t.Run("prints the formatted file", func(t *testing.T) {
+		path := writeInput(t, input)
+
+		var out bytes.Buffer
+		assert.NoError(t, runFmt(path, fmtOptions{}, &out))
+		assert.Equal(t, expected, out.String())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, input, string(content))
+	})
+
+	t.Run("writes the formatted file in place", func(t *testing.T) {
+		path := writeInput(t, input)
+
+		var out bytes.Buffer
+		assert.NoError(t, runFmt(path, fmtOptions{write: true}, &out))
+		assert.Empty(t, out.String())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("sorts keys when asked", func(t *testing.T) {
+		path := writeInput(t, "ZULU: z\nALPHA: a\n")
+
+		var out bytes.Buffer
+		assert.NoError(t, runFmt(path, fmtOptions{sortKeys: true}, &out))
+		assert.Equal(t, "ALPHA: a\nZULU: z\n", out.String())
+	})
+
+	t.Run("check fails on unformatted files", func(t *testing.T) {
+		path := writeInput(t, input)
+
+		var out bytes.Buffer
+		err := runFmt(path, fmtOptions{check: true}, &out)
+		assert.EqualError(t, err, path+" is not formatted, run `summon tool fmt -w -f "+path+"`")
+		if exitErr, ok := err.(cli.ExitCoder); assert.True(t, ok) {
+			assert.Equal(t, 1, exitErr.ExitCode())
+		}
+		assert.Empty(t, out.String())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, input, string(content))
+	})
+
+	t.Run("check passes on formatted files", func(t *testing.T) {
+		path := writeInput(t, expected)
+		assert.NoError(t, runFmt(path, fmtOptions{check: true}, &bytes.Buffer{}))
+	})
+
+	t.Run("reports invalid files", func(t *testing.T) {
+		path := writeInput(t, "- not a mapping\n")
+		err := runFmt(path, fmtOptions{}, &bytes.Buffer{})
+		assert.EqualError(t, err, "Unable to format "+path+": invalid YAML structure: expected mapping")
+	})
+}
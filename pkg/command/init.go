@@ -0,0 +1,99 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/urfave/cli"
+)
+
+var initCommand = cli.Command{
+	Name:      "init",
+	Usage:     "Create or extend a secrets.yml file from .env or docker-compose files",
+	ArgsUsage: "SOURCE...",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "o, output",
+			Value: "secrets.yml",
+			Usage: "Path to the secrets.yml file to create or extend, or '-' to print it",
+		},
+		cli.StringFlag{
+			Name:  "environment",
+			Usage: "Add the variables to this environment section",
+		},
+		cli.StringFlag{
+			Name:  "prefix",
+			Usage: "Prefix of the variable paths generated for secrets, e.g. prod/myapp/",
+		},
+		cli.StringFlag{
+			Name:  "service",
+			Usage: "Compose service to read the environment of",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if !c.Args().Present() {
+			return cli.NewExitError("summon tool init needs at least one .env or compose file", 1)
+		}
+		return runInit(c.Args(), c.String("output"), initOptions{
+			ImportOptions: secretsyml.ImportOptions{
+				Environment: c.String("environment"),
+				PathPrefix:  c.String("prefix"),
+			},
+			service: c.String("service"),
+		}, c.App.Writer)
+	},
+}
+
+// initOptions holds the flags of the init command.
+type initOptions struct {
+	secretsyml.ImportOptions
+	service string
+}
+
+// runInit imports the variables of sources into the secrets.yml file at
+// output, creating it if needed, or prints the result to out when output
+// is "-". Keys the file already declares are left untouched.
+func runInit(sources []string, output string, opts initOptions, out io.Writer) error {
+	var entries []secretsyml.ImportEntry
+	for _, source := range sources {
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		sourceEntries, err := secretsyml.ReadImportSource(source, content, opts.service)
+		if err != nil {
+			return fmt.Errorf("Unable to read %s: %w", source, err)
+		}
+		entries = append(entries, sourceEntries...)
+	}
+
+	var existing []byte
+	if output != "-" {
+		var err error
+		existing, err = os.ReadFile(output)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	content, skipped, err := secretsyml.Import(existing, entries, opts.ImportOptions)
+	if err != nil {
+		return fmt.Errorf("Unable to import into %s: %w", output, err)
+	}
+
+	if output == "-" {
+		_, err = out.Write(content)
+		return err
+	}
+	if err := os.WriteFile(output, content, 0o644); err != nil {
+		return err
+	}
+	for _, key := range skipped {
+		fmt.Fprintf(out, "Kept %s, already declared in %s\n", key, output)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInit(t *testing.T) {
+	dir := t.TempDir()
+	dotenv := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(dotenv, []byte("DB_HOST=localhost\nDB_PASSWORD=hunter2\n"), 0o600))
+	compose := filepath.Join(dir, "compose.yml")
+	require.NoError(t, os.WriteFile(compose, []byte("services:\n  app:\n    environment:\n      - DB_HOST=db\n      - API_TOKEN\n"), 0o600))
+
+	t.Run("prints the configuration", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runInit([]string{dotenv, compose}, "-", initOptions{ImportOptions: secretsyml.ImportOptions{PathPrefix: "app/"}}, &out)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "DB_HOST: db\nDB_PASSWORD: !var app/DB_PASSWORD\nAPI_TOKEN: !var app/API_TOKEN\n")
+	})
+
+	t.Run("extends an existing file", func(t *testing.T) {
+		output := filepath.Join(dir, "secrets.yml")
+		require.NoError(t, os.WriteFile(output, []byte("DB_HOST: !var vault/db/host\n"), 0o600))
+
+		var out bytes.Buffer
+		require.NoError(t, runInit([]string{dotenv}, output, initOptions{}, &out))
+		assert.Equal(t, "Kept DB_HOST, already declared in "+output+"\n", out.String())
+
+		content, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Equal(t, "DB_HOST: !var vault/db/host\nDB_PASSWORD: !var DB_PASSWORD\n", string(content))
+	})
+
+	t.Run("reports unreadable sources", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.env")
+		require.NoError(t, os.WriteFile(bad, []byte("oops\n"), 0o600))
+
+		err := runInit([]string{bad}, "-", initOptions{}, &bytes.Buffer{})
+		assert.EqualError(t, err, "Unable to read "+bad+": line 1: expected KEY=value")
+	})
+}
@@ -0,0 +1,403 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v3"
+)
+
+var providersJSONFlag = cli.BoolFlag{Name: "json", Usage: "Print JSON, for tooling"}
+
+// providersInspectDescription warns that inspecting providers runs them, see
+// provider.Inspect.
+const providersInspectDescription = "Providers are run with --version for their version, and with no arguments, in\n" +
+	"   interactive mode, to find out whether they support it. Providers that don't\n" +
+	"   match their pinned checksum aren't run."
+
+var providersCommand = cli.Command{
+	Name:  "providers",
+	Usage: "Inspect and manage the provider executables summon runs",
+	Subcommands: []cli.Command{
+		{
+			Name:        "list",
+			Usage:       "List the provider search paths and the providers of the one used",
+			ArgsUsage:   " ",
+			Description: providersInspectDescription,
+			Flags:       []cli.Flag{providersJSONFlag},
+			Action: func(c *cli.Context) error {
+				s, err := loadSettings(globalFlags{c})
+				if err != nil {
+					return err
+				}
+				return runProvidersList(s, c.Bool("json"), c.App.Writer)
+			},
+		},
+		{
+			Name:        "info",
+			Usage:       "Describe a provider: its path, permissions, checksum, version and protocols",
+			ArgsUsage:   "PROVIDER",
+			Description: providersInspectDescription,
+			Flags:       []cli.Flag{providersJSONFlag},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.NewExitError("Usage: summon tool providers info PROVIDER", 1)
+				}
+				s, err := loadSettings(globalFlags{c})
+				if err != nil {
+					return err
+				}
+				return runProvidersInfo(s, c.Args().First(), c.Bool("json"), c.App.Writer)
+			},
+		},
+		{
+			Name:      "which",
+			Usage:     "Print the path of a provider, by default the one summon uses",
+			ArgsUsage: "[PROVIDER]",
+			Flags:     []cli.Flag{providersJSONFlag},
+			Action: func(c *cli.Context) error {
+				if c.NArg() > 1 {
+					return cli.NewExitError("Usage: summon tool providers which [PROVIDER]", 1)
+				}
+				s, err := loadSettings(globalFlags{c})
+				if err != nil {
+					return err
+				}
+				name := c.Args().First()
+				if name == "" {
+					name = s.Provider
+				}
+				return runProvidersWhich(name, c.Bool("json"), c.App.Writer)
+			},
+		},
+		{
+			Name:      "pin",
+			Usage:     "Record the checksums of providers in a configuration file, to verify them before each run",
+			ArgsUsage: "[PROVIDER...]",
+			Description: "Without arguments, pins every provider of the provider directory. Providers\n" +
+				"   are pinned by name, or by path when given one.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "c, config",
+					Usage: "Configuration file to write the checksums to (default: the user configuration file)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				configPath := c.String("config")
+				if configPath == "" {
+					configPath = userConfigPath()
+				}
+				return runProvidersPin(configPath, c.Args(), c.App.Writer)
+			},
+		},
+	},
+}
+
+// runProvidersPin records the checksums of the named providers, or of all
+// the providers of the provider directory, in the configuration file at
+// configPath.
+func runProvidersPin(configPath string, names []string, out io.Writer) error {
+	if configPath == "" {
+		return fmt.Errorf("no configuration file to write to: use --config")
+	}
+	if len(names) == 0 {
+		dir, err := prov.GetDefaultPath()
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no providers found in %s", dir)
+		}
+	}
+
+	pins := make(map[string]string, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, prov.BuiltinPrefix) || strings.HasPrefix(name, prov.MockPrefix) {
+			return fmt.Errorf("Unable to pin %s: only provider executables can be pinned", name)
+		}
+		path, err := prov.Resolve(name)
+		if err != nil {
+			return fmt.Errorf("Unable to pin %s: %w", name, err)
+		}
+		sum, err := prov.Checksum(path)
+		if err != nil {
+			return fmt.Errorf("Unable to pin %s: %w", name, err)
+		}
+		if filepath.Base(name) != name {
+			name = path
+		}
+		pins[name] = sum
+	}
+
+	if err := pinChecksums(configPath, pins); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(pins)) {
+		fmt.Fprintf(out, "%s  %s\n", pins[name], name)
+	}
+	_, err := fmt.Fprintf(out, "Pinned in %s\n", configPath)
+	return err
+}
+
+// pinChecksums sets the sha256 setting of providers in the configuration
+// file at path, keeping the rest of the file, comments included.
+func pinChecksums(path string, pins map[string]string) error {
+	// Don't rewrite files summon couldn't read
+	if _, err := readConfigFile(path); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("Unable to read configuration from %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if root := doc.Content[0]; root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+		root.Kind, root.Tag, root.Value = yaml.MappingNode, "", ""
+	}
+	providers, err := mappingEntry(doc.Content[0], "providers", "providers")
+	if err != nil {
+		return fmt.Errorf("Unable to pin providers in %s: %w", path, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(pins)) {
+		provider, err := mappingEntry(providers, name, "providers."+name)
+		if err != nil {
+			return fmt.Errorf("Unable to pin providers in %s: %w", path, err)
+		}
+		sum := valueNode(provider, "sha256")
+		if sum == nil {
+			sum = &yaml.Node{Kind: yaml.ScalarNode}
+			provider.Content = append(provider.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "sha256"}, sum)
+		}
+		sum.Kind, sum.Tag, sum.Value = yaml.ScalarNode, "!!str", pins[name]
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// valueNode returns the value of key in a mapping node, or nil.
+func valueNode(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingEntry returns the mapping value of key in a mapping node, adding
+// it when missing or empty. The value is called setting in errors.
+func mappingEntry(mapping *yaml.Node, key, setting string) (*yaml.Node, error) {
+	value := valueNode(mapping, key)
+	switch {
+	case value == nil:
+		value = &yaml.Node{Kind: yaml.MappingNode}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+		value.Kind, value.Tag, value.Value = yaml.MappingNode, "", ""
+	case value.Kind != yaml.MappingNode:
+		return nil, fmt.Errorf("%s is not a mapping", setting)
+	}
+	return value, nil
+}
+
+// providerInspectTimeout bounds the time spent running each provider to
+// inspect it.
+const providerInspectTimeout = 10 * time.Second
+
+// providerListing is what summon tool providers list prints.
+type providerListing struct {
+	SearchPaths  []searchPathListing `json:"search_paths"`
+	ProviderPath string              `json:"provider_path"` // Empty when no search path exists
+	Providers    []providerInfo      `json:"providers"`
+}
+
+// searchPathListing is a search path of providers, and whether it is the
+// one used.
+type searchPathListing struct {
+	prov.SearchPath
+	Used bool `json:"used"`
+}
+
+// providerInfo describes a provider, and whether summon would use it when
+// run with the same flags.
+type providerInfo struct {
+	prov.Info
+	Selected bool `json:"selected"`
+}
+
+// inspectProvider describes the provider at path, which summon would use if
+// it is selected.
+func inspectProvider(s *settings, path, selected string) providerInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), providerInspectTimeout)
+	defer cancel()
+	return providerInfo{Info: prov.Inspect(ctx, path, s.execOptions(path)), Selected: path == selected}
+}
+
+// runProvidersList prints the directories providers are searched in, and
+// describes the providers of the one used.
+func runProvidersList(s *settings, asJSON bool, out io.Writer) error {
+	listing := providerListing{Providers: []providerInfo{}}
+	listing.ProviderPath, _ = prov.GetDefaultPath()
+	for _, path := range prov.SearchPaths() {
+		listing.SearchPaths = append(listing.SearchPaths, searchPathListing{SearchPath: path, Used: path.Path == listing.ProviderPath})
+	}
+	if listing.ProviderPath != "" {
+		entries, err := os.ReadDir(listing.ProviderPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		selected, _ := prov.Resolve(s.Provider)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				listing.Providers = append(listing.Providers, inspectProvider(s, filepath.Join(listing.ProviderPath, entry.Name()), selected))
+			}
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listing)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Provider search paths:")
+	for _, path := range listing.SearchPaths {
+		state := "missing"
+		switch {
+		case path.Used:
+			state = "used"
+		case path.Exists:
+			state = "exists"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", path.Source, path.Path, state)
+	}
+	if listing.ProviderPath == "" {
+		fmt.Fprintln(w, "No provider directory found")
+		return w.Flush()
+	}
+	fmt.Fprintf(w, "Providers in %s:\n", listing.ProviderPath)
+	for _, info := range listing.Providers {
+		marker := " "
+		if info.Selected {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", marker, info.Name, info.summary())
+	}
+	if len(listing.Providers) > 0 {
+		fmt.Fprintln(w, "(* is the provider summon uses)")
+	}
+	return w.Flush()
+}
+
+// summary describes a provider on one line.
+func (info providerInfo) summary() string {
+	if info.Error != "" {
+		return info.Mode + "\t" + info.Error
+	}
+	version := "unknown version"
+	if info.Version != "" {
+		version = "version " + info.Version
+	}
+	return info.Mode + "\t" + version + "\t" + strings.Join(info.Protocols, ", ")
+}
+
+// runProvidersInfo describes the provider called name.
+func runProvidersInfo(s *settings, name string, asJSON bool, out io.Writer) error {
+	path, err := prov.Resolve(name)
+	if err != nil {
+		return err
+	}
+	selected, _ := prov.Resolve(s.Provider)
+	info := inspectProvider(s, path, selected)
+
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "name:\t%s\n", info.Name)
+	fmt.Fprintf(w, "path:\t%s\n", info.Path)
+	if info.Mode != "" {
+		fmt.Fprintf(w, "mode:\t%s\n", info.Mode)
+	}
+	if info.SHA256 != "" {
+		pinned := ""
+		if info.Pinned {
+			pinned = " (pinned)"
+		}
+		fmt.Fprintf(w, "sha256:\t%s%s\n", info.SHA256, pinned)
+	}
+	if info.Version != "" {
+		fmt.Fprintf(w, "version:\t%s\n", info.Version)
+	}
+	if len(info.Protocols) > 0 {
+		fmt.Fprintf(w, "protocols:\t%s\n", strings.Join(info.Protocols, ", "))
+	}
+	fmt.Fprintf(w, "selected:\t%t\n", info.Selected)
+	if info.Error != "" {
+		fmt.Fprintf(w, "error:\t%s\n", info.Error)
+	}
+	return w.Flush()
+}
+
+// runProvidersWhich prints the path of the provider called name, or of the
+// provider found in the provider directory when name is empty.
+func runProvidersWhich(name string, asJSON bool, out io.Writer) error {
+	path, err := prov.Resolve(name)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return json.NewEncoder(out).Encode(struct {
+			Name string `json:"name,omitempty"`
+			Path string `json:"path"`
+		}{name, path})
+	}
+	_, err = fmt.Fprintln(out, path)
+	return err
+}
@@ -0,0 +1,197 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as a mock provider executable when
+// MockFixturesEnvVar is set, see writeTestProviders.
+func TestMain(m *testing.M) {
+	if fixturesDir := os.Getenv(prov.MockFixturesEnvVar); fixturesDir != "" {
+		fixtures := filepath.Join(fixturesDir, filepath.Base(os.Args[0])+".yml")
+		os.Exit(prov.ServeMock(fixtures, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	os.Exit(m.Run())
+}
+
+// writeTestProviders writes a provider directory and returns its path. Its
+// providers run the test binary as mock provider executables supporting
+// single calls only, answering from the fixtures named after them in the
+// directory that MockFixturesEnvVar holds in these tests: testprovider has
+// version 1.2.3, testprovider-noversionsupport fails when called with
+// --version, and testprovider-trailingnewline prints its version with a
+// trailing newline.
+func writeTestProviders(t *testing.T) string {
+	self, err := os.Executable()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	providerDir, fixturesDir := filepath.Join(dir, "providers"), filepath.Join(dir, "fixtures")
+	require.NoError(t, os.Mkdir(providerDir, 0o755))
+	require.NoError(t, os.Mkdir(fixturesDir, 0o755))
+	for name, version := range map[string]string{
+		"testprovider":                  "1.2.3",
+		"testprovider-noversionsupport": "",
+		"testprovider-trailingnewline":  "3.2.1\n",
+	} {
+		require.NoError(t, os.Symlink(self, filepath.Join(providerDir, name)))
+		fixtures := fmt.Sprintf("protocol: single\nversion: %q\n", version)
+		require.NoError(t, os.WriteFile(filepath.Join(fixturesDir, name+".yml"), []byte(fixtures), 0o600))
+	}
+	t.Setenv(prov.MockFixturesEnvVar, fixturesDir)
+	return providerDir
+}
+
+func TestRunProvidersPin(t *testing.T) {
+	dir := t.TempDir()
+	providerDir := filepath.Join(dir, "providers")
+	require.NoError(t, os.MkdirAll(filepath.Join(providerDir, "subdir"), 0o755))
+	for name, content := range map[string]string{"summon-conjur": "conjur", "summon-aws": "aws"} {
+		require.NoError(t, os.WriteFile(filepath.Join(providerDir, name), []byte(content), 0o755))
+	}
+	t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+	conjurSum, err := prov.Checksum(filepath.Join(providerDir, "summon-conjur"))
+	require.NoError(t, err)
+	awsSum, err := prov.Checksum(filepath.Join(providerDir, "summon-aws"))
+	require.NoError(t, err)
+
+	t.Run("pins every provider of the provider directory", func(t *testing.T) {
+		config := filepath.Join(dir, "config.yml")
+		require.NoError(t, os.WriteFile(config, []byte("# Team defaults\nenvironment: prod\nproviders:\n  summon-conjur: {protocol: 2} # fast\n"), 0o600))
+
+		var out bytes.Buffer
+		require.NoError(t, runProvidersPin(config, nil, &out))
+		assert.Equal(t, awsSum+"  summon-aws\n"+conjurSum+"  summon-conjur\nPinned in "+config+"\n", out.String())
+
+		content, err := os.ReadFile(config)
+		require.NoError(t, err)
+		assert.Equal(t, "# Team defaults\nenvironment: prod\nproviders:\n"+
+			"  summon-conjur: {protocol: 2, sha256: "+conjurSum+"} # fast\n"+
+			"  summon-aws:\n    sha256: "+awsSum+"\n", string(content))
+	})
+
+	t.Run("pins providers given by path and creates the file", func(t *testing.T) {
+		config := filepath.Join(dir, "new", "config.yml")
+		path := filepath.Join(providerDir, "summon-aws")
+
+		require.NoError(t, runProvidersPin(config, []string{path}, &bytes.Buffer{}))
+		file, err := readConfigFile(config)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]providerConfig{path: {SHA256: awsSum}}, file.Providers)
+	})
+
+	t.Run("rejects providers that aren't executables", func(t *testing.T) {
+		err := runProvidersPin(filepath.Join(dir, "config.yml"), []string{"builtin:localstore:store.json"}, &bytes.Buffer{})
+		assert.EqualError(t, err, "Unable to pin builtin:localstore:store.json: only provider executables can be pinned")
+
+		err = runProvidersPin(filepath.Join(dir, "config.yml"), []string{"summon-missing"}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "Unable to pin summon-missing:")
+	})
+
+	t.Run("refuses to rewrite invalid configuration files", func(t *testing.T) {
+		config := filepath.Join(dir, "invalid.yml")
+		require.NoError(t, os.WriteFile(config, []byte("providers: [summon-conjur]\n"), 0o600))
+
+		err := runProvidersPin(config, nil, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "Unable to read configuration from "+config)
+	})
+}
+
+func TestRunProvidersList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running test.")
+	}
+	providerDir := writeTestProviders(t)
+	t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+	t.Setenv("SUMMON_PROVIDER", "")
+	testprovider := filepath.Join(providerDir, "testprovider")
+
+	t.Run("lists search paths and providers with their versions", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runProvidersList(&settings{Provider: "testprovider"}, false, &out))
+
+		// The version of testprovider-trailingnewline is trimmed, and
+		// testprovider-noversionsupport fails when called with --version
+		assert.Regexp(t, `(?m)^  SUMMON_PROVIDER_PATH +`+providerDir+` +used$`, out.String())
+		assert.Contains(t, out.String(), "Providers in "+providerDir+":\n")
+		assert.Regexp(t, `(?m)^\* testprovider +-rwx\S+ +version 1\.2\.3 +single$`, out.String())
+		assert.Regexp(t, `(?m)^  testprovider-noversionsupport +-rwx\S+ +unknown version +single$`, out.String())
+		assert.Regexp(t, `(?m)^  testprovider-trailingnewline +-rwx\S+ +version 3\.2\.1 +single$`, out.String())
+	})
+
+	t.Run("prints JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runProvidersList(&settings{}, true, &out))
+
+		var listing providerListing
+		require.NoError(t, json.Unmarshal(out.Bytes(), &listing))
+		assert.Equal(t, providerDir, listing.ProviderPath)
+		assert.Equal(t, prov.SearchPath{Source: "SUMMON_PROVIDER_PATH", Path: providerDir, Exists: true}, listing.SearchPaths[0].SearchPath)
+		assert.True(t, listing.SearchPaths[0].Used)
+		require.Len(t, listing.Providers, 3)
+		assert.Equal(t, testprovider, listing.Providers[0].Path)
+		assert.Equal(t, "1.2.3", listing.Providers[0].Version)
+		assert.False(t, listing.Providers[0].Selected, "more than one provider and none selected")
+	})
+
+	t.Run("doesn't run providers that don't match their pinned checksum", func(t *testing.T) {
+		s := &settings{Providers: map[string]providerConfig{"testprovider": {SHA256: strings.Repeat("0", 64)}}}
+
+		var out bytes.Buffer
+		require.NoError(t, runProvidersList(s, false, &out))
+		assert.Regexp(t, `(?m)^  testprovider +-rwx\S+ +provider checksum mismatch: `, out.String())
+	})
+}
+
+func TestRunProvidersInfo(t *testing.T) {
+	providerDir := writeTestProviders(t)
+	t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+	testprovider := filepath.Join(providerDir, "testprovider")
+	sum, err := prov.Checksum(testprovider)
+	require.NoError(t, err)
+	s := &settings{Provider: "testprovider", Providers: map[string]providerConfig{"testprovider": {SHA256: sum}}}
+
+	var out bytes.Buffer
+	require.NoError(t, runProvidersInfo(s, "testprovider", false, &out))
+	assert.Regexp(t, `^name: +testprovider
+path: +`+testprovider+`
+mode: +-rwx\S+
+sha256: +`+sum+` \(pinned\)
+version: +1\.2\.3
+protocols: +single
+selected: +true
+$`, out.String())
+
+	out.Reset()
+	require.NoError(t, runProvidersInfo(&settings{}, testprovider, true, &out))
+	var info providerInfo
+	require.NoError(t, json.Unmarshal(out.Bytes(), &info))
+	assert.Equal(t, prov.Info{Name: "testprovider", Path: testprovider, Mode: info.Mode, SHA256: sum, Version: "1.2.3", Protocols: []string{"single"}}, info.Info)
+	assert.False(t, info.Selected)
+}
+
+func TestRunProvidersWhich(t *testing.T) {
+	providerDir := writeTestProviders(t)
+	t.Setenv("SUMMON_PROVIDER_PATH", providerDir)
+	t.Setenv("SUMMON_PROVIDER", "")
+
+	var out bytes.Buffer
+	require.NoError(t, runProvidersWhich("testprovider", false, &out))
+	assert.Equal(t, filepath.Join(providerDir, "testprovider")+"\n", out.String())
+
+	out.Reset()
+	require.NoError(t, runProvidersWhich("builtin:localstore:store.json", true, &out))
+	assert.JSONEq(t, `{"name": "builtin:localstore:store.json", "path": "builtin:localstore:store.json"}`, out.String())
+
+	err := runProvidersWhich("", false, &out)
+	assert.EqualError(t, err, "More than one provider found in "+providerDir+", please specify one\n")
+}
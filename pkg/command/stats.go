@@ -0,0 +1,91 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cyberark/summon/pkg/summon"
+)
+
+// Formats of the --stats report.
+const (
+	statsText = "text"
+	statsJSON = "json"
+)
+
+// checkStatsFormat rejects --stats-format values other than text and json.
+func checkStatsFormat(format string) error {
+	if format != statsText && format != statsJSON {
+		return fmt.Errorf("invalid --stats-format %q (expected %s or %s)", format, statsText, statsJSON)
+	}
+	return nil
+}
+
+// reportStats writes the --stats report in format to path, or to stderr
+// when path is empty.
+func reportStats(stats *summon.Stats, format, path string) error {
+	if path == "" {
+		return writeStats(stats, format, os.Stderr)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("Unable to write stats: %w", err)
+	}
+	if err := writeStats(stats, format, f); err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to write stats to %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// writeStats writes the --stats report in format to out.
+func writeStats(stats *summon.Stats, format string, out io.Writer) error {
+	if format == statsJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Phases:")
+	for _, phase := range stats.Phases {
+		fmt.Fprintf(w, "  %s\t%s\n", phase.Name, formatDuration(phase.Duration))
+	}
+	fmt.Fprintln(w, "Fetches:")
+	for _, fetch := range stats.Fetches {
+		provider, protocol := fetch.Provider, fetch.Protocol
+		if fetch.Fallback {
+			provider += " (fallback)"
+		}
+		if fetch.SingleFallback != "" {
+			protocol += " (interactive mode failed: " + fetch.SingleFallback + ")"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%d secrets, %d failed\t%s\n", provider, protocol,
+			fetch.Secrets, fetch.Failed, formatDuration(fetch.Duration))
+	}
+	fmt.Fprintln(w, "Secrets:")
+	for _, secret := range stats.Secrets {
+		latency := formatDuration(secret.Latency)
+		if secret.Failed {
+			latency += " (failed)"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", secret.Key, secret.Provider, latency)
+	}
+	if len(stats.Files) > 0 {
+		fmt.Fprintln(w, "Files:")
+		for _, file := range stats.Files {
+			fmt.Fprintf(w, "  %s\t%d bytes\n", file.Path, file.Bytes)
+		}
+	}
+	fmt.Fprintf(w, "Provider processes: %d\n", stats.Processes)
+	return w.Flush()
+}
+
+// formatDuration rounds d for the --stats report.
+func formatDuration(d time.Duration) string {
+	return d.Round(10 * time.Microsecond).String()
+}
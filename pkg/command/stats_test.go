@@ -0,0 +1,71 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/cyberark/summon/pkg/summon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStats(t *testing.T) {
+	stats := &summon.Stats{
+		Phases: []summon.PhaseStats{{Name: "parse", Duration: 300 * time.Microsecond}, {Name: "fetch", Duration: 1500 * time.Millisecond}},
+		Files:  []summon.FileStats{{Path: "/run/app/db.env", Bytes: 42}},
+	}
+	stats.Fetches = []prov.FetchStats{
+		{Provider: "summon-vault", Protocol: "single", SingleFallback: "interactive mode not supported", Secrets: 2, Failed: 1, Duration: time.Second},
+		{Provider: "summon-vault-legacy", Protocol: "interactive", Fallback: true, Secrets: 1, Duration: 500 * time.Millisecond},
+	}
+	stats.Secrets = []prov.SecretStats{
+		{Key: "DB_PASSWORD", Provider: "summon-vault", Latency: 900 * time.Millisecond},
+		{Key: "API_KEY", Provider: "summon-vault", Latency: time.Second, Failed: true},
+		{Key: "API_KEY", Provider: "summon-vault-legacy", Latency: 400 * time.Millisecond},
+	}
+	stats.Processes = 3
+
+	t.Run("writes a text report", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeStats(stats, statsText, &out))
+		assert.Equal(t, `Phases:
+  parse  300µs
+  fetch  1.5s
+Fetches:
+  summon-vault                    single (interactive mode failed: interactive mode not supported)  2 secrets, 1 failed  1s
+  summon-vault-legacy (fallback)  interactive                                                       1 secrets, 0 failed  500ms
+Secrets:
+  DB_PASSWORD  summon-vault         900ms
+  API_KEY      summon-vault         1s (failed)
+  API_KEY      summon-vault-legacy  400ms
+Files:
+  /run/app/db.env  42 bytes
+Provider processes: 3
+`, out.String())
+	})
+
+	t.Run("writes a JSON report to a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "stats.json")
+		require.NoError(t, reportStats(stats, statsJSON, path))
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var report map[string]any
+		require.NoError(t, json.Unmarshal(content, &report))
+		assert.Equal(t, float64(3), report["processes"])
+		assert.Len(t, report["phases"], 2)
+		assert.Len(t, report["fetches"], 2)
+		assert.Equal(t, map[string]any{"key": "API_KEY", "provider": "summon-vault", "latency_ns": float64(time.Second), "failed": true}, report["secrets"].([]any)[1])
+		assert.Equal(t, []any{map[string]any{"path": "/run/app/db.env", "bytes": float64(42)}}, report["files"])
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		assert.NoError(t, checkStatsFormat(statsJSON))
+		assert.EqualError(t, checkStatsFormat("yaml"), `invalid --stats-format "yaml" (expected text or json)`)
+	})
+}
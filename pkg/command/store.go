@@ -0,0 +1,122 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cyberark/summon/pkg/localstore"
+	"github.com/urfave/cli"
+)
+
+var storeFlag = cli.StringFlag{
+	Name:   "s, store",
+	Usage:  "Path to the local store",
+	EnvVar: "SUMMON_STORE",
+}
+
+var storeCommand = cli.Command{
+	Name:  "store",
+	Usage: "Manage the local encrypted store read by the builtin:localstore provider",
+	Description: "The passphrase of the store is read from " + localstore.PassphraseEnvVar + ".\n" +
+		"   Use `summon -p builtin:localstore:PATH` to fetch secrets from the store.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "set",
+			Usage:     "Store a secret, read from stdin when VALUE is omitted",
+			ArgsUsage: "PATH [VALUE]",
+			Flags:     []cli.Flag{storeFlag},
+			Action: func(c *cli.Context) error {
+				if c.NArg() < 1 || c.NArg() > 2 {
+					return cli.NewExitError("Usage: summon tool store set PATH [VALUE]", 1)
+				}
+				value, hasValue := c.Args().Get(1), c.NArg() == 2
+				return runStoreSet(c.String("store"), c.Args().First(), value, hasValue, os.Stdin)
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Print a secret",
+			ArgsUsage: "PATH",
+			Flags:     []cli.Flag{storeFlag},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.NewExitError("Usage: summon tool store get PATH", 1)
+				}
+				return runStoreGet(c.String("store"), c.Args().First(), c.App.Writer)
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "Remove a secret",
+			ArgsUsage: "PATH",
+			Flags:     []cli.Flag{storeFlag},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.NewExitError("Usage: summon tool store rm PATH", 1)
+				}
+				return runStoreRm(c.String("store"), c.Args().First())
+			},
+		},
+	},
+}
+
+// openStore opens the local store at storePath with the passphrase of
+// SUMMON_STORE_PASSPHRASE.
+func openStore(storePath string, mustExist bool) (*localstore.Store, error) {
+	if storePath == "" {
+		return nil, fmt.Errorf("no local store given: use --store or SUMMON_STORE")
+	}
+	passphrase, err := localstore.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return localstore.Open(storePath, passphrase, mustExist)
+}
+
+// runStoreSet stores a secret at path in the store, creating the store if
+// needed. Without a value, the value is read from in, so that it stays out
+// of process listings and shell history; a single trailing newline is
+// dropped.
+func runStoreSet(storePath, path, value string, hasValue bool, in io.Reader) error {
+	store, err := openStore(storePath, false)
+	if err != nil {
+		return err
+	}
+	if !hasValue {
+		content, err := io.ReadAll(in)
+		if err != nil {
+			return fmt.Errorf("Unable to read the value of %s: %w", path, err)
+		}
+		value = strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r")
+	}
+	store.Set(path, value)
+	return store.Save()
+}
+
+// runStoreGet prints the secret at path in the store.
+func runStoreGet(storePath, path string, out io.Writer) error {
+	store, err := openStore(storePath, true)
+	if err != nil {
+		return err
+	}
+	value, err := store.Get(path)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, value)
+	return err
+}
+
+// runStoreRm removes the secret at path from the store.
+func runStoreRm(storePath, path string) error {
+	store, err := openStore(storePath, true)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(path); err != nil {
+		return err
+	}
+	return store.Save()
+}
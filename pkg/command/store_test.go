@@ -0,0 +1,35 @@
+package command
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyberark/summon/pkg/localstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreCommands(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "store.json")
+	t.Setenv(localstore.PassphraseEnvVar, "pw")
+
+	require.NoError(t, runStoreSet(store, "db/password", "hunter2", true, nil))
+	require.NoError(t, runStoreSet(store, "api/token", "", false, strings.NewReader("tok\n")))
+
+	var out bytes.Buffer
+	require.NoError(t, runStoreGet(store, "db/password", &out))
+	require.NoError(t, runStoreGet(store, "api/token", &out))
+	assert.Equal(t, "hunter2\ntok\n", out.String())
+
+	require.NoError(t, runStoreRm(store, "db/password"))
+	err := runStoreGet(store, "db/password", &out)
+	assert.EqualError(t, err, "no such secret db/password in local store "+store)
+
+	err = runStoreRm(filepath.Join(t.TempDir(), "missing.json"), "db/password")
+	assert.ErrorContains(t, err, "Unable to read local store")
+
+	err = runStoreGet("", "db/password", &out)
+	assert.EqualError(t, err, "no local store given: use --store or SUMMON_STORE")
+}
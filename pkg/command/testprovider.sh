@@ -1,8 +0,0 @@
-#!/usr/bin/env bash
-
-if [ "$1" == "error" ]; then
-	echo "erroring out!"
-	exit 1
-fi
-
-echo 'mysecret'
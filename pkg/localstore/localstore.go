@@ -0,0 +1,192 @@
+// Package localstore reads and writes local encrypted secret stores: a map
+// of secret paths to values, encrypted with a passphrase. Summon serves
+// them through the builtin:localstore provider, so that secrets.yml files
+// written for a vault also work on laptops and in CI without one.
+package localstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/cyberark/summon/pkg/atomicwriter"
+)
+
+// PassphraseEnvVar holds the passphrase of local stores.
+const PassphraseEnvVar = "SUMMON_STORE_PASSPHRASE"
+
+const (
+	formatVersion = 1
+	kdfName       = "pbkdf2-sha256"
+	keyLength     = 32 // AES-256
+	saltLength    = 16
+)
+
+// kdfIterations is the PBKDF2 iteration count of newly saved stores. Stores
+// record the count they were saved with.
+var kdfIterations = 600000
+
+// ErrNotFound is returned by Get and Delete for paths the store has no
+// secret for.
+var ErrNotFound = errors.New("no such secret")
+
+// Store is a decrypted local store. Changes are only written by Save.
+type Store struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+// envelope is the content of a store file. Only Ciphertext is secret: the
+// JSON encoded map of paths to values, encrypted with AES-GCM using a key
+// derived from the passphrase.
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Passphrase returns the passphrase set in SUMMON_STORE_PASSPHRASE.
+func Passphrase() (string, error) {
+	passphrase := os.Getenv(PassphraseEnvVar)
+	if passphrase == "" {
+		return "", fmt.Errorf("%s is not set: it must hold the passphrase of the local store", PassphraseEnvVar)
+	}
+	return passphrase, nil
+}
+
+// Open decrypts the store at path. A store that doesn't exist yet is empty,
+// unless mustExist is set.
+func Open(path, passphrase string, mustExist bool) (*Store, error) {
+	s := &Store{path: path, passphrase: passphrase, secrets: map[string]string{}}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !mustExist {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read local store: %w", err)
+	}
+
+	var e envelope
+	if err := json.Unmarshal(content, &e); err != nil {
+		return nil, fmt.Errorf("Unable to read local store %s: %w", path, err)
+	}
+	if e.Version != formatVersion || e.KDF != kdfName {
+		return nil, fmt.Errorf("Unable to read local store %s: unsupported version %d (%s)", path, e.Version, e.KDF)
+	}
+
+	aead, err := newAEAD(passphrase, e.Salt, e.Iterations)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read local store %s: %w", path, err)
+	}
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt local store %s: wrong passphrase or corrupted file", path)
+	}
+	defer clear(plaintext)
+
+	if err := json.Unmarshal(plaintext, &s.secrets); err != nil {
+		return nil, fmt.Errorf("Unable to read local store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the secret at path.
+func (s *Store) Get(path string) (string, error) {
+	value, ok := s.secrets[path]
+	if !ok {
+		return "", fmt.Errorf("%w %s in local store %s", ErrNotFound, path, s.path)
+	}
+	return value, nil
+}
+
+// Set stores value at path, replacing any previous value.
+func (s *Store) Set(path, value string) {
+	s.secrets[path] = value
+}
+
+// Delete removes the secret at path.
+func (s *Store) Delete(path string) error {
+	if _, ok := s.secrets[path]; !ok {
+		return fmt.Errorf("%w %s in local store %s", ErrNotFound, path, s.path)
+	}
+	delete(s.secrets, path)
+	return nil
+}
+
+// Paths returns the paths of the secrets in the store, sorted.
+func (s *Store) Paths() []string {
+	return slices.Sorted(maps.Keys(s.secrets))
+}
+
+// Save encrypts the store with a fresh salt and nonce and writes it
+// atomically, readable by its owner only.
+func (s *Store) Save() error {
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+	defer clear(plaintext)
+
+	e := envelope{
+		Version:    formatVersion,
+		KDF:        kdfName,
+		Iterations: kdfIterations,
+		Salt:       make([]byte, saltLength),
+	}
+	rand.Read(e.Salt)
+
+	aead, err := newAEAD(s.passphrase, e.Salt, e.Iterations)
+	if err != nil {
+		return err
+	}
+	e.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(e.Nonce)
+	e.Ciphertext = aead.Seal(nil, e.Nonce, plaintext, nil)
+
+	content, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w := atomicwriter.NewAtomicWriter(s.path, 0o600)
+	if _, err := w.Write(append(content, '\n')); err != nil {
+		w.Close()
+		return fmt.Errorf("Unable to write local store %s: %w", s.path, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Unable to write local store %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// newAEAD returns the AES-GCM cipher keyed with the passphrase.
+func newAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if len(salt) == 0 || iterations <= 0 {
+		return nil, errors.New("invalid key derivation parameters")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
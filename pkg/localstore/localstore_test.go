@@ -0,0 +1,91 @@
+package localstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Keep key derivation fast in tests
+	kdfIterations = 1000
+	os.Exit(m.Run())
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	t.Run("a missing store is empty unless it must exist", func(t *testing.T) {
+		store, err := Open(path, "pw", false)
+		require.NoError(t, err)
+		assert.Empty(t, store.Paths())
+
+		_, err = Open(path, "pw", true)
+		assert.ErrorContains(t, err, "Unable to read local store")
+	})
+
+	t.Run("saves encrypted secrets", func(t *testing.T) {
+		store, err := Open(path, "pw", false)
+		require.NoError(t, err)
+		store.Set("db/password", "hunter2")
+		store.Set("api/token", "tok")
+		require.NoError(t, store.Save())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "hunter2")
+		assert.NotContains(t, string(content), "db/password")
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		store, err = Open(path, "pw", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"api/token", "db/password"}, store.Paths())
+		value, err := store.Get("db/password")
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", value)
+	})
+
+	t.Run("deletes secrets", func(t *testing.T) {
+		store, err := Open(path, "pw", true)
+		require.NoError(t, err)
+		require.NoError(t, store.Delete("api/token"))
+		require.NoError(t, store.Save())
+
+		store, err = Open(path, "pw", true)
+		require.NoError(t, err)
+		_, err = store.Get("api/token")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.EqualError(t, err, "no such secret api/token in local store "+path)
+		assert.ErrorIs(t, store.Delete("api/token"), ErrNotFound)
+	})
+
+	t.Run("rejects a wrong passphrase", func(t *testing.T) {
+		_, err := Open(path, "wrong", true)
+		assert.EqualError(t, err, "Unable to decrypt local store "+path+": wrong passphrase or corrupted file")
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "other.json")
+		require.NoError(t, os.WriteFile(other, []byte(`{"version": 2, "kdf": "argon2id"}`), 0o600))
+
+		_, err := Open(other, "pw", true)
+		assert.EqualError(t, err, "Unable to read local store "+other+": unsupported version 2 (argon2id)")
+	})
+}
+
+func TestPassphrase(t *testing.T) {
+	t.Setenv(PassphraseEnvVar, "")
+	_, err := Passphrase()
+	assert.EqualError(t, err, "SUMMON_STORE_PASSPHRASE is not set: it must hold the passphrase of the local store")
+
+	t.Setenv(PassphraseEnvVar, "pw")
+	passphrase, err := Passphrase()
+	require.NoError(t, err)
+	assert.Equal(t, "pw", passphrase)
+}
@@ -0,0 +1,51 @@
+package localstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/cyberark/summon/pkg/provider"
+)
+
+func init() {
+	provider.Register("localstore", NewProvider)
+}
+
+// NewProvider returns a provider reading secrets from the store at path,
+// registered as builtin:localstore:PATH. The passphrase of the store is
+// read from SUMMON_STORE_PASSPHRASE when fetching.
+func NewProvider(path string) (provider.Provider, error) {
+	if path == "" {
+		return nil, errors.New("the path of the store is missing, e.g. builtin:localstore:/path/to/store")
+	}
+	return &storeProvider{path: path}, nil
+}
+
+// storeProvider fetches secrets from a local store.
+type storeProvider struct {
+	path string
+}
+
+func (p *storeProvider) Name() string {
+	return provider.BuiltinPrefix + "localstore:" + p.path
+}
+
+func (p *storeProvider) Fetch(ctx context.Context, requests []provider.Request) ([]provider.Result, error) {
+	passphrase, err := Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	store, err := Open(p.path, passphrase, true)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]provider.Result, 0, len(requests))
+	for _, request := range requests {
+		slog.Debug("Fetching secret", "name", request.Key, "provider", p.Name())
+		value, err := store.Get(request.Path)
+		results = append(results, provider.Result{Key: request.Key, Value: value, Error: err})
+	}
+	return results, nil
+}
@@ -0,0 +1,46 @@
+package localstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/summon/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	t.Setenv(PassphraseEnvVar, "pw")
+	store, err := Open(path, "pw", false)
+	require.NoError(t, err)
+	store.Set("db/password", "s3cr3t")
+	require.NoError(t, store.Save())
+
+	p, err := provider.New("builtin:localstore:" + path)
+	require.NoError(t, err)
+	assert.Equal(t, "builtin:localstore:"+path, p.Name())
+
+	t.Run("reports missing secrets per key", func(t *testing.T) {
+		results, err := p.Fetch(context.Background(), []provider.Request{
+			{Key: "DB_PASS", Path: "db/password"},
+			{Key: "API_TOKEN", Path: "api/token"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, provider.Result{Key: "DB_PASS", Value: "s3cr3t"}, results[0])
+		assert.ErrorIs(t, results[1].Error, ErrNotFound)
+	})
+
+	t.Run("fails without the passphrase", func(t *testing.T) {
+		t.Setenv(PassphraseEnvVar, "")
+		_, err := p.Fetch(context.Background(), []provider.Request{{Key: "DB_PASS", Path: "db/password"}})
+		assert.ErrorContains(t, err, "SUMMON_STORE_PASSPHRASE is not set")
+	})
+
+	t.Run("needs the path of the store", func(t *testing.T) {
+		_, err := provider.New("builtin:localstore")
+		assert.EqualError(t, err, `builtin provider "builtin:localstore": the path of the store is missing, e.g. builtin:localstore:/path/to/store`)
+	})
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// NewChain returns a Provider fetching secrets from providers in order: the
+// secrets a provider fails to fetch, or all of them when it can't be used at
+// all, are fetched from the next one. Secrets no provider fetches fail with
+// the errors of every provider. A provider that doesn't match its pinned
+// checksum (see ErrChecksumMismatch) fails the whole fetch instead of being
+// skipped.
+func NewChain(providers ...Provider) Provider {
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	return &chainProvider{providers: providers}
+}
+
+// chainProvider fetches secrets from the first of its providers that has
+// them, see NewChain.
+type chainProvider struct {
+	providers []Provider
+}
+
+func (c *chainProvider) Name() string {
+	names := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (c *chainProvider) Fetch(ctx context.Context, requests []Request) ([]Result, error) {
+	var results []Result
+	// Errors of the secrets left to fetch, by key
+	failures := make(map[string]chainError, len(requests))
+	var providerErrs chainError
+
+	remaining := requests
+	for i, provider := range c.providers {
+		if len(remaining) == 0 {
+			break
+		}
+		fetchCtx := ctx
+		if i > 0 {
+			slog.Debug("Falling back to the next provider", "provider", provider.Name(), "count", len(remaining))
+			fetchCtx = context.WithValue(ctx, chainFallbackKey{}, true)
+		}
+
+		fetched, err := provider.Fetch(fetchCtx, remaining)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			if errors.Is(err, ErrChecksumMismatch) {
+				return nil, err
+			}
+			slog.Debug("Unable to fetch secrets", "provider", provider.Name(), "error", err)
+			err = fmt.Errorf("%s: %w", provider.Name(), err)
+			providerErrs = append(providerErrs, err)
+			for _, request := range remaining {
+				failures[request.Key] = append(failures[request.Key], err)
+			}
+			continue
+		}
+
+		byKey := make(map[string]Result, len(fetched))
+		for _, result := range fetched {
+			byKey[result.Key] = result
+		}
+		var failed []Request
+		for _, request := range remaining {
+			result, ok := byKey[request.Key]
+			switch {
+			case !ok:
+				result.Error = errors.New("no value returned")
+			case result.Error == nil:
+				slog.Debug("Fetched secret", "name", request.Key, "provider", provider.Name())
+				results = append(results, result)
+				continue
+			}
+			failures[request.Key] = append(failures[request.Key], fmt.Errorf("%s: %w", provider.Name(), result.Error))
+			failed = append(failed, request)
+		}
+		remaining = failed
+	}
+
+	if len(providerErrs) == len(c.providers) {
+		return nil, providerErrs
+	}
+	for _, request := range remaining {
+		results = append(results, Result{Key: request.Key, Value: "", Error: failures[request.Key]})
+	}
+	return results, nil
+}
+
+// chainError reports the errors of each provider of a chain, in order.
+type chainError []error
+
+func (e chainError) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e chainError) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapProvider answers the requests for the paths it has values for, fails
+// the others, and records the keys it is asked for.
+type mapProvider struct {
+	name   string
+	values map[string]string
+	err    error // Returned by Fetch when set
+	asked  []string
+}
+
+func (p *mapProvider) Name() string {
+	return p.name
+}
+
+func (p *mapProvider) Fetch(_ context.Context, requests []Request) ([]Result, error) {
+	for _, request := range requests {
+		p.asked = append(p.asked, request.Key)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	results := make([]Result, 0, len(requests))
+	for _, request := range requests {
+		if value, ok := p.values[request.Path]; ok {
+			results = append(results, Result{Key: request.Key, Value: value})
+		} else {
+			results = append(results, Result{Key: request.Key, Error: errors.New("not found")})
+		}
+	}
+	return results, nil
+}
+
+func TestChain(t *testing.T) {
+	requests := []Request{{Key: "A", Path: "a"}, {Key: "B", Path: "b"}, {Key: "C", Path: "c"}}
+
+	t.Run("fetches the secrets a provider fails from the next one", func(t *testing.T) {
+		primary := &mapProvider{name: "new", values: map[string]string{"a": "new a"}}
+		secondary := &mapProvider{name: "legacy", values: map[string]string{"a": "legacy a", "b": "legacy b"}}
+		chain := NewChain(primary, secondary)
+		assert.Equal(t, "new, legacy", chain.Name())
+
+		results, err := chain.Fetch(context.Background(), requests)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, Result{Key: "A", Value: "new a"}, results[0])
+		assert.Equal(t, Result{Key: "B", Value: "legacy b"}, results[1])
+		assert.Equal(t, "C", results[2].Key)
+		assert.EqualError(t, results[2].Error, "new: not found; legacy: not found")
+		assert.Equal(t, []string{"A", "B", "C"}, primary.asked)
+		assert.Equal(t, []string{"B", "C"}, secondary.asked)
+	})
+
+	t.Run("fetches everything from the next provider when one can't be used", func(t *testing.T) {
+		unreachable := errors.New("connection refused")
+		primary := &mapProvider{name: "new", err: unreachable}
+		secondary := &mapProvider{name: "legacy", values: map[string]string{"a": "legacy a", "b": "legacy b"}}
+
+		results, err := NewChain(primary, secondary).Fetch(context.Background(), requests)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, Result{Key: "A", Value: "legacy a"}, results[0])
+		assert.Equal(t, Result{Key: "B", Value: "legacy b"}, results[1])
+		assert.EqualError(t, results[2].Error, "new: connection refused; legacy: not found")
+		assert.ErrorIs(t, results[2].Error, unreachable)
+	})
+
+	t.Run("fails when no provider can be used", func(t *testing.T) {
+		primary := &mapProvider{name: "new", err: errors.New("connection refused")}
+		secondary := &mapProvider{name: "legacy", err: errors.New("timeout")}
+
+		_, err := NewChain(primary, secondary).Fetch(context.Background(), requests)
+		assert.EqualError(t, err, "new: connection refused; legacy: timeout")
+	})
+
+	t.Run("doesn't fall back from a provider with a checksum mismatch", func(t *testing.T) {
+		primary := &mapProvider{name: "new", err: ErrChecksumMismatch}
+		secondary := &mapProvider{name: "legacy", values: map[string]string{"a": "legacy a"}}
+
+		_, err := NewChain(primary, secondary).Fetch(context.Background(), requests)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+		assert.Empty(t, secondary.asked)
+	})
+
+	t.Run("returns a single provider as is", func(t *testing.T) {
+		provider := &mapProvider{name: "new"}
+		assert.Same(t, provider, NewChain(provider))
+	})
+}
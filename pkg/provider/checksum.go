@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is wrapped by the errors reported when a provider
+// executable doesn't match its pinned checksum, see ExecOptions.SHA256.
+var ErrChecksumMismatch = errors.New("provider checksum mismatch")
+
+// Checksum returns the hex encoded SHA-256 checksum of the file at path, as
+// pinned with ExecOptions.SHA256.
+func Checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyChecksum checks that the executable at path has the expected
+// checksum.
+func verifyChecksum(path, expected string) error {
+	actual, err := Checksum(path)
+	if err != nil {
+		return fmt.Errorf("Unable to verify the checksum of provider %s: %w", path, err)
+	}
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: %s has sha256 %s, but %s is pinned (run `summon tool providers pin` if it was upgraded on purpose)",
+			ErrChecksumMismatch, path, actual, expected)
+	}
+	return nil
+}
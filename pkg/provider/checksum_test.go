@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "provider")
+	require.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o755))
+
+	sum, err := Checksum(path)
+	require.NoError(t, err)
+	assert.Equal(t, "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", sum)
+
+	assert.NoError(t, verifyChecksum(path, "5891B5B522D5DF086D0FF0B110FBD9D21BB4FC7163AF34D08286A2E846F6BE03"))
+	err = verifyChecksum(path, "0000")
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.EqualError(t, err, "provider checksum mismatch: "+path+" has sha256 "+sum+
+		", but 0000 is pinned (run `summon tool providers pin` if it was upgraded on purpose)")
+
+	_, err = Checksum(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Inherit decides which of summon's environment variables provider
+// processes inherit.
+type Inherit string
+
+const (
+	// InheritAll, the default, passes summon's whole environment on.
+	InheritAll Inherit = "all"
+	// InheritNone starts providers with an empty environment.
+	InheritNone Inherit = "none"
+	// InheritAllowlist passes on the variables matching EnvPolicy.Allow.
+	InheritAllowlist Inherit = "allowlist"
+)
+
+// EnvPolicy describes the environment of provider processes: the variables
+// inherited from summon, and the ones added or overridden. The variables
+// summon sets to talk to providers, like InteractiveProtocolEnvVar, are
+// always set.
+type EnvPolicy struct {
+	Inherit Inherit
+
+	// Allow holds the names of the variables inherited with
+	// InheritAllowlist, or patterns like CONJUR_* (see filepath.Match).
+	Allow []string
+
+	// Env holds variables to set, by name.
+	Env map[string]string
+}
+
+// environ returns the environment of a provider process for summon's
+// environment, in the form of os.Environ. It is never nil, since a nil
+// exec.Cmd.Env inherits everything.
+func (p EnvPolicy) environ(environ []string) []string {
+	env := []string{}
+	switch p.Inherit {
+	case InheritNone:
+	case InheritAllowlist:
+		for _, kv := range environ {
+			name, _, _ := strings.Cut(kv, "=")
+			if p.allows(name) {
+				env = append(env, kv)
+			}
+		}
+	default:
+		env = slices.Clone(environ)
+	}
+	for _, name := range slices.Sorted(maps.Keys(p.Env)) {
+		env = append(env, name+"="+p.Env[name])
+	}
+	return env
+}
+
+// allows tells whether the variable called name matches Allow.
+func (p EnvPolicy) allows(name string) bool {
+	for _, pattern := range p.Allow {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvPolicyEnviron(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/home/me", "CONJUR_ACCOUNT=acme", "CONJUR_AUTHN_API_KEY=key", "AWS_SECRET_ACCESS_KEY=aws"}
+
+	tests := []struct {
+		name     string
+		policy   EnvPolicy
+		expected []string
+	}{
+		{"inherits everything by default", EnvPolicy{}, environ},
+		{"inherits everything", EnvPolicy{Inherit: InheritAll, Env: map[string]string{"HOME": "/tmp"}},
+			append(environ, "HOME=/tmp")},
+		{"inherits nothing", EnvPolicy{Inherit: InheritNone}, []string{}},
+		{"inherits nothing but additions", EnvPolicy{Inherit: InheritNone, Env: map[string]string{"B": "2", "A": "1"}},
+			[]string{"A=1", "B=2"}},
+		{"inherits allowed variables", EnvPolicy{Inherit: InheritAllowlist, Allow: []string{"PATH", "CONJUR_*"}, Env: map[string]string{"A": "1"}},
+			[]string{"PATH=/bin", "CONJUR_ACCOUNT=acme", "CONJUR_AUTHN_API_KEY=key", "A=1"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.policy.environ(environ))
+		})
+	}
+}
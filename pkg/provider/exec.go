@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberark/summon/pkg/secretsyml"
+)
+
+// Provider fetches secret values. Implementations may run an external
+// program, like the providers located by Resolve (see NewExec), or fetch
+// secrets in-process (see Register).
+type Provider interface {
+	// Name identifies the provider in logs and error messages.
+	Name() string
+
+	// Fetch returns a result for each request, in any order. A failure to
+	// fetch a single secret is reported in the Error of its result; an error
+	// is returned only when the provider can't be used at all.
+	Fetch(ctx context.Context, requests []Request) ([]Result, error)
+}
+
+// NewExec returns a Provider that runs the provider executable at path, such
+// as one returned by Resolve. All secrets are fetched from a single process
+// in interactive mode when the provider supports it, and with one process
+// per secret otherwise.
+func NewExec(path string) Provider {
+	return NewExecWithOptions(path, ExecOptions{})
+}
+
+// ExecOptions configure how NewExecWithOptions runs a provider executable.
+type ExecOptions struct {
+	// Protocol is the version of the interactive protocol the provider
+	// speaks: 1, the default, or 2, which adds request IDs so that the
+	// provider can answer in any order. See InteractiveProtocolEnvVar.
+	Protocol int
+
+	// Stderr decides whether output on stderr fails a fetch, StderrFallback
+	// by default. Lines that don't are logged with slog once the secrets are
+	// fetched, with the fetched values redacted: at debug level, or with
+	// StderrLog at the level they start with (e.g. "ERROR:", warning by
+	// default).
+	Stderr StderrPolicy
+
+	// Env describes the environment of the provider processes, by default
+	// summon's whole environment.
+	Env EnvPolicy
+
+	// SHA256 is the hex encoded checksum the provider executable must have,
+	// verified before each run (see Checksum). Empty means any executable
+	// is run. The file is read again to be run, so an executable replaced
+	// between the check and the run isn't caught: the pin protects against
+	// unexpected providers, not against whoever can write to their
+	// directory while summon runs.
+	SHA256 string
+}
+
+// NewExecWithOptions is NewExec for a provider configured by opts.
+func NewExecWithOptions(path string, opts ExecOptions) Provider {
+	return &execProvider{path: path, opts: opts}
+}
+
+// execProvider fetches secrets by running a provider executable.
+type execProvider struct {
+	path string
+	opts ExecOptions
+}
+
+func (p *execProvider) Name() string {
+	return p.path
+}
+
+func (p *execProvider) Fetch(ctx context.Context, requests []Request) ([]Result, error) {
+	secrets := make(secretsyml.SecretsMap, len(requests))
+	for _, request := range requests {
+		secrets[request.Key] = secretsyml.SecretSpec{
+			Path: request.Path,
+			Tags: []secretsyml.YamlTag{secretsyml.Var},
+		}
+	}
+
+	start, received := time.Now(), newLatencies()
+	fetch := FetchStats{Provider: p.path, Protocol: "interactive"}
+	if p.opts.Protocol == 2 {
+		fetch.Protocol = "interactive-v2"
+	}
+
+	stderr := &stderrLog{provider: p.path, levels: p.opts.Stderr == StderrLog}
+	// Unless the session ends on stderr output, it's collected until all the
+	// values it could leak are known
+	var onStderr func(string)
+	if p.opts.Stderr == StderrLog || p.opts.Stderr == StderrFail {
+		onStderr = stderr.add
+	}
+	resultsCh, errorsCh, cleanup := callInteractiveMode(ctx, p.path, secrets, p.opts, onStderr)
+	results, err := collectResults(ctx, resultsCh, errorsCh, received.received)
+	cleanup()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	var stderrErr *stderrError
+	switch {
+	case errors.Is(err, ErrProtocolViolation), errors.Is(err, ErrChecksumMismatch):
+		return nil, err
+	case p.opts.Stderr == StderrFail && len(stderr.lines) > 0:
+		return nil, fmt.Errorf("provider %s wrote to stderr: %s", p.path, redact(stderr.lines[0], results))
+	case err != nil:
+		if errors.As(err, &stderrErr) {
+			stderr.add(stderrErr.line)
+			err = errors.New("provider wrote to stderr")
+		}
+		slog.Debug("Falling back to non-interactive mode", "provider", p.path, "error", err)
+		fetch.Protocol, fetch.SingleFallback = "single", err.Error()
+		received.restart()
+		results = p.fetchEach(ctx, requests, stderr, received)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+	}
+
+	stderr.flush(ctx, results)
+	for i, result := range results {
+		if errors.As(result.Error, &stderrErr) {
+			results[i].Error = fmt.Errorf("provider wrote to stderr: %s", redact(stderrErr.line, results))
+		}
+	}
+	statsFrom(ctx).recordFetch(ctx, fetch, start, results, received.byKey)
+	return results, nil
+}
+
+// collectResults gathers the results of an interactive mode call, until the
+// results channel is closed or an error is reported. The results gathered
+// before an error are returned with it. received, when not nil, is called
+// with the key of each result as it arrives.
+func collectResults(ctx context.Context, resultsCh chan Result, errorsCh chan error, received func(key string)) (results []Result, err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result, ok := <-resultsCh:
+			if !ok {
+				return results, nil
+			}
+
+			if received != nil {
+				received(result.Key)
+			}
+			results = append(results, result)
+
+		// Fallback to the old implementation if either provider doesn't support interactive mode or an error occured
+		case err = <-errorsCh:
+			return results, err
+		}
+	}
+}
+
+// fetchEach fetches the requested secrets concurrently, calling the provider
+// once per secret. What the calls write to stderr is added to stderr, or
+// fails the secret with the StderrFail policy. The end of each call is
+// recorded in received.
+func (p *execProvider) fetchEach(ctx context.Context, requests []Request, stderr *stderrLog, received *latencies) []Result {
+	results := make(chan Result, len(requests))
+	var wg sync.WaitGroup
+
+	for _, request := range requests {
+		wg.Add(1)
+		go func(request Request) {
+			defer wg.Done()
+
+			slog.Debug("Fetching secret", "name", request.Key)
+			value, output, err := call(ctx, p.path, p.opts, request.Path)
+			received.received(request.Key)
+			if err != nil {
+				results <- Result{Key: request.Key, Value: "", Error: err}
+				return
+			}
+			if output != "" && p.opts.Stderr == StderrFail {
+				line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+				line = redact(line, []Result{{Value: value}})
+				results <- Result{Key: request.Key, Value: "", Error: &stderrError{line: line}}
+				return
+			}
+			stderr.add(output)
+			results <- Result{Key: request.Key, Value: value, Error: nil}
+		}(request)
+	}
+	wg.Wait()
+	close(results)
+
+	resultsSlice := make([]Result, 0, len(requests))
+	for result := range results {
+		resultsSlice = append(resultsSlice, result)
+	}
+	return resultsSlice
+}
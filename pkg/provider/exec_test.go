@@ -0,0 +1,283 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Parts of the scripts of test providers answering db/password and db/user,
+// and failing db/broken.
+// writeTestProvider writes a provider executable running script, and
+// returns its path.
+func writeTestProvider(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "provider")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/bash\n"+script), 0o755))
+	return path
+}
+
+func TestExecProvider(t *testing.T) {
+	requests := []Request{
+		{Key: "PASSWORD", Path: "db/password"},
+		{Key: "USER", Path: "db/user"},
+	}
+	sortResults := func(results []Result) []Result {
+		slices.SortFunc(results, func(a, b Result) int { return strings.Compare(a.Key, b.Key) })
+		return results
+	}
+
+	for protocol, fixtures := range map[string]string{
+		"interactive": testFixtures,
+		"single":      "protocol: single\n" + testFixtures,
+	} {
+		t.Run("fetches secrets over the "+protocol+" protocol", func(t *testing.T) {
+			provider := newMockExec(t, fixtures, ExecOptions{})
+
+			results, err := provider.Fetch(context.Background(), requests)
+			require.NoError(t, err)
+			assert.Equal(t, []Result{
+				{Key: "PASSWORD", Value: "s3cr3t"},
+				{Key: "USER", Value: "admin"},
+			}, sortResults(results))
+		})
+	}
+
+	t.Run("reports per-secret errors in interactive mode", func(t *testing.T) {
+		provider := newMockExec(t, testFixtures, ExecOptions{})
+
+		results, err := provider.Fetch(context.Background(), append(requests, Request{Key: "BROKEN", Path: "db/broken"}))
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		results = sortResults(results)
+		assert.Equal(t, "BROKEN", results[0].Key)
+		assert.EqualError(t, results[0].Error, "permission denied")
+		assert.Equal(t, []Result{
+			{Key: "PASSWORD", Value: "s3cr3t"},
+			{Key: "USER", Value: "admin"},
+		}, results[1:])
+	})
+
+	t.Run("falls back to single calls when interactive mode fails", func(t *testing.T) {
+		// Like providers predating InteractiveErrorPrefix, the interactive
+		// session ends with the first failure on stderr
+		provider := NewExec(writeTestProvider(t, `lookup() {
+  case "$1" in
+    db/password) printf s3cr3t ;;
+    db/user) printf admin ;;
+    *) printf "permission denied"; return 1 ;;
+  esac
+}
+if [ $# -gt 0 ]; then
+  value=$(lookup "$1") || { echo "$value" >&2; exit 1; }
+  printf %s "$value"; exit
+fi
+while read -r path; do
+  value=$(lookup "$path") || { echo "$value" >&2; exit 1; }
+  printf %s "$value" | base64
+done
+`))
+
+		results, err := provider.Fetch(context.Background(), append(requests, Request{Key: "BROKEN", Path: "db/broken"}))
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		results = sortResults(results)
+		assert.Equal(t, "BROKEN", results[0].Key)
+		assert.EqualError(t, results[0].Error, "exit status 1: permission denied")
+		assert.Equal(t, []Result{
+			{Key: "PASSWORD", Value: "s3cr3t"},
+			{Key: "USER", Value: "admin"},
+		}, results[1:])
+	})
+
+	t.Run("matches out of order answers with protocol version 2", func(t *testing.T) {
+		// Requests are answered concurrently, db/user last
+		provider := newMockExec(t, `
+secrets:
+  db/password: s3cr3t
+  db/user: {value: admin, latency: 200ms}
+  db/broken: {error: permission denied}
+`, ExecOptions{Protocol: 2})
+
+		start := time.Now()
+		results, err := provider.Fetch(context.Background(), []Request{
+			{Key: "USER", Path: "db/user"},
+			{Key: "PASSWORD", Path: "db/password"},
+			{Key: "BROKEN", Path: "db/broken"},
+		})
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+		require.Len(t, results, 3)
+		assert.Equal(t, "USER", results[2].Key, "the slowest secret is answered last")
+		results = sortResults(results)
+		assert.EqualError(t, results[0].Error, "permission denied")
+		assert.Equal(t, []Result{
+			{Key: "PASSWORD", Value: "s3cr3t"},
+			{Key: "USER", Value: "admin"},
+		}, results[1:])
+	})
+
+	t.Run("reports protocol violations", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			script   string
+			expected string
+		}{
+			{"unknown request", `read -r id path; echo "7 $(printf x | base64)"`, `interactive protocol violation: answer for unknown or already answered request "7"`},
+			{"duplicate answer", `read -r id path; echo "$id eA=="; echo "$id eA=="`, `interactive protocol violation: answer for unknown or already answered request "1"`},
+			{"missing request ID", `read -r id path; echo "eA=="`, `interactive protocol violation: answer for unknown or already answered request "eA=="`},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				script := filepath.Join(t.TempDir(), "provider")
+				require.NoError(t, os.WriteFile(script, []byte("#!/bin/bash\n"+tc.script+"\nsleep 1\n"), 0o755))
+				provider := NewExecWithOptions(script, ExecOptions{Protocol: 2})
+
+				_, err := provider.Fetch(context.Background(), []Request{{Key: "A", Path: "a"}, {Key: "B", Path: "b"}})
+				assert.ErrorIs(t, err, ErrProtocolViolation)
+				assert.EqualError(t, err, tc.expected)
+			})
+		}
+	})
+
+	t.Run("tells providers the protocol version", func(t *testing.T) {
+		script := filepath.Join(t.TempDir(), "provider")
+		require.NoError(t, os.WriteFile(script, []byte("#!/bin/bash\nwhile read -r id path; do echo \"$id $(printf %s \"$"+InteractiveProtocolEnvVar+"\" | base64)\"; done\n"), 0o755))
+		provider := NewExecWithOptions(script, ExecOptions{Protocol: 2})
+
+		results, err := provider.Fetch(context.Background(), []Request{{Key: "A", Path: "a"}})
+		require.NoError(t, err)
+		assert.Equal(t, []Result{{Key: "A", Value: "2"}}, results)
+	})
+
+	t.Run("handles stderr output according to the policy", func(t *testing.T) {
+		// Answers in interactive mode, or a single call, warning with the value
+		script := filepath.Join(t.TempDir(), "provider")
+		require.NoError(t, os.WriteFile(script, []byte(`#!/bin/bash
+if [ $# -gt 0 ]; then echo "INFO: value of $1 is v-$1" >&2; echo "v-$1"; exit; fi
+while read -r path; do echo "warning: value of $path is v-$path" >&2; printf "v-$path" | base64; sleep 0.1; done
+`), 0o755))
+		requests := []Request{{Key: "A", Path: "a"}, {Key: "B", Path: "b"}}
+		var logs bytes.Buffer
+		previous := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+				if attr.Key == slog.TimeKey || attr.Key == "provider" {
+					return slog.Attr{}
+				}
+				return attr
+			},
+		})))
+		t.Cleanup(func() { slog.SetDefault(previous) })
+
+		t.Run("fallback", func(t *testing.T) {
+			logs.Reset()
+			results, err := NewExec(script).Fetch(context.Background(), requests)
+			require.NoError(t, err)
+			assert.Equal(t, []Result{{Key: "A", Value: "v-a"}, {Key: "B", Value: "v-b"}}, sortResults(results))
+			assert.Contains(t, logs.String(), `level=DEBUG msg="warning: value of a is REDACTED"`)
+			assert.Contains(t, logs.String(), `level=DEBUG msg="INFO: value of b is REDACTED"`)
+			assert.NotContains(t, logs.String(), "level=WARN")
+			assert.NotContains(t, logs.String(), "v-")
+		})
+
+		t.Run("log", func(t *testing.T) {
+			logs.Reset()
+			results, err := NewExecWithOptions(script, ExecOptions{Stderr: StderrLog}).Fetch(context.Background(), requests)
+			require.NoError(t, err)
+			assert.Equal(t, []Result{{Key: "A", Value: "v-a"}, {Key: "B", Value: "v-b"}}, sortResults(results))
+			assert.Contains(t, logs.String(), "level=WARN msg=\"warning: value of a is REDACTED\"\n"+
+				"level=WARN msg=\"warning: value of b is REDACTED\"\n")
+		})
+
+		t.Run("fail", func(t *testing.T) {
+			logs.Reset()
+			_, err := NewExecWithOptions(script, ExecOptions{Stderr: StderrFail}).Fetch(context.Background(), requests)
+			assert.EqualError(t, err, "provider "+script+" wrote to stderr: warning: value of a is REDACTED")
+			assert.NotContains(t, logs.String(), "REDACTED")
+		})
+	})
+
+	t.Run("runs providers in the environment of the policy", func(t *testing.T) {
+		t.Setenv("SUMMON_TEST_TOKEN", "token")
+		t.Setenv("SUMMON_TEST_ALLOWED", "allowed")
+		policy := EnvPolicy{Inherit: InheritAllowlist, Allow: []string{"PATH", "SUMMON_TEST_ALLOW*"}, Env: map[string]string{"ADDED": "added"}}
+		env := `"${SUMMON_TEST_TOKEN:-none},$SUMMON_TEST_ALLOWED,$ADDED"`
+
+		for name, script := range map[string]string{
+			"interactive": `while read -r path; do printf "%s" ` + env + ` | base64; done`,
+			"single":      `[ $# -gt 0 ] || exit 1; printf "%s" ` + env,
+		} {
+			t.Run(name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "provider")
+				require.NoError(t, os.WriteFile(path, []byte("#!/bin/bash\n"+script+"\n"), 0o755))
+
+				results, err := NewExecWithOptions(path, ExecOptions{Env: policy}).Fetch(context.Background(), []Request{{Key: "A", Path: "a"}})
+				require.NoError(t, err)
+				assert.Equal(t, []Result{{Key: "A", Value: "none,allowed,added"}}, results)
+			})
+		}
+	})
+
+	t.Run("verifies pinned checksums before running providers", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "provider")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/bash\n[ $# -gt 0 ] || exit 1; printf x\n"), 0o755))
+		sum, err := Checksum(path)
+		require.NoError(t, err)
+
+		results, err := NewExecWithOptions(path, ExecOptions{SHA256: sum}).Fetch(context.Background(), []Request{{Key: "A", Path: "a"}})
+		require.NoError(t, err)
+		assert.Equal(t, []Result{{Key: "A", Value: "x"}}, results)
+
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/bash\nprintf evil\n"), 0o755))
+		_, err = NewExecWithOptions(path, ExecOptions{SHA256: sum}).Fetch(context.Background(), []Request{{Key: "A", Path: "a"}})
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		provider := newMockExec(t, testFixtures, ExecOptions{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := provider.Fetch(ctx, requests)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCollectResults(t *testing.T) {
+	t.Run("Returns results when provider returns results", func(t *testing.T) {
+		resultsCh := make(chan Result)
+		errorsCh := make(chan error, 1)
+
+		go func() {
+			resultsCh <- Result{Key: "SERVICE_KEY", Value: "secretvalue", Error: nil}
+			close(resultsCh)
+		}()
+
+		results, err := collectResults(context.Background(), resultsCh, errorsCh, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{Key: "SERVICE_KEY", Value: "secretvalue"}}, results)
+	})
+
+	t.Run("Returns error when provider cannot handle interactive mode", func(t *testing.T) {
+		resultsCh := make(chan Result, 1)
+		errorsCh := make(chan error, 1)
+
+		errorsCh <- ErrInteractiveModeNotSupported
+
+		results, err := collectResults(context.Background(), resultsCh, errorsCh, nil)
+
+		assert.Equal(t, ErrInteractiveModeNotSupported, err)
+		assert.Nil(t, results)
+	})
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Info describes a provider, as returned by Inspect.
+type Info struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`             // As returned by Resolve
+	Mode   string `json:"mode,omitempty"`   // Permissions of the executable, e.g. -rwxr-xr-x
+	SHA256 string `json:"sha256,omitempty"` // Checksum of the executable, see Checksum
+	Pinned bool   `json:"pinned"`           // Whether the checksum matches the one pinned
+
+	// Version is the output of the provider's --version flag, empty when
+	// it doesn't support it.
+	Version string `json:"version,omitempty"`
+
+	// Protocols are the provider protocols summon can use with the
+	// provider: builtin, for the providers compiled into summon, or single,
+	// interactive and interactive-v2, see ExecOptions.Protocol.
+	Protocols []string `json:"protocols"`
+
+	// Error tells why the provider couldn't be inspected fully, e.g. when
+	// it doesn't match its pinned checksum.
+	Error string `json:"error,omitempty"`
+}
+
+// Inspect describes the provider at path, a name returned by Resolve,
+// configured by opts. Executables are run with --version for their version,
+// and in interactive mode with no secrets to request to find out whether
+// they support it: providers exiting successfully without output do.
+func Inspect(ctx context.Context, path string, opts ExecOptions) Info {
+	info := Info{Name: filepath.Base(path), Path: path}
+	if strings.HasPrefix(path, BuiltinPrefix) {
+		info.Name = path
+		info.Protocols = []string{"builtin"}
+		return info
+	}
+
+	stat, err := os.Stat(path)
+	if err == nil {
+		info.Mode = stat.Mode().String()
+		info.SHA256, err = Checksum(path)
+	}
+	if err == nil && opts.SHA256 != "" {
+		err = verifyChecksum(path, opts.SHA256)
+		info.Pinned = err == nil
+	}
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	if cmd, err := command(ctx, path, opts, nil, "--version"); err == nil {
+		if version, err := cmd.Output(); err == nil {
+			info.Version = strings.TrimSpace(string(version))
+		}
+	}
+
+	info.Protocols = []string{"single"}
+	var env []string
+	if opts.Protocol == 2 {
+		env = append(env, InteractiveProtocolEnvVar+"=2")
+	}
+	if cmd, err := command(ctx, path, opts, env); err == nil {
+		var output bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &output, &output
+		if cmd.Run() == nil && output.Len() == 0 {
+			protocol := "interactive"
+			if opts.Protocol == 2 {
+				protocol = "interactive-v2"
+			}
+			info.Protocols = append(info.Protocols, protocol)
+		}
+	}
+	return info
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ProviderPathEnvVar, dir)
+
+	paths := SearchPaths()
+	require.Len(t, paths, 4)
+	assert.Equal(t, SearchPath{Source: ProviderPathEnvVar, Path: dir, Exists: true}, paths[0])
+	assert.Equal(t, []string{"system", "portable", "homebrew"}, []string{paths[1].Source, paths[2].Source, paths[3].Source})
+
+	t.Setenv(ProviderPathEnvVar, filepath.Join(dir, "missing"))
+	defaultPath, err := GetDefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "missing"), defaultPath, "SUMMON_PROVIDER_PATH is used even when it doesn't exist")
+}
+
+func TestInspect(t *testing.T) {
+	writeProvider := func(t *testing.T, script string) string {
+		path := filepath.Join(t.TempDir(), "summon-test")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/bash\n"+script+"\n"), 0o755))
+		return path
+	}
+	interactive := writeProvider(t, `[ "$1" == "--version" ] && { echo 1.2.3; exit; }
+[ $# -eq 0 ] && { while read -r path; do echo eA==; done; exit; }
+printf x`)
+	single := writeProvider(t, `[ "$1" == "--version" ] && exit 1
+[ $# -eq 0 ] && { echo "usage: summon-test PATH" >&2; exit 1; }
+printf x`)
+
+	t.Run("describes providers supporting interactive mode", func(t *testing.T) {
+		sum, err := Checksum(interactive)
+		require.NoError(t, err)
+
+		info := Inspect(context.Background(), interactive, ExecOptions{SHA256: sum})
+		assert.Equal(t, Info{
+			Name:      "summon-test",
+			Path:      interactive,
+			Mode:      "-rwxr-xr-x",
+			SHA256:    sum,
+			Pinned:    true,
+			Version:   "1.2.3",
+			Protocols: []string{"single", "interactive"},
+		}, info)
+
+		info = Inspect(context.Background(), interactive, ExecOptions{Protocol: 2})
+		assert.Equal(t, []string{"single", "interactive-v2"}, info.Protocols)
+	})
+
+	t.Run("describes providers supporting single calls only", func(t *testing.T) {
+		info := Inspect(context.Background(), single, ExecOptions{})
+		assert.Empty(t, info.Version)
+		assert.Equal(t, []string{"single"}, info.Protocols)
+		assert.Empty(t, info.Error)
+	})
+
+	t.Run("doesn't run providers that don't match their pinned checksum", func(t *testing.T) {
+		info := Inspect(context.Background(), interactive, ExecOptions{SHA256: strings.Repeat("0", 64)})
+		assert.False(t, info.Pinned)
+		assert.Empty(t, info.Protocols)
+		assert.Contains(t, info.Error, "provider checksum mismatch")
+	})
+
+	t.Run("describes builtin and missing providers", func(t *testing.T) {
+		info := Inspect(context.Background(), "builtin:localstore:store.json", ExecOptions{})
+		assert.Equal(t, Info{Name: "builtin:localstore:store.json", Path: "builtin:localstore:store.json", Protocols: []string{"builtin"}}, info)
+
+		info = Inspect(context.Background(), filepath.Join(t.TempDir(), "missing"), ExecOptions{})
+		assert.Contains(t, info.Error, "no such file or directory")
+	})
+}
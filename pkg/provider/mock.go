@@ -0,0 +1,276 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	Register("mock", NewMock)
+}
+
+// MockPrefix starts the names of mock providers, such as mock:fixtures.yml,
+// which answer secret paths from a fixtures file. It is a shorthand for the
+// in-process provider builtin:mock:PATH, see NewMock.
+const MockPrefix = "mock:"
+
+// MockFixturesEnvVar holds the path of the fixtures file of a mock provider
+// executable: a program that calls ServeMock when it is set, such as a test
+// binary from its TestMain, can be run as a provider speaking the provider
+// protocols.
+const MockFixturesEnvVar = "SUMMON_MOCK_FIXTURES"
+
+// Protocols a mock provider executable can be restricted to.
+const (
+	MockProtocolBoth        = "both"
+	MockProtocolInteractive = "interactive"
+	MockProtocolSingle      = "single"
+)
+
+// MockFixtures is the content of a mock provider's fixtures file:
+//
+//	latency: 100ms       # delay before answering each secret
+//	error: ""            # when set, every fetch fails with this message
+//	secrets:
+//	  db/password: s3cr3t
+//	  api/token:
+//	    value: tok
+//	    latency: 2s
+//	    error: permission denied
+//
+// Mock provider executables (see ServeMock) also read:
+//
+//	protocol: both       # or interactive, or single
+//	version: 1.2.3       # output of --version, which fails when empty
+type MockFixtures struct {
+	Latency  time.Duration         `yaml:"latency"`
+	Error    string                `yaml:"error"`
+	Secrets  map[string]MockSecret `yaml:"secrets"`
+	Protocol string                `yaml:"protocol"`
+	Version  string                `yaml:"version"`
+}
+
+// MockSecret is the answer of a mock provider for a path: its value or, to
+// inject a failure, an error. A plain string is a value.
+type MockSecret struct {
+	Value   string        `yaml:"value"`
+	Latency time.Duration `yaml:"latency"`
+	Error   string        `yaml:"error"`
+}
+
+// UnmarshalYAML reads a secret given either as its value or as a mapping.
+func (s *MockSecret) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.Value)
+	}
+	type plain MockSecret
+	return node.Decode((*plain)(s))
+}
+
+// LoadMockFixtures reads the fixtures file at path. Unknown settings are
+// errors, to catch typos.
+func LoadMockFixtures(path string) (*MockFixtures, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read mock fixtures: %w", err)
+	}
+	defer f.Close()
+
+	fixtures := &MockFixtures{Protocol: MockProtocolBoth}
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(fixtures); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Unable to read mock fixtures from %s: %w", path, err)
+	}
+
+	switch fixtures.Protocol {
+	case MockProtocolBoth, MockProtocolInteractive, MockProtocolSingle:
+	default:
+		return nil, fmt.Errorf("Unable to read mock fixtures from %s: unknown protocol %q (expected %s, %s or %s)",
+			path, fixtures.Protocol, MockProtocolBoth, MockProtocolInteractive, MockProtocolSingle)
+	}
+	return fixtures, nil
+}
+
+// Lookup returns the value of the secret at path, after simulating the
+// configured latency. It returns ctx's error if ctx is done first.
+func (f *MockFixtures) Lookup(ctx context.Context, path string) (string, error) {
+	secret, ok := f.Secrets[path]
+
+	timer := time.NewTimer(f.Latency + secret.Latency)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-timer.C:
+	}
+
+	switch {
+	case f.Error != "":
+		return "", errors.New(f.Error)
+	case !ok:
+		return "", fmt.Errorf("no fixture for %s", path)
+	case secret.Error != "":
+		return "", errors.New(secret.Error)
+	}
+	return secret.Value, nil
+}
+
+// NewMock returns a provider answering secret paths from the fixtures file
+// at path, registered as builtin:mock:PATH. The fixtures are read once, and
+// the secrets of a fetch are looked up concurrently.
+func NewMock(path string) (Provider, error) {
+	if path == "" {
+		return nil, errors.New("the fixtures file is missing, e.g. mock:fixtures.yml")
+	}
+	fixtures, err := LoadMockFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mockProvider{path: path, fixtures: fixtures}, nil
+}
+
+// mockProvider answers secret paths from fixtures.
+type mockProvider struct {
+	path     string
+	fixtures *MockFixtures
+}
+
+func (p *mockProvider) Name() string {
+	return BuiltinPrefix + "mock:" + p.path
+}
+
+func (p *mockProvider) Fetch(ctx context.Context, requests []Request) ([]Result, error) {
+	results := make([]Result, len(requests))
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := p.fixtures.Lookup(ctx, request.Path)
+			results[i] = Result{Key: request.Key, Value: value, Error: err}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ServeMock runs a mock provider executable answering from the fixtures
+// file at fixturesPath, and returns its exit status. With a path in args, it
+// answers a single call, or prints the fixtures' version for --version.
+// Otherwise it answers the requests read from stdin in interactive mode, in
+// the protocol version of InteractiveProtocolEnvVar, until stdin is closed:
+// the failures of a secret are reported with InteractiveErrorPrefix, and
+// version 2 requests are answered concurrently, so a secret with less
+// latency is answered first. With an error for every fetch, the session
+// ends on stderr instead, like a provider that can't be used.
+func ServeMock(fixturesPath string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fixtures, err := LoadMockFixtures(fixturesPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		switch {
+		case args[0] == "--version" && fixtures.Version != "":
+			fmt.Fprint(stdout, fixtures.Version)
+			return 0
+		case args[0] == "--version":
+			fmt.Fprintln(stderr, "mock provider has no version")
+			return 1
+		case fixtures.Protocol == MockProtocolInteractive:
+			fmt.Fprintln(stderr, "mock provider only supports interactive mode")
+			return 1
+		}
+		value, err := fixtures.Lookup(ctx, args[0])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprint(stdout, value)
+		return 0
+	}
+
+	if fixtures.Protocol == MockProtocolSingle {
+		// Exit without answering, like providers without interactive mode
+		return 1
+	}
+	v2 := os.Getenv(InteractiveProtocolEnvVar) == "2"
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	answer := func(id, path string) {
+		value, err := fixtures.Lookup(ctx, path)
+		line := base64.StdEncoding.EncodeToString([]byte(value))
+		if err != nil {
+			line = InteractiveErrorPrefix + base64.StdEncoding.EncodeToString([]byte(err.Error()))
+		}
+		if v2 {
+			line = id + " " + line
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(stdout, line)
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if fixtures.Error != "" {
+			_, err := fixtures.Lookup(ctx, line)
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if !v2 {
+			answer("", line)
+			continue
+		}
+		id, path, ok := strings.Cut(line, " ")
+		if !ok {
+			fmt.Fprintf(stderr, "malformed request %q\n", line)
+			return 1
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answer(id, path)
+		}()
+	}
+	wg.Wait()
+	return 0
+}
+
+// resolveMock returns the builtin provider name for a mock:PATH provider
+// argument, with PATH made absolute.
+func resolveMock(provider string) (string, error) {
+	path, _ := strings.CutPrefix(provider, MockPrefix)
+	if path == "" {
+		return "", fmt.Errorf("mock provider %q needs a fixtures file, e.g. mock:fixtures.yml", provider)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("Unable to read mock fixtures: %w", err)
+	}
+	return BuiltinPrefix + "mock:" + path, nil
+}
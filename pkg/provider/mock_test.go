@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as a mock provider executable when
+// MockFixturesEnvVar is set, see newMockExec.
+func TestMain(m *testing.M) {
+	if fixtures := os.Getenv(MockFixturesEnvVar); fixtures != "" {
+		os.Exit(ServeMock(fixtures, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	os.Exit(m.Run())
+}
+
+// newMockExec returns a provider running the test binary, configured by
+// opts, as a mock provider executable answering from fixtures.
+func newMockExec(t *testing.T, fixtures string, opts ExecOptions) Provider {
+	self, err := os.Executable()
+	require.NoError(t, err)
+	opts.Env.Env = map[string]string{MockFixturesEnvVar: writeFixtures(t, fixtures)}
+	return NewExecWithOptions(self, opts)
+}
+
+// writeFixtures writes a fixtures file and returns its path.
+func writeFixtures(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "fixtures.yml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+const testFixtures = `
+secrets:
+  db/password: s3cr3t
+  db/user:
+    value: admin
+    latency: 20ms
+  db/broken:
+    error: permission denied
+`
+
+func TestLoadMockFixtures(t *testing.T) {
+	t.Run("reads plain and detailed secrets", func(t *testing.T) {
+		fixtures, err := LoadMockFixtures(writeFixtures(t, testFixtures))
+		require.NoError(t, err)
+		assert.Equal(t, MockSecret{Value: "s3cr3t"}, fixtures.Secrets["db/password"])
+		assert.Equal(t, MockSecret{Value: "admin", Latency: 20 * time.Millisecond}, fixtures.Secrets["db/user"])
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		_, err := LoadMockFixtures(writeFixtures(t, "secret: {}\n"))
+		assert.ErrorContains(t, err, "field secret not found")
+
+		_, err = LoadMockFixtures(writeFixtures(t, "protocol: v3\n"))
+		assert.ErrorContains(t, err, `unknown protocol "v3" (expected both, interactive or single)`)
+	})
+}
+
+func TestMockFixturesLookup(t *testing.T) {
+	fixtures, err := LoadMockFixtures(writeFixtures(t, testFixtures))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	start := time.Now()
+	value, err := fixtures.Lookup(ctx, "db/user")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", value)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	_, err = fixtures.Lookup(ctx, "db/broken")
+	assert.EqualError(t, err, "permission denied")
+
+	_, err = fixtures.Lookup(ctx, "db/missing")
+	assert.EqualError(t, err, "no fixture for db/missing")
+
+	fixtures.Error = "token expired"
+	_, err = fixtures.Lookup(ctx, "db/password")
+	assert.EqualError(t, err, "token expired")
+}
+
+func TestMockProvider(t *testing.T) {
+	fixtures := writeFixtures(t, testFixtures)
+	dir := filepath.Dir(fixtures)
+	t.Chdir(dir)
+
+	name, err := Resolve("mock:fixtures.yml")
+	require.NoError(t, err)
+	assert.Equal(t, BuiltinPrefix+"mock:"+filepath.Join(dir, "fixtures.yml"), name)
+
+	_, err = Resolve("mock:missing.yml")
+	assert.ErrorContains(t, err, "Unable to read mock fixtures")
+
+	provider, err := New(name)
+	require.NoError(t, err)
+	assert.Equal(t, name, provider.Name())
+
+	t.Run("answers from the fixtures", func(t *testing.T) {
+		results, err := provider.Fetch(context.Background(), []Request{
+			{Key: "USER", Path: "db/user"},
+			{Key: "PASSWORD", Path: "db/password"},
+			{Key: "BROKEN", Path: "db/broken"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, []Result{{Key: "USER", Value: "admin"}, {Key: "PASSWORD", Value: "s3cr3t"}}, results[:2])
+		assert.EqualError(t, results[2].Error, "permission denied")
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		slow, err := New(BuiltinPrefix + "mock:" + writeFixtures(t, "latency: 1h\n"))
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err = slow.Fetch(ctx, []Request{{Key: "A", Path: "a"}})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("needs a fixtures file", func(t *testing.T) {
+		_, err := New(BuiltinPrefix + "mock:")
+		assert.EqualError(t, err, `builtin provider "builtin:mock:": the fixtures file is missing, e.g. mock:fixtures.yml`)
+	})
+}
+
+func TestServeMock(t *testing.T) {
+	serve := func(t *testing.T, fixtures, stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		status := ServeMock(writeFixtures(t, fixtures), args, strings.NewReader(stdin), &stdout, &stderr)
+		return status, stdout.String(), stderr.String()
+	}
+
+	t.Run("answers single calls", func(t *testing.T) {
+		status, stdout, _ := serve(t, testFixtures, "", "db/password")
+		assert.Equal(t, 0, status)
+		assert.Equal(t, "s3cr3t", stdout)
+
+		status, _, stderr := serve(t, testFixtures, "", "db/broken")
+		assert.Equal(t, 1, status)
+		assert.Equal(t, "permission denied\n", stderr)
+
+		status, _, stderr = serve(t, "protocol: interactive\n"+testFixtures, "", "db/password")
+		assert.Equal(t, 1, status)
+		assert.Equal(t, "mock provider only supports interactive mode\n", stderr)
+	})
+
+	t.Run("prints its version", func(t *testing.T) {
+		status, stdout, _ := serve(t, "version: 1.2.3\n", "", "--version")
+		assert.Equal(t, 0, status)
+		assert.Equal(t, "1.2.3", stdout)
+
+		status, _, _ = serve(t, "", "", "--version")
+		assert.Equal(t, 1, status)
+	})
+
+	t.Run("answers interactive requests", func(t *testing.T) {
+		status, stdout, _ := serve(t, testFixtures, "db/password\ndb/broken\n")
+		assert.Equal(t, 0, status)
+		assert.Equal(t, "czNjcjN0\n!error cGVybWlzc2lvbiBkZW5pZWQ=\n", stdout)
+
+		t.Setenv(InteractiveProtocolEnvVar, "2")
+		status, stdout, _ = serve(t, testFixtures, "1 db/user\n2 db/password\n")
+		assert.Equal(t, 0, status)
+		assert.Equal(t, "2 czNjcjN0\n1 YWRtaW4=\n", stdout, "db/user is slower")
+
+		status, _, stderr := serve(t, testFixtures, "db/password\n")
+		assert.Equal(t, 1, status)
+		assert.Equal(t, "malformed request \"db/password\"\n", stderr)
+	})
+
+	t.Run("ends interactive sessions on errors for every fetch", func(t *testing.T) {
+		status, stdout, stderr := serve(t, "error: token expired\n"+testFixtures, "db/password\n")
+		assert.Equal(t, 1, status)
+		assert.Empty(t, stdout)
+		assert.Equal(t, "token expired\n", stderr)
+	})
+
+	t.Run("exits without answering when restricted to single calls", func(t *testing.T) {
+		status, stdout, _ := serve(t, "protocol: single\n"+testFixtures, "db/password\n")
+		assert.Equal(t, 1, status)
+		assert.Empty(t, stdout)
+	})
+}
@@ -9,10 +9,12 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -20,8 +22,22 @@ import (
 	"github.com/cyberark/summon/pkg/secretsyml"
 )
 
+// BuiltinPrefix starts the names of the providers compiled into summon, such
+// as builtin:localstore:/path/to/store.
+const BuiltinPrefix = "builtin:"
+
+// ErrMultipleProviders is wrapped by the error Resolve returns when no
+// provider is given and the provider path holds several.
+var ErrMultipleProviders = errors.New("More than one provider")
+
+// ErrNoProvider is returned by Resolve when no provider is given and the
+// provider path holds none.
+var ErrNoProvider = errors.New("Could not resolve a provider!")
+
 // Resolve resolves a filepath to a provider
 // Checks the CLI arg, environment and then default path
+// Builtin provider names are returned as they are, mock ones with an absolute
+// fixtures path.
 func Resolve(providerArg string) (string, error) {
 	provider := providerArg
 
@@ -29,6 +45,13 @@ func Resolve(providerArg string) (string, error) {
 		provider = os.Getenv("SUMMON_PROVIDER")
 	}
 
+	if strings.HasPrefix(provider, BuiltinPrefix) {
+		return provider, nil
+	}
+	if strings.HasPrefix(provider, MockPrefix) {
+		return resolveMock(provider)
+	}
+
 	if provider == "" {
 		defaultPath, err := GetDefaultPath()
 		if err != nil {
@@ -38,12 +61,12 @@ func Resolve(providerArg string) (string, error) {
 		if len(providers) == 1 {
 			provider = providers[0].Name()
 		} else if len(providers) > 1 {
-			return "", fmt.Errorf("More than one provider found in %s, please specify one\n", defaultPath)
+			return "", fmt.Errorf("%w found in %s, please specify one\n", ErrMultipleProviders, defaultPath)
 		}
 	}
 
 	if provider == "" {
-		return "", fmt.Errorf("Could not resolve a provider!")
+		return "", ErrNoProvider
 	}
 
 	provider, err := expandPath(provider)
@@ -61,25 +84,54 @@ func Resolve(providerArg string) (string, error) {
 // Call shells out to a provider and return its output
 // If call succeeds, stdout is returned with no error
 // If call fails, "" is return with error containing stderr
-func Call(provider, specPath string) (string, error) {
+// If ctx is done before the provider exits, the provider is killed and ctx's error is returned
+// The provider runs with the default ExecOptions: its checksum isn't verified and it inherits
+// summon's whole environment, see CallWithOptions.
+func Call(ctx context.Context, provider, specPath string) (string, error) {
+	return CallWithOptions(ctx, provider, ExecOptions{}, specPath)
+}
+
+// CallWithOptions is Call for a provider configured by opts, which applies its
+// checksum pin and environment policy. The protocol and stderr policy don't apply
+// to single calls.
+func CallWithOptions(ctx context.Context, provider string, opts ExecOptions, specPath string) (string, error) {
+	value, _, err := call(ctx, provider, opts, specPath)
+	return value, err
+}
+
+// call implements Call for a provider configured by opts, also returning
+// what the provider wrote to stderr.
+func call(ctx context.Context, provider string, opts ExecOptions, specPath string) (string, string, error) {
 	var (
 		stdOut bytes.Buffer
 		stdErr bytes.Buffer
 	)
-	cmd := exec.Command(provider, specPath)
+	cmd, err := command(ctx, provider, opts, nil, specPath)
+	if err != nil {
+		return "", "", err
+	}
 	cmd.Stdout = &stdOut
 	cmd.Stderr = &stdErr
-	err := cmd.Run()
+	err = cmd.Run()
 
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return "", "", ctxErr
+	}
 	if err != nil {
 		errstr := err.Error()
 		if stdErr.Len() > 0 {
 			errstr += ": " + strings.TrimSpace(stdErr.String())
 		}
-		return "", errors.New(errstr)
+		return "", "", errors.New(errstr)
 	}
 
-	return strings.TrimSpace(stdOut.String()), nil
+	return strings.TrimSpace(stdOut.String()), stdErr.String(), nil
+}
+
+// Request asks a provider for the secret at Path on behalf of Key.
+type Request struct {
+	Key  string // The secret's identifier (environment variable name or alias).
+	Path string // The secret's path, as passed to the provider.
 }
 
 // Result is the outcome of fetching a single secret from the provider.
@@ -96,38 +148,82 @@ type Result struct {
 // ErrInteractiveModeNotSupported is returned when a provider does not support interactive mode
 var ErrInteractiveModeNotSupported = errors.New("interactive mode not supported")
 
+// InteractiveErrorPrefix starts the lines with which providers report, in
+// interactive mode, that they failed to fetch a secret: the prefix is
+// followed by the base64 encoded error message. Other secrets of the session
+// are still fetched, unlike when a provider writes to stderr.
+const InteractiveErrorPrefix = "!error "
+
+// InteractiveProtocolEnvVar is set to "2" for the providers summon talks to
+// with version 2 of the interactive protocol, see ExecOptions.Protocol. Each
+// request line then starts with an ID and a space, and each answer starts
+// with the ID of the request it answers and a space.
+const InteractiveProtocolEnvVar = "SUMMON_INTERACTIVE_PROTOCOL"
+
+// ErrProtocolViolation is wrapped by the errors reported when a provider
+// breaks the interactive protocol, e.g. by answering a request twice.
+var ErrProtocolViolation = errors.New("interactive protocol violation")
+
 const (
-	// defaultInteractiveTimeout is the fallback timeout for interactive mode
-	defaultInteractiveTimeout = 60
-	// Even though summon can be used for non-Conjur providers, the environment
+	// DefaultInteractiveTimeout is the fallback timeout for interactive mode, in seconds
+	DefaultInteractiveTimeout = 60
+	// InteractiveTimeoutEnvVar overrides the timeout for interactive mode. Even
+	// though summon can be used for non-Conjur providers, the environment
 	// variable name is kept the same as the Conjur provider to avoid confusion.
-	interactiveTimeoutEnvVar = "CONJUR_HTTP_TIMEOUT"
+	InteractiveTimeoutEnvVar = "CONJUR_HTTP_TIMEOUT"
 )
 
+// stderrGracePeriod is how long cleaning up after interactive mode waits for
+// the provider to exit once its stdin is closed.
+const stderrGracePeriod = 100 * time.Millisecond
+
 // interactiveModeTimeout returns the timeout for interactive provider calls.
 // It can be overridden via CONJUR_HTTP_TIMEOUT which must be a positive
 // integer number of seconds.
 func interactiveModeTimeout() time.Duration {
-	timeoutStr, ok := os.LookupEnv(interactiveTimeoutEnvVar)
+	timeoutStr, ok := os.LookupEnv(InteractiveTimeoutEnvVar)
 	if !ok || timeoutStr == "" {
-		return defaultInteractiveTimeout * time.Second
+		return DefaultInteractiveTimeout * time.Second
 	}
 
 	secs, err := strconv.Atoi(timeoutStr)
 	if err != nil || secs <= 0 {
-		secs = defaultInteractiveTimeout
+		secs = DefaultInteractiveTimeout
 	}
 	return time.Duration(secs) * time.Second
 }
 
 // CallInteractiveMode calls a provider without passing any arguments. It then constantly fetches
 // secrets from its stdout. It returns a channel of results, a channel of errors and a cleanup function.
-func CallInteractiveMode(provider string, secrets secretsyml.SecretsMap) (chan Result, chan error, func()) {
+// Secrets the provider reports with InteractiveErrorPrefix get a Result with an Error, while anything
+// written to stderr fails the whole call. The provider is killed when ctx is done. Like Call, it
+// runs the provider with the default ExecOptions.
+func CallInteractiveMode(ctx context.Context, provider string, secrets secretsyml.SecretsMap) (chan Result, chan error, func()) {
+	return callInteractiveMode(ctx, provider, secrets, ExecOptions{}, nil)
+}
+
+// callInteractiveMode implements CallInteractiveMode for a provider
+// configured by opts. Version 1 of the protocol matches answers to requests
+// by their order. Version 2 prefixes requests with an ID echoed by answers,
+// so that providers can answer in any order, see InteractiveProtocolEnvVar.
+// When onStderr is set, lines written to stderr are passed to it instead of
+// failing the call. Cleaning up waits until stderr has been read.
+func callInteractiveMode(ctx context.Context, provider string, secrets secretsyml.SecretsMap, opts ExecOptions, onStderr func(string)) (chan Result, chan error, func()) {
 	resultsCh := make(chan Result)
 	errorsCh := make(chan error, 1)
-	ctxTimeout, ctxCancel := context.WithTimeout(context.Background(), interactiveModeTimeout())
+	ctxTimeout, ctxCancel := context.WithTimeout(ctx, interactiveModeTimeout())
 
-	cmd := exec.CommandContext(ctxTimeout, provider)
+	version := 1
+	var env []string
+	if opts.Protocol == 2 {
+		version = 2
+		env = append(env, InteractiveProtocolEnvVar+"=2")
+	}
+	cmd, err := command(ctxTimeout, provider, opts, env)
+	if err != nil {
+		errorsCh <- err
+		return resultsCh, errorsCh, func() { ctxCancel() }
+	}
 
 	// Get a pipe to the command's stdinPipe
 	stdinPipe, err := cmd.StdinPipe()
@@ -154,29 +250,62 @@ func CallInteractiveMode(provider string, secrets secretsyml.SecretsMap) (chan R
 		}
 	}
 
+	stderrDone := make(chan struct{})
 	cleanup := func() {
 		stdinPipe.Close()
 		stdoutPipe.Close()
+		// Let the provider exit on its own, so that what it writes to stderr
+		// until then is read
+		select {
+		case <-stderrDone:
+		case <-time.After(stderrGracePeriod):
+		}
 		stderrPipe.Close()
 		ctxCancel()
+		<-stderrDone
+		// Reap the provider, once started
+		if cmd.Process != nil {
+			cmd.Wait()
+		}
 	}
 
 	err = cmd.Start()
 
 	if err != nil {
+		close(stderrDone)
 		errorsCh <- err
 		return resultsCh, errorsCh, cleanup
 	}
 
 	secretEnvVarCh := make(chan string, len(secrets))
 
+	// Version 2 requests are numbered in key order
+	keys := slices.Sorted(maps.Keys(secrets))
+	pending := make(map[string]string, len(keys))
+	for i, key := range keys {
+		pending[strconv.Itoa(i+1)] = key
+	}
+
+	// Only the first error ends the call: later ones are dropped rather than
+	// block the goroutine reporting them
+	reportError := func(err error) {
+		select {
+		case errorsCh <- err:
+		default:
+		}
+	}
+
 	// This goroutine sends the paths of the secrets to the stdin of a secrets provider
 	go func() {
-		for key, spec := range secrets {
+		for i, key := range keys {
 			slog.Debug("Fetching secret", "name", key, "provider", provider)
-			_, err := fmt.Fprintln(stdinPipe, spec.Path)
+			request := secrets[key].Path
+			if version == 2 {
+				request = strconv.Itoa(i+1) + " " + request
+			}
+			_, err := fmt.Fprintln(stdinPipe, request)
 			if err != nil {
-				errorsCh <- ErrInteractiveModeNotSupported
+				reportError(ErrInteractiveModeNotSupported)
 				break
 			}
 			secretEnvVarCh <- key
@@ -186,7 +315,18 @@ func CallInteractiveMode(provider string, secrets secretsyml.SecretsMap) (chan R
 	// This goroutine reads from the stdoutPipe of a secrets provider and sends the results to the results channel.
 	// After all secrets are read, it closes the results channel
 	go func() {
-		defer close(resultsCh)
+		// After an error, resultsCh is left open so that the error is what
+		// ends the call
+		failed := false
+		fail := func(err error) {
+			failed = true
+			reportError(err)
+		}
+		defer func() {
+			if !failed {
+				close(resultsCh)
+			}
+		}()
 		reader := bufio.NewReader(stdoutPipe)
 		index := 0
 
@@ -196,24 +336,51 @@ func CallInteractiveMode(provider string, secrets secretsyml.SecretsMap) (chan R
 				if err == io.EOF {
 					break
 				}
-				errorsCh <- err
-				continue
+				fail(err)
+				return
 			}
 
 			line = strings.TrimRight(line, "\r\n")
+			var keyVal string
+			if version == 2 {
+				id, answer, _ := strings.Cut(line, " ")
+				key, ok := pending[id]
+				if !ok {
+					fail(fmt.Errorf("%w: answer for unknown or already answered request %q", ErrProtocolViolation, id))
+					return
+				}
+				delete(pending, id)
+				keyVal, line = key, answer
+			}
+
+			message, isError := strings.CutPrefix(line, InteractiveErrorPrefix)
+			if isError {
+				line = message
+			}
 			decoded, err := base64.StdEncoding.DecodeString(line)
 			if err != nil {
-				errorsCh <- fmt.Errorf("failed to decode base64 string: %w", err)
-				continue
+				fail(fmt.Errorf("failed to decode base64 string: %w", err))
+				return
 			}
 
-			keyVal := <-secretEnvVarCh
-			resultsCh <- Result{
-				Key:   keyVal,
-				Value: string(decoded),
-				Error: nil,
+			if version != 2 {
+				select {
+				case keyVal = <-secretEnvVarCh:
+				case <-ctxTimeout.Done():
+					return
+				}
+			}
+			result := Result{Key: keyVal, Value: string(decoded)}
+			if isError {
+				result = Result{Key: keyVal, Value: "", Error: errors.New(string(decoded))}
 			}
 			clear(decoded)
+			// The results stop being read once the call has ended
+			select {
+			case resultsCh <- result:
+			case <-ctxTimeout.Done():
+				return
+			}
 			index++
 			if index >= len(secrets) {
 				break
@@ -221,20 +388,41 @@ func CallInteractiveMode(provider string, secrets secretsyml.SecretsMap) (chan R
 
 		}
 		if index == 0 {
-			errorsCh <- ErrInteractiveModeNotSupported
+			fail(ErrInteractiveModeNotSupported)
 		}
 
 	}()
 	go func() {
+		defer close(stderrDone)
 		scanner := bufio.NewScanner(stderrPipe)
 		for scanner.Scan() {
 			line := scanner.Text()
-			errorsCh <- errors.New(line)
+			if onStderr != nil {
+				onStderr(line)
+				continue
+			}
+			reportError(&stderrError{line: line})
 		}
 	}()
 	return resultsCh, errorsCh, cleanup
 }
 
+// command returns the command running provider with args, in the
+// environment described by opts.Env with env added, after checking the
+// executable against opts.SHA256.
+func command(ctx context.Context, provider string, opts ExecOptions, env []string, args ...string) (*exec.Cmd, error) {
+	if opts.SHA256 != "" {
+		if err := verifyChecksum(provider, opts.SHA256); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, provider, args...)
+	cmd.Env = append(opts.Env.environ(os.Environ()), env...)
+	statsFrom(ctx).recordProcess()
+	return cmd, nil
+}
+
 // Given a provider name, it returns a path to executable prefixed with DefaultPath. If
 // the provider has any other pattern (eg. `./provider-name`, `/foo/provider-name`), the
 // parameter is assumed to be a path to the provider and not just a name.
@@ -253,11 +441,23 @@ func expandPath(provider string) (string, error) {
 	return filepath.Join(defaultPath, provider), nil
 }
 
-func GetDefaultPath() (string, error) {
-	pathOverride := os.Getenv("SUMMON_PROVIDER_PATH")
+// ProviderPathEnvVar overrides the directory providers are searched in.
+const ProviderPathEnvVar = "SUMMON_PROVIDER_PATH"
 
-	if pathOverride != "" {
-		return pathOverride, nil
+// SearchPath is a directory GetDefaultPath may find providers in.
+type SearchPath struct {
+	Source string `json:"source"` // What the directory is, e.g. SUMMON_PROVIDER_PATH or homebrew
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
+// SearchPaths returns the directories GetDefaultPath considers, in order.
+// The one in SUMMON_PROVIDER_PATH, when set, is used even if it doesn't
+// exist; otherwise the first existing directory is used.
+func SearchPaths() []SearchPath {
+	var paths []SearchPath
+	if pathOverride := os.Getenv(ProviderPathEnvVar); pathOverride != "" {
+		paths = append(paths, SearchPath{Source: ProviderPathEnvVar, Path: pathOverride})
 	}
 
 	dir := "/usr/local/lib/summon"
@@ -272,11 +472,7 @@ func GetDefaultPath() (string, error) {
 
 		dir = filepath.Join(programFilesDir, "Cyberark Conjur", "Summon", "Providers")
 	}
-
-	// found default installation directory
-	if _, err := os.Stat(dir); err == nil {
-		return dir, nil
-	}
+	paths = append(paths, SearchPath{Source: "system", Path: dir})
 
 	// Enable portable installation with Providers dir next to executable
 	// if the direcotries above were not found
@@ -288,11 +484,7 @@ func GetDefaultPath() (string, error) {
 	execDir := filepath.Dir(exec)
 
 	// eg ~/brew/bin/Providers
-	providersDir := filepath.Join(execDir, "Providers")
-
-	if _, err := os.Stat(providersDir); err == nil {
-		return providersDir, nil
-	}
+	paths = append(paths, SearchPath{Source: "portable", Path: filepath.Join(execDir, "Providers")})
 
 	// Homebrew installs summon-conjur to ~/brew/lib/summon
 
@@ -303,10 +495,26 @@ func GetDefaultPath() (string, error) {
 	baseDir := filepath.Dir(execDir)
 
 	// libDir = ~/brew/lib/summon
-	libDir := filepath.Join(baseDir, "lib", "summon")
+	paths = append(paths, SearchPath{Source: "homebrew", Path: filepath.Join(baseDir, "lib", "summon")})
+
+	for i := range paths {
+		_, err := os.Stat(paths[i].Path)
+		paths[i].Exists = err == nil
+	}
+	return paths
+}
 
-	if _, err := os.Stat(libDir); err == nil {
-		return libDir, nil
+// GetDefaultPath returns the directory providers are searched in, see
+// SearchPaths.
+func GetDefaultPath() (string, error) {
+	paths := SearchPaths()
+	if paths[0].Source == ProviderPathEnvVar {
+		return paths[0].Path, nil
+	}
+	for _, path := range paths {
+		if path.Exists {
+			return path.Path, nil
+		}
 	}
 
 	return "", fmt.Errorf("No provider directory found. Please set the " +
@@ -1,17 +1,20 @@
 package provider
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	"math/big"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/cyberark/summon/pkg/secretsyml"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaultPortableProviderPath(t *testing.T) {
@@ -80,6 +83,17 @@ func TestProviderResolutionOfAbsPath(t *testing.T) {
 	assert.EqualValues(t, provider, expected)
 }
 
+func TestProviderResolutionOfBuiltin(t *testing.T) {
+	provider, err := Resolve("builtin:localstore:store.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "builtin:localstore:store.json", provider)
+
+	t.Setenv("SUMMON_PROVIDER", "builtin:localstore:other.json")
+	provider, err = Resolve("")
+	assert.Nil(t, err)
+	assert.Equal(t, "builtin:localstore:other.json", provider)
+}
+
 func TestProviderResolutionOfRelPath(t *testing.T) {
 	f, err := os.CreateTemp("", "")
 	defer os.RemoveAll(f.Name())
@@ -223,12 +237,21 @@ func TestProviderResolutionViaOverrideDefaultPathWithMultipleProviders(t *testin
 
 	_, err := Resolve("")
 
-	assert.NotNil(t, err)
+	assert.ErrorIs(t, err, ErrMultipleProviders)
+	assert.EqualError(t, err, "More than one provider found in "+tempDir+", please specify one\n")
+
+	t.Run("reports an empty provider path", func(t *testing.T) {
+		t.Setenv("SUMMON_PROVIDER_PATH", t.TempDir())
+		t.Setenv("SUMMON_PROVIDER", "")
+
+		_, err := Resolve("")
+		assert.ErrorIs(t, err, ErrNoProvider)
+	})
 }
 
 func TestProviderCall(t *testing.T) {
 	arg := "provider.go"
-	out, err := Call("ls", arg)
+	out, err := Call(context.Background(), "ls", arg)
 
 	assert.Nil(t, err)
 	if err != nil {
@@ -242,7 +265,7 @@ func TestProviderCallWithExecutionError(t *testing.T) {
 	err := os.Setenv("LC_ALL", "C")
 	assert.Nil(t, err)
 
-	out, err := Call("ls", "README.notafile")
+	out, err := Call(context.Background(), "ls", "README.notafile")
 
 	assert.Empty(t, out)
 	assert.NotNil(t, err)
@@ -256,23 +279,43 @@ func TestProviderCallWithBadPath(t *testing.T) {
 		return
 	}
 
-	out, err := Call("/etc/passwd", "foo")
+	out, err := Call(context.Background(), "/etc/passwd", "foo")
 
 	assert.Empty(t, out)
 	assert.Contains(t, err.Error(), "permission denied")
 }
 
-func TestGetAllProviders(t *testing.T) {
-	pathTo, err := os.Getwd()
-	assert.Nil(t, err)
-	if err != nil {
-		return
-	}
+func TestProviderCallIsKilledWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
 
-	choppedPathTo := strings.TrimSuffix(pathTo, "/provider")
-	assert.Equal(t, choppedPathTo, pathTo[0:len(pathTo)-9])
+	start := time.Now()
+	out, err := Call(ctx, "sleep", "10")
 
-	pathToTest := filepath.Join(choppedPathTo, "command", "testversions")
+	assert.Empty(t, out)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestProviderCallWithOptions(t *testing.T) {
+	t.Setenv("SUMMON_TEST_TOKEN", "token")
+	script := writeTestProvider(t, `echo "$1 ${SUMMON_TEST_TOKEN:-none}"`+"\n")
+	sum, err := Checksum(script)
+	require.NoError(t, err)
+
+	out, err := CallWithOptions(context.Background(), script, ExecOptions{SHA256: sum, Env: EnvPolicy{Inherit: InheritNone}}, "db/password")
+	require.NoError(t, err)
+	assert.Equal(t, "db/password none", out)
+
+	_, err = CallWithOptions(context.Background(), script, ExecOptions{SHA256: strings.Repeat("0", 64)}, "db/password")
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestGetAllProviders(t *testing.T) {
+	pathToTest := t.TempDir()
+	for _, name := range []string{"testprovider-trailingnewline", "testprovider", "testprovider-noversionsupport"} {
+		require.NoError(t, os.WriteFile(filepath.Join(pathToTest, name), nil, 0o755))
+	}
 
 	output, err := GetAllProviders(pathToTest)
 	assert.Nil(t, err)
@@ -289,18 +332,7 @@ func TestGetAllProviders(t *testing.T) {
 }
 
 func TestGetAllProvidersWithBadPath(t *testing.T) {
-	pathTo, err := os.Getwd()
-	assert.Nil(t, err)
-	if err != nil {
-		return
-	}
-
-	choppedPathTo := strings.TrimSuffix(pathTo, "/provider")
-	assert.Equal(t, choppedPathTo, pathTo[0:len(pathTo)-9])
-
-	pathToTest := filepath.Join(choppedPathTo, "command", "testversions")
-
-	_, err = GetAllProviders(filepath.Join(pathToTest, "makebelievedir"))
+	_, err := GetAllProviders(filepath.Join(t.TempDir(), "makebelievedir"))
 	assert.NotNil(t, err)
 }
 
@@ -311,7 +343,7 @@ func TestCallInteractiveMode(t *testing.T) {
 			"key1": secretsyml.SecretSpec{Path: "provider.go"},
 		}
 
-		_, errorsCh, cleanup := CallInteractiveMode(provider, secrets)
+		_, errorsCh, cleanup := CallInteractiveMode(context.Background(), provider, secrets)
 		defer cleanup()
 
 		select {
@@ -322,6 +354,28 @@ func TestCallInteractiveMode(t *testing.T) {
 		}
 	})
 
+	t.Run("provider command fails to execute when context is done", func(t *testing.T) {
+		provider, err := createMockProvider()
+		assert.NoError(t, err)
+		defer os.Remove(provider)
+		secrets := secretsyml.SecretsMap{
+			"key1": secretsyml.SecretSpec{Path: "provider.go"},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, errorsCh, cleanup := CallInteractiveMode(ctx, provider, secrets)
+		defer cleanup()
+
+		select {
+		case err := <-errorsCh:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "Timeout waiting for error")
+		}
+	})
+
 	t.Run("provider command executes successfully", func(t *testing.T) {
 		provider, err := createMockProvider()
 		assert.NoError(t, err)
@@ -329,7 +383,7 @@ func TestCallInteractiveMode(t *testing.T) {
 			"key1": secretsyml.SecretSpec{Path: "provider.go"},
 		}
 
-		resultsCh, errorsCh, cleanup := CallInteractiveMode(provider, secrets)
+		resultsCh, errorsCh, cleanup := CallInteractiveMode(context.Background(), provider, secrets)
 		defer cleanup()
 
 		select {
@@ -356,7 +410,7 @@ func TestCallInteractiveMode(t *testing.T) {
 		}
 		results := make(map[string]string)
 
-		resultsCh, errorsCh, cleanup := CallInteractiveMode(provider, secrets)
+		resultsCh, errorsCh, cleanup := CallInteractiveMode(context.Background(), provider, secrets)
 		defer cleanup()
 
 		for i := 0; i < len(secrets); i++ {
@@ -392,7 +446,7 @@ func TestCallInteractiveMode(t *testing.T) {
 		}
 		results := make(map[string]string)
 
-		resultsCh, errorsCh, cleanup := CallInteractiveMode(provider, secrets)
+		resultsCh, errorsCh, cleanup := CallInteractiveMode(context.Background(), provider, secrets)
 		defer cleanup()
 
 		for range numResults {
@@ -415,6 +469,33 @@ func TestCallInteractiveMode(t *testing.T) {
 			assert.Equal(t, expectedValue, results[key], "Mismatch for key %s", key)
 		}
 	})
+
+	t.Run("provider answering with several bad lines doesn't leak the reader", func(t *testing.T) {
+		provider := writeTestProvider(t, "read -r path\necho 'not base64!'\necho 'not base64 either!'\n")
+		secrets := secretsyml.SecretsMap{
+			"key1": secretsyml.SecretSpec{Path: "provider.go"},
+			"key2": secretsyml.SecretSpec{Path: "provider2.go"},
+		}
+		goroutines := runtime.NumGoroutine()
+
+		_, errorsCh, cleanup := CallInteractiveMode(context.Background(), provider, secrets)
+		// Let the provider write both lines and exit before reading the error
+		time.Sleep(200 * time.Millisecond)
+		select {
+		case err := <-errorsCh:
+			assert.ErrorContains(t, err, "failed to decode base64 string")
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "Timeout waiting for error")
+		}
+		cleanup()
+
+		// Eventually would count its own goroutine
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines, "the goroutines of the call are still running")
+	})
 }
 
 // Mocks the behaviour of a summon provider. The provider reads a list of secrets from stdin
@@ -458,7 +539,7 @@ func generateRandomString(n int) string {
 }
 
 func TestInteractiveModeTimeout(t *testing.T) {
-	defaultTimeout := time.Duration(defaultInteractiveTimeout) * time.Second
+	defaultTimeout := time.Duration(DefaultInteractiveTimeout) * time.Second
 
 	tests := []struct {
 		envValue string
@@ -473,8 +554,8 @@ func TestInteractiveModeTimeout(t *testing.T) {
 
 	for _, testCase := range tests {
 		t.Run(testCase.envValue, func(t *testing.T) {
-			os.Setenv(interactiveTimeoutEnvVar, testCase.envValue)
-			defer os.Unsetenv(interactiveTimeoutEnvVar)
+			os.Setenv(InteractiveTimeoutEnvVar, testCase.envValue)
+			defer os.Unsetenv(InteractiveTimeoutEnvVar)
 			assert.Equal(t, testCase.expected, interactiveModeTimeout())
 		})
 	}
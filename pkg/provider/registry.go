@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Factory creates an in-process provider from the argument following its
+// name, e.g. "/path/to/store" for builtin:localstore:/path/to/store. The
+// argument is empty when the name has none.
+type Factory func(arg string) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes an in-process provider available as builtin:NAME, see
+// New. It is meant to be called from init functions, and panics when name
+// is already registered or contains a colon.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("provider: invalid builtin provider name %q", name))
+	}
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("provider: builtin provider %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Builtins returns the names of the registered in-process providers, sorted.
+func Builtins() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// New returns the Provider for a name returned by Resolve: the registered
+// in-process provider for builtin:NAME[:ARG] names, and NewExec otherwise.
+func New(name string) (Provider, error) {
+	builtin, ok := strings.CutPrefix(name, BuiltinPrefix)
+	if !ok {
+		return NewExec(name), nil
+	}
+
+	kind, arg, _ := strings.Cut(builtin, ":")
+	registryMu.RLock()
+	factory, ok := registry[kind]
+	registryMu.RUnlock()
+	if !ok {
+		registered := strings.Join(Builtins(), ", ")
+		if registered == "" {
+			registered = "none"
+		}
+		return nil, fmt.Errorf("unknown builtin provider %q (registered: %s)", name, registered)
+	}
+
+	provider, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("builtin provider %q: %w", name, err)
+	}
+	return builtinProvider{provider}, nil
+}
+
+// builtinProvider records the fetches of an in-process provider in the
+// Stats of their context.
+type builtinProvider struct {
+	Provider
+}
+
+func (p builtinProvider) Fetch(ctx context.Context, requests []Request) ([]Result, error) {
+	start := time.Now()
+	results, err := p.Provider.Fetch(ctx, requests)
+	if err == nil {
+		statsFrom(ctx).recordFetch(ctx, FetchStats{Provider: p.Name(), Protocol: "builtin"}, start, results, nil)
+	}
+	return results, err
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticProvider answers every request with the same value.
+type staticProvider struct {
+	value string
+}
+
+func (p staticProvider) Name() string {
+	return "static"
+}
+
+func (p staticProvider) Fetch(_ context.Context, requests []Request) ([]Result, error) {
+	results := make([]Result, 0, len(requests))
+	for _, request := range requests {
+		results = append(results, Result{Key: request.Key, Value: p.value})
+	}
+	return results, nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register("static", func(arg string) (Provider, error) {
+		if arg == "" {
+			return nil, errors.New("missing value")
+		}
+		return staticProvider{value: arg}, nil
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "static")
+		registryMu.Unlock()
+	})
+
+	t.Run("creates registered providers", func(t *testing.T) {
+		assert.Contains(t, Builtins(), "static")
+
+		provider, err := New("builtin:static:s3cr3t")
+		require.NoError(t, err)
+		results, err := provider.Fetch(context.Background(), []Request{{Key: "A", Path: "a"}})
+		require.NoError(t, err)
+		assert.Equal(t, []Result{{Key: "A", Value: "s3cr3t"}}, results)
+
+		_, err = New("builtin:static")
+		assert.EqualError(t, err, `builtin provider "builtin:static": missing value`)
+	})
+
+	t.Run("rejects unknown builtin providers", func(t *testing.T) {
+		_, err := New("builtin:vault")
+		assert.ErrorContains(t, err, `unknown builtin provider "builtin:vault" (registered: `)
+	})
+
+	t.Run("runs other providers as executables", func(t *testing.T) {
+		provider, err := New("/usr/local/lib/summon/summon-conjur")
+		require.NoError(t, err)
+		assert.Equal(t, &execProvider{path: "/usr/local/lib/summon/summon-conjur"}, provider)
+	})
+
+	t.Run("refuses duplicate and invalid names", func(t *testing.T) {
+		factory := func(string) (Provider, error) { return staticProvider{}, nil }
+		assert.PanicsWithValue(t, `provider: builtin provider "static" registered twice`, func() { Register("static", factory) })
+		assert.PanicsWithValue(t, `provider: invalid builtin provider name "a:b"`, func() { Register("a:b", factory) })
+	})
+}
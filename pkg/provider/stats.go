@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stats records how secrets are fetched, for reports such as summon's
+// --stats: how each provider was used, how long each secret took and how
+// many provider processes were spawned. Secret values are never recorded.
+// Providers record into the Stats attached to the context of Fetch with
+// WithStats.
+type Stats struct {
+	mu sync.Mutex
+
+	Fetches   []FetchStats  `json:"fetches"`
+	Secrets   []SecretStats `json:"secrets"`
+	Processes int           `json:"processes"` // Provider processes spawned
+}
+
+// FetchStats describes a call to the Fetch method of a provider.
+type FetchStats struct {
+	Provider string `json:"provider"`
+	// Protocol is how the secrets were fetched: "builtin", "interactive",
+	// "interactive-v2" or "single" (one process per secret).
+	Protocol string `json:"protocol"`
+	// SingleFallback is why the secrets were fetched with single calls after
+	// an interactive session failed, e.g. "interactive mode not supported".
+	SingleFallback string `json:"single_fallback,omitempty"`
+	// Fallback tells whether the secrets are ones that the providers before
+	// this one in a chain failed to fetch, see NewChain.
+	Fallback bool          `json:"fallback"`
+	Secrets  int           `json:"secrets"`
+	Failed   int           `json:"failed"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// SecretStats describes the fetch of a secret by a provider.
+type SecretStats struct {
+	Key      string        `json:"key"`
+	Provider string        `json:"provider"`
+	Latency  time.Duration `json:"latency_ns"` // From the start of the fetch, or of the single calls
+	Failed   bool          `json:"failed"`
+}
+
+type statsKey struct{}
+
+// chainFallbackKey marks the contexts of the fetches of fallback providers.
+type chainFallbackKey struct{}
+
+// WithStats returns a copy of ctx recording the fetches made with it in
+// stats.
+func WithStats(ctx context.Context, stats *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+// statsFrom returns the Stats attached to ctx, or nil.
+func statsFrom(ctx context.Context) *Stats {
+	stats, _ := ctx.Value(statsKey{}).(*Stats)
+	return stats
+}
+
+// recordProcess counts a spawned provider process. Like the other record
+// methods, it does nothing on a nil Stats.
+func (s *Stats) recordProcess() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Processes++
+}
+
+// recordFetch records a fetch of requests by provider that started at
+// start, its results and when each of them was received.
+func (s *Stats) recordFetch(ctx context.Context, fetch FetchStats, start time.Time, results []Result, latencies map[string]time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fetch.Duration = time.Since(start)
+	fetch.Secrets = len(results)
+	fetch.Fallback = ctx.Value(chainFallbackKey{}) != nil
+	for _, result := range results {
+		latency, ok := latencies[result.Key]
+		if !ok {
+			latency = fetch.Duration
+		}
+		if result.Error != nil {
+			fetch.Failed++
+		}
+		s.Secrets = append(s.Secrets, SecretStats{Key: result.Key, Provider: fetch.Provider, Latency: latency, Failed: result.Error != nil})
+	}
+	s.Fetches = append(s.Fetches, fetch)
+}
+
+// latencies records how long after start each secret of a fetch was
+// received.
+type latencies struct {
+	start time.Time
+
+	mu    sync.Mutex
+	byKey map[string]time.Duration
+}
+
+func newLatencies() *latencies {
+	return &latencies{start: time.Now(), byKey: map[string]time.Duration{}}
+}
+
+// received records that the secret of key was received.
+func (l *latencies) received(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.byKey[key] = time.Since(l.start)
+}
+
+// restart forgets the secrets received, and measures from now on.
+func (l *latencies) restart() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start = time.Now()
+	clear(l.byKey)
+}
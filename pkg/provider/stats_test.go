@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	requests := []Request{
+		{Key: "PASSWORD", Path: "db/password"},
+		{Key: "USER", Path: "db/user"},
+	}
+
+	t.Run("records interactive sessions", func(t *testing.T) {
+		provider := newMockExec(t, testFixtures, ExecOptions{Protocol: 2})
+		var stats Stats
+
+		_, err := provider.Fetch(WithStats(context.Background(), &stats), append(requests, Request{Key: "BROKEN", Path: "db/broken"}))
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Processes)
+		require.Len(t, stats.Fetches, 1)
+		fetch := stats.Fetches[0]
+		assert.Equal(t, provider.Name(), fetch.Provider)
+		assert.Equal(t, "interactive-v2", fetch.Protocol)
+		assert.Empty(t, fetch.SingleFallback)
+		assert.False(t, fetch.Fallback)
+		assert.Equal(t, 3, fetch.Secrets)
+		assert.Equal(t, 1, fetch.Failed)
+		require.Len(t, stats.Secrets, 3)
+		for _, secret := range stats.Secrets {
+			assert.Equal(t, provider.Name(), secret.Provider)
+			assert.Equal(t, secret.Key == "BROKEN", secret.Failed, secret.Key)
+			assert.Positive(t, secret.Latency)
+			assert.LessOrEqual(t, secret.Latency, fetch.Duration)
+		}
+	})
+
+	t.Run("records falling back to single calls", func(t *testing.T) {
+		provider := newMockExec(t, "protocol: single\n"+testFixtures, ExecOptions{})
+		var stats Stats
+
+		_, err := provider.Fetch(WithStats(context.Background(), &stats), requests)
+		require.NoError(t, err)
+		assert.Equal(t, 3, stats.Processes, "the interactive session and one call per secret")
+		require.Len(t, stats.Fetches, 1)
+		assert.Equal(t, "single", stats.Fetches[0].Protocol)
+		assert.Equal(t, ErrInteractiveModeNotSupported.Error(), stats.Fetches[0].SingleFallback)
+		assert.Len(t, stats.Secrets, 2)
+	})
+
+	t.Run("records the fetches of fallback providers", func(t *testing.T) {
+		primary := &mapProvider{name: "new", values: map[string]string{"db/password": "s3cr3t"}}
+		Register("stats-test", func(string) (Provider, error) { return primary, nil })
+		t.Cleanup(func() {
+			registryMu.Lock()
+			delete(registry, "stats-test")
+			registryMu.Unlock()
+		})
+		builtin, err := New("builtin:stats-test")
+		require.NoError(t, err)
+		secondary := newMockExec(t, testFixtures, ExecOptions{})
+		var stats Stats
+
+		_, err = NewChain(builtin, secondary).Fetch(WithStats(context.Background(), &stats), requests)
+		require.NoError(t, err)
+		require.Len(t, stats.Fetches, 2)
+		assert.Equal(t, "builtin", stats.Fetches[0].Protocol)
+		assert.False(t, stats.Fetches[0].Fallback)
+		assert.Equal(t, 1, stats.Fetches[0].Failed)
+		assert.Equal(t, "interactive", stats.Fetches[1].Protocol)
+		assert.True(t, stats.Fetches[1].Fallback)
+		assert.Equal(t, 1, stats.Fetches[1].Secrets)
+		assert.Equal(t, []string{"PASSWORD", "USER", "USER"}, []string{stats.Secrets[0].Key, stats.Secrets[1].Key, stats.Secrets[2].Key})
+		assert.Equal(t, 1, stats.Processes)
+	})
+
+	t.Run("records nothing without Stats", func(t *testing.T) {
+		provider := newMockExec(t, testFixtures, ExecOptions{})
+		_, err := provider.Fetch(context.Background(), requests)
+		require.NoError(t, err)
+	})
+}
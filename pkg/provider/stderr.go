@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// StderrPolicy decides what it means for a provider to write to stderr.
+// Whatever the policy, the lines that don't fail a fetch are logged, at debug
+// level unless the policy is StderrLog, see ExecOptions.Stderr.
+type StderrPolicy string
+
+const (
+	// StderrFallback, the default, ends an interactive session on the first
+	// line written to stderr, and fetches the secrets again with one call
+	// each. A call with output on stderr only fails when the provider exits
+	// with an error.
+	StderrFallback StderrPolicy = "fallback"
+	// StderrLog never fails because of stderr output: providers only report
+	// failures with error lines in interactive mode and exit statuses.
+	StderrLog StderrPolicy = "log"
+	// StderrFail fails the whole fetch when a provider writes to stderr in
+	// interactive mode, and the secret when it does so in a single call.
+	StderrFail StderrPolicy = "fail"
+)
+
+// redacted replaces secret values in logged provider output.
+const redacted = "REDACTED"
+
+// stderrError reports a line a provider wrote to stderr.
+type stderrError struct {
+	line string
+}
+
+func (e *stderrError) Error() string {
+	return e.line
+}
+
+// stderrLog collects the lines a provider writes to stderr, so that they can
+// be logged once all the values they could leak have been fetched.
+type stderrLog struct {
+	provider string
+	// levels logs each line at the level it starts with, see stderrLevel.
+	// Otherwise the lines are debug messages.
+	levels bool
+
+	mu    sync.Mutex
+	lines []string
+}
+
+// add collects the lines of output.
+func (l *stderrLog) add(output string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			l.lines = append(l.lines, line)
+		}
+	}
+}
+
+// flush logs the collected lines, with the values of results redacted.
+func (l *stderrLog) flush(ctx context.Context, results []Result) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		level := slog.LevelDebug
+		if l.levels {
+			level = stderrLevel(line)
+		}
+		slog.Log(ctx, level, redact(line, results), "provider", l.provider)
+	}
+	l.lines = nil
+}
+
+// stderrLevel returns the level of a line of provider output from the word
+// it starts with, such as "ERROR:" or "[debug]". Other lines are warnings.
+func stderrLevel(line string) slog.Level {
+	word, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	word = strings.Trim(strings.ToUpper(word), "[]:")
+	switch word {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "INFO":
+		return slog.LevelInfo
+	case "ERROR", "FATAL":
+		return slog.LevelError
+	}
+	return slog.LevelWarn
+}
+
+// redact replaces the values of results found in line. Each line of a
+// multi-line value is replaced on its own. Short values are replaced too,
+// even inside words: a readable log isn't worth leaking a PIN.
+func redact(line string, results []Result) string {
+	var values []string
+	for _, result := range results {
+		for value := range strings.Lines(result.Value) {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	// Longest first, so that values containing others are replaced whole
+	slices.SortFunc(values, func(a, b string) int { return len(b) - len(a) })
+	for _, value := range values {
+		line = strings.ReplaceAll(line, value, redacted)
+	}
+	return line
+}
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStderrLevel(t *testing.T) {
+	for line, level := range map[string]slog.Level{
+		"DEBUG: connecting":      slog.LevelDebug,
+		"[info] token cached":    slog.LevelInfo,
+		"Error: token expired":   slog.LevelError,
+		"warning: deprecated":    slog.LevelWarn,
+		"please log in again":    slog.LevelWarn,
+		"  FATAL cannot connect": slog.LevelError,
+	} {
+		assert.Equal(t, level, stderrLevel(line), line)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	results := []Result{
+		{Key: "A", Value: "s3cr3t"},
+		{Key: "B", Value: "s3cr3t-longer"},
+		{Key: "C", Value: "line one\nline two\n"},
+		{Key: "D", Value: ""},
+	}
+
+	assert.Equal(t, "got REDACTED and REDACTED", redact("got s3cr3t and s3cr3t-longer", results))
+	assert.Equal(t, "REDACTED, REDACTED", redact("line one, line two", results))
+	assert.Equal(t, "nothing to hide", redact("nothing to hide", results))
+
+	t.Run("redacts short values", func(t *testing.T) {
+		short := []Result{{Key: "PIN", Value: "123"}}
+		assert.Equal(t, "pin REDACTED rejected", redact("pin 123 rejected", short))
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 
 	"github.com/cyberark/summon/pkg/atomicwriter"
 	filetemplates "github.com/cyberark/summon/pkg/file_templates"
@@ -60,13 +59,9 @@ func pushToWriter(
 	fileTemplate string,
 	fileSecrets []*filetemplates.Secret,
 ) error {
-	// Sort secrets by alias so .SecretsArray is lexicographically ordered in templates.
-	// Intended to make output deterministic for testing purposes and to provide a
-	// consistent experience for users.
-	sort.Slice(fileSecrets, func(i, j int) bool {
-		return fileSecrets[i].Alias < fileSecrets[j].Alias
-	})
-
+	// fileSecrets is expected to be ordered already (alphabetically or in
+	// declaration order, see SecretFile.orderedAliases), so .SecretsArray
+	// preserves the order it is given.
 	secretsMap := map[string]*filetemplates.Secret{}
 	for _, s := range fileSecrets {
 		secretsMap[s.Alias] = s
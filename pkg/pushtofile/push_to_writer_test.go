@@ -99,9 +99,9 @@ var writeToFileTestCases = []pushToWriterTestCase{
 {{- end -}}`,
 		secrets: []*filetemplates.Secret{
 			{Alias: "environment", Value: "prod"},
+			{Alias: "password", Value: "example-pass"},
 			{Alias: "url", Value: "https://example.com"},
 			{Alias: "username", Value: "example-user"},
-			{Alias: "password", Value: "example-pass"},
 		},
 		assert: assertGoodOutput(`environment: prod
 password: example-pass
@@ -124,9 +124,9 @@ Nested Template
 {{- end -}}`,
 		secrets: []*filetemplates.Secret{
 			{Alias: "environment", Value: "prod"},
+			{Alias: "password", Value: "example-pass"},
 			{Alias: "url", Value: "https://example.com"},
 			{Alias: "username", Value: "example-user"},
-			{Alias: "password", Value: "example-pass"},
 		},
 		assert: assertGoodOutput(`Nested Template
 Alias : environment
@@ -147,15 +147,16 @@ Value : example-user
 `),
 	},
 	{
-		// .SecretsArray is sorted lexicographically by alias regardless of input order.
-		description: "SecretsArray sorted iteration",
+		// .SecretsArray preserves the order of the secrets it is given;
+		// ordering is applied by SecretFile before pushing to the writer.
+		description: "SecretsArray preserves input order",
 		template:    "{{range .SecretsArray}}{{.Alias}}: {{.Value}}\n{{end}}",
 		secrets: []*filetemplates.Secret{
 			{Alias: "z", Value: "1"},
 			{Alias: "a", Value: "2"},
 			{Alias: "m", Value: "3"},
 		},
-		assert: assertGoodOutput("a: 2\nm: 3\nz: 1\n"),
+		assert: assertGoodOutput("z: 1\na: 2\nm: 3\n"),
 	},
 	{
 		// Smoke test to ensure summon template features do not break existing text/template blocks.
@@ -1,6 +1,8 @@
 package pushtofile
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -28,35 +30,67 @@ func (secretFile *SecretFile) SecretSpecs() secretsyml.SecretsMap {
 	return secretFile.FileConfig.Secrets.(secretsyml.SecretsMap)
 }
 
-func (secretFile *SecretFile) Write(providerResults []provider.Result) (absolutePath string, err error) {
+// Write renders the secret file for providerResults and writes it to its
+// path. Nothing is written once ctx is done.
+func (secretFile *SecretFile) Write(ctx context.Context, providerResults []provider.Result) (absolutePath string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	return secretFile.writeWithDeps(openFileAsWriteCloser, pushToWriter, providerResults)
 }
 
-func (secretFile *SecretFile) writeWithDeps(
-	depOpenWriteCloser openWriteCloserFunc,
-	depPushToWriter pushToWriterFunc,
-	providerResults []provider.Result,
-) (absolutePath string, err error) {
-	err = secretFile.validate()
+// Render returns the content of the secret file for providerResults without
+// writing it anywhere.
+func (secretFile *SecretFile) Render(providerResults []provider.Result) (content []byte, err error) {
+	fileTemplate, secrets, err := secretFile.prepare(providerResults)
 	if err != nil {
+		return nil, err
+	}
+
+	maskError := fmt.Errorf("failed to execute template, with secret values, on push to file %q", secretFile.FileConfig.Path)
+	defer func() {
+		if r := recover(); r != nil {
+			content, err = nil, maskError
+		}
+	}()
+
+	var buf bytes.Buffer
+	if err := pushToWriter(&buf, secretFile.FileConfig.Path, fileTemplate, secrets); err != nil {
+		return nil, maskError
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteContent writes content, as returned by Render, to the secret file's
+// path with its configured permissions and overwrite setting. Nothing is
+// written once ctx is done.
+func (secretFile *SecretFile) WriteContent(ctx context.Context, content []byte) (absolutePath string, err error) {
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
-	// Make sure all the secret specs are accounted for
-	secrets, err := validateSecretsAgainstSpecs(providerResults, secretFile.SecretSpecs(), secretFile.Ignores, secretFile.IgnoreAll)
+	absolutePath, err = secretFile.absoluteFilePath()
 	if err != nil {
 		return "", err
 	}
 
-	// Determine file template from
-	// 1. File template
-	// 2. File format
-	// 3. Secret specs (user to validate file template)
-	fileTemplate, err := maybeFileTemplateFromFormat(
-		secretFile.FileConfig.Template,
-		secretFile.FileConfig.Format,
-		secretFile.SecretSpecs(),
-	)
+	wc, err := openFileAsWriteCloser(absolutePath, secretFile.filePermissions(), secretFile.FileConfig.Overwrite)
+	if err != nil {
+		return "", err
+	}
+	if _, err := wc.Write(content); err != nil {
+		_ = wc.Close()
+		return "", err
+	}
+	return absolutePath, wc.Close()
+}
+
+func (secretFile *SecretFile) writeWithDeps(
+	depOpenWriteCloser openWriteCloserFunc,
+	depPushToWriter pushToWriterFunc,
+	providerResults []provider.Result,
+) (absolutePath string, err error) {
+	fileTemplate, secrets, err := secretFile.prepare(providerResults)
 	if err != nil {
 		return "", err
 	}
@@ -66,11 +100,7 @@ func (secretFile *SecretFile) writeWithDeps(
 	if err != nil {
 		return "", err
 	}
-	filePermissions := secretFile.FileConfig.Permissions
-	if filePermissions == 0 {
-		filePermissions = defaultFilePermissions
-	}
-	wc, err := depOpenWriteCloser(absolutePath, filePermissions, secretFile.FileConfig.Overwrite)
+	wc, err := depOpenWriteCloser(absolutePath, secretFile.filePermissions(), secretFile.FileConfig.Overwrite)
 	if err != nil {
 		return "", err
 	}
@@ -96,6 +126,44 @@ func (secretFile *SecretFile) writeWithDeps(
 	return absolutePath, err
 }
 
+// prepare validates the secret file against providerResults and returns the
+// template to render along with the secrets to render it with.
+func (secretFile *SecretFile) prepare(providerResults []provider.Result) (string, []*filetemplates.Secret, error) {
+	err := secretFile.validate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Make sure all the secret specs are accounted for
+	secrets, err := validateSecretsAgainstSpecs(providerResults, secretFile.SecretSpecs(), secretFile.Ignores, secretFile.IgnoreAll)
+	if err != nil {
+		return "", nil, err
+	}
+	secrets = orderSecrets(secrets, secretFile.orderedAliases())
+
+	// Determine file template from
+	// 1. File template
+	// 2. File format
+	// 3. Secret specs (user to validate file template)
+	fileTemplate, err := maybeFileTemplateFromFormat(
+		secretFile.FileConfig.Template,
+		secretFile.FileConfig.Format,
+		secretFile.SecretSpecs(),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fileTemplate, secrets, nil
+}
+
+func (secretFile *SecretFile) filePermissions() os.FileMode {
+	if secretFile.FileConfig.Permissions == 0 {
+		return defaultFilePermissions
+	}
+	return secretFile.FileConfig.Permissions
+}
+
 func (secretFile *SecretFile) absoluteFilePath() (string, error) {
 	filePath := secretFile.FileConfig.Path
 
@@ -197,7 +265,7 @@ func validateSecretsAgainstSpecs(
 
 		// Handle provider errors with ignore logic
 		if result.Error != nil {
-			if shouldIgnoreAlias(alias, ignores, ignoreAll) {
+			if shouldIgnoreAlias(alias, ignores, ignoreAll) || specs[alias].Optional {
 				ignoredAliases[alias] = struct{}{}
 				continue
 			}
@@ -253,6 +321,28 @@ func validateSecretsAgainstSpecs(
 	return secrets, nil
 }
 
+// orderedAliases returns the file's secret aliases in the order configured
+// by the file's `order` field.
+func (secretFile *SecretFile) orderedAliases() []string {
+	return secretsyml.OrderedKeys(
+		secretFile.SecretSpecs(),
+		secretFile.FileConfig.Keys,
+		secretFile.FileConfig.Order,
+	)
+}
+
+// orderSecrets sorts secrets to match the position of their alias in aliases.
+func orderSecrets(secrets []*filetemplates.Secret, aliases []string) []*filetemplates.Secret {
+	positions := make(map[string]int, len(aliases))
+	for i, alias := range aliases {
+		positions[alias] = i
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return positions[secrets[i].Alias] < positions[secrets[j].Alias]
+	})
+	return secrets
+}
+
 func shouldIgnoreAlias(alias string, ignores []string, ignoreAll bool) bool {
 	if ignoreAll {
 		return true
@@ -1,6 +1,7 @@
 package pushtofile
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -556,7 +557,7 @@ func TestSecretFile_Write(t *testing.T) {
 					Overwrite:   true, // Allow overwrite since multiple tests write to same path
 				},
 			}
-			_, err = file.Write(commonResults)
+			_, err = file.Write(context.Background(), commonResults)
 			assert.NoError(t, err)
 
 			// Read file contents and metadata
@@ -573,6 +574,44 @@ func TestSecretFile_Write(t *testing.T) {
 		})
 	}
 
+	t.Run("secrets are rendered in the configured order", func(t *testing.T) {
+		results := createResults(map[string]string{
+			"ZULU":  "z",
+			"ALPHA": "a",
+			"MIKE":  "m",
+		})
+		specs := secretsyml.SecretsMap{
+			"ZULU":  secretsyml.SecretSpec{Path: "z"},
+			"ALPHA": secretsyml.SecretSpec{Path: "a"},
+			"MIKE":  secretsyml.SecretSpec{Path: "m"},
+		}
+
+		for _, order := range []struct {
+			order    string
+			expected string
+		}{
+			{secretsyml.OrderAlphabetical, "ALPHA=\"a\"\nMIKE=\"m\"\nZULU=\"z\""},
+			{secretsyml.OrderDeclared, "ZULU=\"z\"\nALPHA=\"a\"\nMIKE=\"m\""},
+		} {
+			absoluteFilePath := filepath.Join(dir, "ordered-"+order.order)
+			file := SecretFile{
+				FileConfig: secretsyml.FileConfig{
+					Path:    absoluteFilePath,
+					Format:  "dotenv",
+					Order:   order.order,
+					Keys:    []string{"ZULU", "ALPHA", "MIKE"},
+					Secrets: specs,
+				},
+			}
+			_, err := file.Write(context.Background(), results)
+			assert.NoError(t, err)
+
+			contentBytes, err := os.ReadFile(absoluteFilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, order.expected, string(contentBytes))
+		}
+	})
+
 	t.Run("failure to mkdir", func(t *testing.T) {
 		file := SecretFile{
 			FileConfig: secretsyml.FileConfig{
@@ -583,7 +622,7 @@ func TestSecretFile_Write(t *testing.T) {
 				Secrets:     goodSecretSpecs(),
 			},
 		}
-		_, err = file.Write(commonResults)
+		_, err = file.Write(context.Background(), commonResults)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unable to mkdir")
 	})
@@ -598,7 +637,7 @@ func TestSecretFile_Write(t *testing.T) {
 				Secrets:     goodSecretSpecs(),
 			},
 		}
-		_, err = file.Write(commonResults)
+		_, err = file.Write(context.Background(), commonResults)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "must contain a filename")
 	})
@@ -619,7 +658,7 @@ func TestSecretFile_Write(t *testing.T) {
 				Overwrite: false,
 			},
 		}
-		_, err = file.Write(commonResults)
+		_, err = file.Write(context.Background(), commonResults)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "already exists")
 		assert.Contains(t, err.Error(), "overwrite is not enabled")
@@ -646,7 +685,7 @@ func TestSecretFile_Write(t *testing.T) {
 				Overwrite:   true,
 			},
 		}
-		_, err = file.Write(commonResults)
+		_, err = file.Write(context.Background(), commonResults)
 		assert.NoError(t, err)
 
 		// Content should be updated
@@ -668,7 +707,7 @@ func TestSecretFile_Write(t *testing.T) {
 				Overwrite:   false,
 			},
 		}
-		_, err := file.Write(commonResults)
+		_, err := file.Write(context.Background(), commonResults)
 		assert.NoError(t, err)
 
 		// File should be created with correct content
@@ -678,6 +717,89 @@ func TestSecretFile_Write(t *testing.T) {
 	})
 }
 
+func TestSecretFile_RenderAndWriteContent(t *testing.T) {
+	dir := t.TempDir()
+	results := createResults(map[string]string{
+		"alias1": "value1",
+		"alias2": "value2",
+	})
+
+	t.Run("renders without writing", func(t *testing.T) {
+		filePath := filepath.Join(dir, "rendered")
+		file := SecretFile{
+			FileConfig: secretsyml.FileConfig{
+				Path:    filePath,
+				Format:  "dotenv",
+				Secrets: goodSecretSpecs(),
+			},
+		}
+
+		content, err := file.Render(results)
+		assert.NoError(t, err)
+		assert.Equal(t, "alias1=\"value1\"\nalias2=\"value2\"", string(content))
+		assert.NoFileExists(t, filePath)
+	})
+
+	t.Run("fails to render when a secret is missing", func(t *testing.T) {
+		file := SecretFile{
+			FileConfig: secretsyml.FileConfig{
+				Path:    filepath.Join(dir, "missing"),
+				Format:  "dotenv",
+				Secrets: goodSecretSpecs(),
+			},
+		}
+
+		_, err := file.Render(createResults(map[string]string{"alias1": "value1"}))
+		assert.ErrorContains(t, err, "some secret specs are not present in secrets")
+	})
+
+	t.Run("writes rendered content with configured permissions", func(t *testing.T) {
+		filePath := filepath.Join(dir, "path", "to", "written")
+		file := SecretFile{
+			FileConfig: secretsyml.FileConfig{
+				Path:        filePath,
+				Format:      "dotenv",
+				Permissions: 0o640,
+				Secrets:     goodSecretSpecs(),
+			},
+		}
+
+		written, err := file.WriteContent(context.Background(), []byte("content"))
+		assert.NoError(t, err)
+		assert.Equal(t, filePath, written)
+
+		content, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		assert.Equal(t, "content", string(content))
+		info, err := os.Stat(filePath)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0o640, info.Mode())
+
+		// A second write needs overwrite to be enabled
+		_, err = file.WriteContent(context.Background(), []byte("other content"))
+		assert.ErrorContains(t, err, "overwrite is not enabled")
+	})
+
+	t.Run("doesn't write once the context is done", func(t *testing.T) {
+		filePath := filepath.Join(dir, "cancelled")
+		file := SecretFile{
+			FileConfig: secretsyml.FileConfig{
+				Path:    filePath,
+				Format:  "dotenv",
+				Secrets: goodSecretSpecs(),
+			},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := file.WriteContent(ctx, []byte("content"))
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = file.Write(ctx, results)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NoFileExists(t, filePath)
+	})
+}
+
 func TestSecretFile_absoluteFilePath(t *testing.T) {
 	pwd, _ := os.Getwd()
 
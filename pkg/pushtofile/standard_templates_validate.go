@@ -93,10 +93,21 @@ func validatePropertyVarName(name string) error {
 	return nil
 }
 
+// bashVarNameExplanation describes the names matched by bashVarNameRegex.
+const bashVarNameExplanation = "variable names can only include alphanumerics and underscores, with first char being a non-digit"
+
 func validateBashVarName(name string) error {
 	if !bashVarNameRegex.MatchString(name) {
-		explanation := "variable names can only include alphanumerics and underscores, with first char being a non-digit"
-		return fmt.Errorf("invalid alias %q: %s", name, explanation)
+		return fmt.Errorf("invalid alias %q: %s", name, bashVarNameExplanation)
+	}
+	return nil
+}
+
+// ValidateEnvVarName checks that name is usable as an environment variable
+// name, by the same rules as the keys of bash and dotenv files.
+func ValidateEnvVarName(name string) error {
+	if !bashVarNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q: %s", name, bashVarNameExplanation)
 	}
 	return nil
 }
@@ -0,0 +1,84 @@
+package secretsyml
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// AliasTarget follows the aliases starting at key and returns the name of
+// the secret they end at. Names are looked up in secrets and, when missing
+// there or already visited, in fallback: the environment secrets, for
+// aliases declared in a summon.files entry. inFallback reports whether the
+// target was found in fallback.
+func AliasTarget(key string, secrets, fallback SecretsMap) (target string, inFallback bool, err error) {
+	scope := secrets
+	if _, ok := scope[key]; !ok {
+		return "", false, fmt.Errorf("undeclared secret %s", key)
+	}
+
+	chain := []string{key}
+	visited := map[string]bool{key: true}
+	for {
+		spec := scope[key]
+		if !spec.IsAlias() {
+			return key, inFallback, nil
+		}
+
+		next := spec.Path
+		_, inScope := scope[next]
+		_, inFallbackScope := fallback[next]
+		switch {
+		case inScope && !visited[next]:
+		case !inFallback && inFallbackScope:
+			// e.g. a file secret aliasing the environment secret of the same name
+			scope, inFallback = fallback, true
+			visited = map[string]bool{}
+		case inScope:
+			return "", false, fmt.Errorf("alias cycle: %s -> %s", strings.Join(chain, " -> "), next)
+		default:
+			return "", false, fmt.Errorf("alias %s refers to undeclared secret %s", key, next)
+		}
+
+		key = next
+		visited[key] = true
+		chain = append(chain, key)
+	}
+}
+
+// resolveAliasOptions checks that every alias of config refers to a declared
+// secret without cycles, and makes aliases optional when their target is.
+func resolveAliasOptions(config *ParsedConfig) error {
+	if err := resolveAliasesIn(config.EnvSecrets, nil); err != nil {
+		return err
+	}
+	for _, file := range config.Files {
+		if err := resolveAliasesIn(file.Secrets.(SecretsMap), config.EnvSecrets); err != nil {
+			return fmt.Errorf("file config for path %q: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// resolveAliasesIn applies resolveAliasOptions to the aliases of secrets.
+func resolveAliasesIn(secrets, fallback SecretsMap) error {
+	for _, key := range slices.Sorted(maps.Keys(secrets)) {
+		spec := secrets[key]
+		if !spec.IsAlias() {
+			continue
+		}
+
+		target, inFallback, err := AliasTarget(key, secrets, fallback)
+		if err != nil {
+			return err
+		}
+		if inFallback {
+			spec.Optional = fallback[target].Optional
+		} else {
+			spec.Optional = secrets[target].Optional
+		}
+		secrets[key] = spec
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package secretsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAlias(t *testing.T) {
+	t.Run("parses !alias entries", func(t *testing.T) {
+		config, err := ParseFromString("DATABASE_PASSWORD: !var db/$env/password\nPGPASSWORD: !alias DATABASE_PASSWORD\n", "", map[string]string{"env": "prod"})
+		require.NoError(t, err)
+		assert.Equal(t, SecretSpec{Tags: []YamlTag{Alias}, Path: "DATABASE_PASSWORD"}, config.EnvSecrets["PGPASSWORD"])
+		assert.Equal(t, "db/prod/password", config.EnvSecrets["DATABASE_PASSWORD"].Path)
+	})
+
+	t.Run("parses alias structured entries", func(t *testing.T) {
+		config, err := ParseFromString("summon.version: 2\nA: {path: a}\nB: {path: A, type: alias}\n", "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, SecretSpec{Tags: []YamlTag{Alias}, Path: "A"}, config.EnvSecrets["B"])
+
+		_, err = ParseFromString("summon.version: 2\nA: {path: a}\nB: {path: A, type: alias, file: true}\n", "", nil)
+		assert.ErrorContains(t, err, "alias entries only take a path")
+	})
+
+	t.Run("aliases are optional when their target is", func(t *testing.T) {
+		config, err := ParseFromString("summon.version: 2\nA: {path: a, optional: true}\nB: !alias A\nC: !alias B\n", "", nil)
+		require.NoError(t, err)
+		assert.True(t, config.EnvSecrets["C"].Optional)
+	})
+
+	t.Run("file secrets may alias environment secrets", func(t *testing.T) {
+		config, err := ParseFromString(`
+PASSWORD: !var db/password
+summon.files:
+  - path: db.env
+    secrets:
+      PASSWORD: !alias PASSWORD
+      PASS: !alias PASSWORD
+`, "", nil)
+		require.NoError(t, err)
+
+		secrets := config.Files[0].Secrets.(SecretsMap)
+		for _, key := range []string{"PASSWORD", "PASS"} {
+			target, inFallback, err := AliasTarget(key, secrets, config.EnvSecrets)
+			require.NoError(t, err)
+			assert.Equal(t, "PASSWORD", target)
+			assert.True(t, inFallback)
+		}
+	})
+
+	testCases := []struct {
+		description string
+		content     string
+		err         string
+	}{
+		{
+			description: "rejects alias cycles",
+			content:     "A: !alias B\nB: !alias C\nC: !alias A\n",
+			err:         "alias cycle: A -> B -> C -> A",
+		},
+		{
+			description: "rejects self-references",
+			content:     "A: !alias A\n",
+			err:         "alias cycle: A -> A",
+		},
+		{
+			description: "rejects undeclared targets",
+			content:     "A: !alias B\n",
+			err:         "alias A refers to undeclared secret B",
+		},
+		{
+			description: "rejects undeclared targets in files",
+			content:     "summon.files:\n  - path: a.env\n    secrets:\n      A: !alias B\n",
+			err:         `file config for path "a.env": alias A refers to undeclared secret B`,
+		},
+		{
+			description: "rejects combined tags",
+			content:     "A: a\nB: !alias:file A\n",
+			err:         `failed to parse secret "B": !alias can't be combined with other tags`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			_, err := ParseFromString(tc.content, "", nil)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package secretsyml
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// constraintRegex matches value constraint segments of a YAML tag, e.g.
+// `min_len=32` in `!var:min_len=32`. Arguments run up to the next `:`.
+var constraintRegex = regexp.MustCompile(`(?:^!|:)(min_len|max_len|type)=([^:]*)`)
+
+// matchConstraintRegex matches the `match=` constraint of a YAML tag, whose
+// pattern runs to the end of the tag (or to its default value) so that it
+// may contain `:`.
+var matchConstraintRegex = regexp.MustCompile(`(?:^!|:)match=(.*)$`)
+
+// Value types supported by the `type=` constraint.
+var constraintTypes = map[string]func(string) bool{
+	"int": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	},
+	"url": func(value string) bool {
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	},
+	"pem": func(value string) bool {
+		block, _ := pem.Decode([]byte(value))
+		return block != nil
+	},
+}
+
+// Constraint is an expectation on a secret value, declared in the YAML tag
+// as `name=arg` (e.g. `!var:min_len=32`).
+type Constraint struct {
+	Name string // One of "match", "min_len", "max_len" or "type".
+	Arg  string // The constraint argument as written in secrets.yml.
+
+	pattern *regexp.Regexp
+	length  int
+}
+
+// String returns the constraint as written in secrets.yml.
+func (c Constraint) String() string {
+	return c.Name + "=" + c.Arg
+}
+
+// newConstraint validates the argument of a constraint and prepares it for use.
+func newConstraint(name, arg string) (Constraint, error) {
+	c := Constraint{Name: name, Arg: arg}
+
+	switch name {
+	case "match":
+		// Patterns must match the whole value. Anchoring implicitly also
+		// sidesteps `^`, which YAML does not allow in tags.
+		pattern, err := regexp.Compile(`^(?:` + arg + `)$`)
+		if err != nil {
+			return c, fmt.Errorf("invalid match pattern %q: %w", arg, err)
+		}
+		c.pattern = pattern
+	case "min_len", "max_len":
+		length, err := strconv.Atoi(arg)
+		if err != nil || length < 0 {
+			return c, fmt.Errorf("invalid %s value %q: expected a non-negative integer", name, arg)
+		}
+		c.length = length
+	case "type":
+		if _, ok := constraintTypes[arg]; !ok {
+			return c, fmt.Errorf("unknown type constraint %q (expected int, url or pem)", arg)
+		}
+	default:
+		return c, fmt.Errorf("unknown constraint: %s", name)
+	}
+
+	return c, nil
+}
+
+// check reports whether value satisfies the constraint.
+func (c Constraint) check(value string) bool {
+	switch c.Name {
+	case "match":
+		return c.pattern.MatchString(value)
+	case "min_len":
+		return utf8.RuneCountInString(value) >= c.length
+	case "max_len":
+		return utf8.RuneCountInString(value) <= c.length
+	case "type":
+		return constraintTypes[c.Arg](value)
+	default:
+		return false
+	}
+}
+
+// CheckValue verifies value against every constraint of the spec. The
+// returned error never includes the value itself.
+func (spec *SecretSpec) CheckValue(value string) error {
+	for _, c := range spec.Constraints {
+		if !c.check(value) {
+			return fmt.Errorf("value does not satisfy constraint %s", c)
+		}
+	}
+	return nil
+}
+
+// extractConstraints records the value constraints found in tag on the spec
+// and returns tag with them removed. The `default='...'` segment is left
+// untouched so that defaults may contain constraint-like text.
+func (spec *SecretSpec) extractConstraints(tag string) (string, error) {
+	head, defaultSegment, tail := tag, "", ""
+	if span := defaultValueRegex.FindStringIndex(tag); span != nil {
+		head, defaultSegment, tail = tag[:span[0]], tag[span[0]:span[1]], tag[span[1]:]
+		// Keep the separator out of a match pattern ending the head
+		if strings.HasSuffix(head, ":") {
+			head, defaultSegment = head[:len(head)-1], ":"+defaultSegment
+		}
+	}
+
+	var err error
+	strip := func(part string) string {
+		var pattern []string
+		if span := matchConstraintRegex.FindStringSubmatchIndex(part); span != nil {
+			pattern = []string{"", "match", part[span[2]:span[3]]}
+			part = part[:span[0]]
+		}
+		for _, match := range append(constraintRegex.FindAllStringSubmatch(part, -1), pattern) {
+			if match == nil {
+				continue
+			}
+			c, cerr := newConstraint(match[1], match[2])
+			if cerr != nil && err == nil {
+				err = cerr
+			}
+			spec.Constraints = append(spec.Constraints, c)
+		}
+		return constraintRegex.ReplaceAllString(part, "")
+	}
+
+	stripped := strip(head) + defaultSegment + strip(tail)
+	if err != nil {
+		return "", err
+	}
+	return stripped, nil
+}
@@ -0,0 +1,120 @@
+package secretsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPEM = `-----BEGIN CERTIFICATE-----
+MIIBszCCAVmgAwIBAgIUQ0Vf
+-----END CERTIFICATE-----`
+
+func TestParseConstraints(t *testing.T) {
+	t.Run("Constraints are parsed alongside tags and defaults", func(t *testing.T) {
+		input := `
+PORT: !var:type=int:default='8080' app/port
+TOKEN: !var:min_len=4:max_len=8 app/token
+PIN: !var:match=[0-9]+ app/pin
+PLAIN: !var app/plain`
+
+		config, err := ParseFromString(input, "", nil)
+		assert.NoError(t, err)
+
+		port := config.EnvSecrets["PORT"]
+		assert.True(t, port.IsVar())
+		assert.False(t, port.IsLiteral())
+		assert.Equal(t, "8080", port.DefaultValue)
+		assert.Equal(t, "app/port", port.Path)
+		if assert.Len(t, port.Constraints, 1) {
+			assert.Equal(t, "type=int", port.Constraints[0].String())
+		}
+
+		token := config.EnvSecrets["TOKEN"]
+		assert.Equal(t, []YamlTag{Var}, token.Tags)
+		assert.Len(t, token.Constraints, 2)
+
+		pin := config.EnvSecrets["PIN"]
+		if assert.Len(t, pin.Constraints, 1) {
+			assert.Equal(t, "[0-9]+", pin.Constraints[0].Arg)
+		}
+
+		assert.Empty(t, config.EnvSecrets["PLAIN"].Constraints)
+	})
+
+	t.Run("Match patterns may contain colons", func(t *testing.T) {
+		config, err := ParseFromString("URL: !var:min_len=8:match=https?://[a-z.]+:[0-9]+:default='http://localhost:80' app/url", "", nil)
+		assert.NoError(t, err)
+		url := config.EnvSecrets["URL"]
+		assert.Equal(t, "http://localhost:80", url.DefaultValue)
+		if assert.Len(t, url.Constraints, 2) {
+			assert.Equal(t, "min_len=8", url.Constraints[0].String())
+			assert.Equal(t, "match=https?://[a-z.]+:[0-9]+", url.Constraints[1].String())
+		}
+		assert.NoError(t, url.CheckValue("https://conjur.example.com:443"))
+		assert.Error(t, url.CheckValue("https://conjur.example.com"))
+	})
+
+	t.Run("Default values may contain constraint-like text", func(t *testing.T) {
+		config, err := ParseFromString(`A: !var:default='type=int' path`, "", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "type=int", config.EnvSecrets["A"].DefaultValue)
+		assert.Empty(t, config.EnvSecrets["A"].Constraints)
+	})
+
+	errorTests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{"Unknown type", `A: !var:type=uuid path`, `unknown type constraint "uuid"`},
+		{"Invalid length", `A: !var:min_len=abc path`, `invalid min_len value "abc"`},
+		{"Invalid pattern", `A: !var:match=[0-9 path`, `invalid match pattern "[0-9"`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFromString(tt.input, "", nil)
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestSecretSpec_CheckValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint string
+		value      string
+		valid      bool
+	}{
+		{"match whole value", "match=[0-9]+", "12345", true},
+		{"match is anchored", "match=[0-9]+", "12345abc", false},
+		{"min_len satisfied", "min_len=3", "abc", true},
+		{"min_len violated", "min_len=4", "abc", false},
+		{"max_len satisfied", "max_len=3", "abc", true},
+		{"max_len violated", "max_len=2", "abc", false},
+		{"length counts characters", "max_len=1", "🐶", true},
+		{"type int", "type=int", "-42", true},
+		{"type int rejects text", "type=int", "42a", false},
+		{"type url", "type=url", "https://example.com/path", true},
+		{"type url requires a host", "type=url", "not a url", false},
+		{"type pem", "type=pem", testPEM, true},
+		{"type pem rejects text", "type=pem", "BEGIN CERTIFICATE", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := SecretSpec{}
+			_, err := spec.extractConstraints("!var:" + tt.constraint)
+			assert.NoError(t, err)
+
+			err = spec.CheckValue(tt.value)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, "value does not satisfy constraint "+tt.constraint)
+			assert.NotContains(t, err.Error(), tt.value)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package secretsyml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConvertToV2 rewrites secrets.yml content in the version 2 format: values
+// carrying tags such as `!var:file` become structured entries and
+// summon.version is set to 2. Comments, ordering and untagged values are
+// preserved.
+func ConvertToV2(content []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("nothing to convert: secrets.yml is empty")
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid YAML structure: expected mapping")
+	}
+
+	version, err := configVersion(root)
+	if err != nil {
+		return nil, err
+	}
+
+	hasVersion := false
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		switch key.Value {
+		case versionKey:
+			hasVersion = true
+			value.Value = strconv.Itoa(latestVersion)
+		case "summon.files":
+			if value.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("summon.files must be a sequence/array")
+			}
+			for _, fileNode := range value.Content {
+				if secrets := mappingValue(fileNode, "secrets"); secrets != nil {
+					if err := convertSecretsNode(secrets, version); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			if err := convertSecretOrSection(key.Value, value, version); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !hasVersion {
+		versionKeyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: versionKey}
+		versionNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(latestVersion)}
+		if len(root.Content) > 0 {
+			// Keep a leading comment at the top of the document
+			versionKeyNode.HeadComment = root.Content[0].HeadComment
+			root.Content[0].HeadComment = ""
+		}
+		root.Content = append([]*yaml.Node{versionKeyNode, versionNode}, root.Content...)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// convertSecretsNode converts every entry of a secrets mapping, descending
+// into environment sections.
+func convertSecretsNode(node *yaml.Node, version int) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected mapping node for secrets, got kind %d", node.Kind)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if err := convertSecretOrSection(node.Content[i].Value, node.Content[i+1], version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertSecretOrSection converts a single entry, or each entry of an
+// environment section. Whether a mapping is an entry or a section is decided
+// by the version of the file being converted: before version 2, every
+// mapping is a section.
+func convertSecretOrSection(key string, node *yaml.Node, version int) error {
+	if node.Kind == yaml.MappingNode {
+		if version >= 2 && isEntryNode(node) {
+			return nil
+		}
+		if version < 2 && isEntryNode(node) {
+			return fmt.Errorf("failed to convert section %q: its keys are all entry fields, so version 2 would read it as a structured entry", key)
+		}
+		return convertSecretsNode(node, version)
+	}
+
+	if err := convertSecretNode(node); err != nil {
+		return fmt.Errorf("failed to convert secret %q: %w", key, err)
+	}
+	return nil
+}
+
+// convertSecretNode replaces a tagged scalar with the equivalent structured
+// entry. Untagged scalars are left as they are.
+func convertSecretNode(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode || !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return nil
+	}
+
+	var spec SecretSpec
+	if err := spec.setYAML(node.Tag, node.Value); err != nil {
+		return err
+	}
+	// default='...' runs to the last quote of the tag, so a quote followed by
+	// a tag separator may have ended the default earlier than parsed.
+	if strings.Contains(spec.DefaultValue, "':") {
+		return fmt.Errorf("ambiguous default value %q in tag %s, write this entry by hand", spec.DefaultValue, node.Tag)
+	}
+
+	pathNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: node.Value}
+
+	// A plain literal needs no entry, just the untagged value
+	if !spec.IsVar() && !spec.IsFile() && !spec.IsAlias() && spec.DefaultValue == "" && len(spec.Constraints) == 0 {
+		copyComments(pathNode, node)
+		*node = *pathNode
+		return nil
+	}
+
+	entry := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+	addField := func(name string, value *yaml.Node) {
+		entry.Content = append(entry.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, value)
+	}
+
+	addField("path", pathNode)
+	switch {
+	case spec.IsAlias():
+		addField("type", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "alias"})
+	case !spec.IsVar():
+		addField("type", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "literal"})
+	}
+	if spec.DefaultValue != "" {
+		addField("default", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: spec.DefaultValue})
+	}
+	if spec.IsFile() {
+		addField("file", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+	}
+	if len(spec.Constraints) > 0 {
+		constraints := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, c := range spec.Constraints {
+			constraints.Content = append(constraints.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.String()})
+		}
+		addField("constraints", constraints)
+	}
+
+	copyComments(entry, node)
+	*node = *entry
+	return nil
+}
+
+// copyComments carries the comments attached to from over to to.
+func copyComments(to, from *yaml.Node) {
+	to.HeadComment = from.HeadComment
+	to.LineComment = from.LineComment
+	to.FootComment = from.FootComment
+}
+
+// mappingValue returns the value for key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package secretsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertToV2(t *testing.T) {
+	t.Run("Rewrites tagged values as structured entries", func(t *testing.T) {
+		input := `# Secrets for the app
+common:
+  DB_USER: !var:default='admin' db/user # the user
+  PLAIN: hello
+prod:
+  DB_PASS: !var:file:min_len=8 prod/db/pass
+  CONTENT: !file my content
+  LITERAL: !str lit
+  PGPASS: !alias DB_PASS
+summon.files:
+  - path: out.yml
+    secrets:
+      TOKEN: !var prod/token
+`
+		expected := `# Secrets for the app
+summon.version: 2
+common:
+  DB_USER: {path: db/user, default: admin} # the user
+  PLAIN: hello
+prod:
+  DB_PASS: {path: prod/db/pass, file: true, constraints: [min_len=8]}
+  CONTENT: {path: my content, type: literal, file: true}
+  LITERAL: lit
+  PGPASS: {path: DB_PASS, type: alias}
+summon.files:
+  - path: out.yml
+    secrets:
+      TOKEN: {path: prod/token}
+`
+		converted, err := ConvertToV2([]byte(input))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(converted))
+	})
+
+	t.Run("Converted files parse identically", func(t *testing.T) {
+		input := `
+common:
+  DB_USER: !var:default='admin' db/user
+prod:
+  DB_PASS: !var:file prod/db/pass
+  PORT: !var:type=int prod/port
+`
+		converted, err := ConvertToV2([]byte(input))
+		assert.NoError(t, err)
+
+		original, err := ParseFromString(input, "prod", nil)
+		assert.NoError(t, err)
+		reparsed, err := ParseFromString(string(converted), "prod", nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, original.EnvSecrets, reparsed.EnvSecrets)
+		assert.Equal(t, original.EnvKeys, reparsed.EnvKeys)
+	})
+
+	t.Run("Converting a version 2 file is a no-op", func(t *testing.T) {
+		input := "summon.version: 2\nKEY: {path: a/b}\n"
+		converted, err := ConvertToV2([]byte(input))
+		assert.NoError(t, err)
+		assert.Equal(t, input, string(converted))
+	})
+
+	t.Run("Sections are never read as entries in version 1 files", func(t *testing.T) {
+		input := "prod:\n  path: !var prod/path\n  type: !var prod/type\n"
+
+		original, err := ParseFromString(input, "prod", nil)
+		assert.NoError(t, err)
+		assert.Len(t, original.EnvSecrets, 2)
+
+		_, err = ConvertToV2([]byte(input))
+		assert.EqualError(t, err, `failed to convert section "prod": its keys are all entry fields, so version 2 would read it as a structured entry`)
+	})
+
+	t.Run("Defaults that contain a single quote", func(t *testing.T) {
+		converted, err := ConvertToV2([]byte("KEY: !var:default='it's' a/b\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "summon.version: 2\nKEY: {path: a/b, default: it's}\n", string(converted))
+
+		_, err = ConvertToV2([]byte("KEY: !var:default='it':file' a/b\n"))
+		assert.EqualError(t, err, `failed to convert secret "KEY": ambiguous default value "it':file" in tag !var:default='it':file', write this entry by hand`)
+	})
+
+	t.Run("Empty input is an error", func(t *testing.T) {
+		_, err := ConvertToV2([]byte(""))
+		assert.EqualError(t, err, "nothing to convert: secrets.yml is empty")
+	})
+}
@@ -0,0 +1,228 @@
+package secretsyml
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kinds of ConfigDiff changes.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// ConfigDiff is the structural difference between two parsed
+// configurations. It only describes the configurations themselves: no
+// secret is fetched to compute it.
+type ConfigDiff struct {
+	Secrets []SecretChange `json:"secrets"` // Changes to environment variable secrets
+	Files   []FileChange   `json:"files"`   // Changes to summon.files entries
+}
+
+// SecretChange is a secret that was added, removed or changed.
+type SecretChange struct {
+	Key    string          `json:"key"`
+	Change string          `json:"change"`
+	From   *SecretSnapshot `json:"from,omitempty"` // Unset for added secrets
+	To     *SecretSnapshot `json:"to,omitempty"`   // Unset for removed secrets
+}
+
+// SecretSnapshot describes a secret as declared, e.g. for `!var:file path`
+// a Tag of "!var:file" and a Path of "path". Literal values have no tag.
+type SecretSnapshot struct {
+	Tag         string   `json:"tag"`
+	Path        string   `json:"path"`
+	Default     string   `json:"default,omitempty"`
+	Provider    string   `json:"provider,omitempty"`
+	Optional    bool     `json:"optional,omitempty"`
+	Constraints []string `json:"constraints,omitempty"`
+}
+
+// FileChange is a summon.files entry, identified by its path, that was
+// added, removed or changed.
+type FileChange struct {
+	Path    string         `json:"path"`
+	Change  string         `json:"change"`
+	Fields  []FieldChange  `json:"fields,omitempty"`  // Changed settings, e.g. format
+	Secrets []SecretChange `json:"secrets,omitempty"` // Changes to the secrets of the file
+}
+
+// FieldChange is a summon.files setting whose value changed.
+type FieldChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Empty reports whether the configurations compared are equivalent.
+func (d ConfigDiff) Empty() bool {
+	return len(d.Secrets) == 0 && len(d.Files) == 0
+}
+
+// Diff compares two parsed configurations.
+func Diff(from, to *ParsedConfig) ConfigDiff {
+	// Empty lists rather than nil ones, so that JSON output has arrays
+	diff := ConfigDiff{
+		Secrets: append([]SecretChange{}, diffSecrets(from.EnvSecrets, to.EnvSecrets)...),
+		Files:   []FileChange{},
+	}
+
+	fromFiles, toFiles := filesByPath(from.Files), filesByPath(to.Files)
+	for _, path := range unionKeys(fromFiles, toFiles) {
+		fromFile, inFrom := fromFiles[path]
+		toFile, inTo := toFiles[path]
+
+		switch {
+		case !inFrom:
+			diff.Files = append(diff.Files, FileChange{Path: path, Change: ChangeAdded})
+		case !inTo:
+			diff.Files = append(diff.Files, FileChange{Path: path, Change: ChangeRemoved})
+		default:
+			change := FileChange{
+				Path:    path,
+				Change:  ChangeChanged,
+				Fields:  diffFileFields(fromFile, toFile),
+				Secrets: diffSecrets(fileSecrets(fromFile), fileSecrets(toFile)),
+			}
+			if len(change.Fields) > 0 || len(change.Secrets) > 0 {
+				diff.Files = append(diff.Files, change)
+			}
+		}
+	}
+
+	return diff
+}
+
+// diffSecrets compares two secrets maps, returning changes sorted by key.
+func diffSecrets(from, to SecretsMap) []SecretChange {
+	var changes []SecretChange
+	for _, key := range unionKeys(from, to) {
+		fromSpec, inFrom := from[key]
+		toSpec, inTo := to[key]
+
+		switch {
+		case !inFrom:
+			changes = append(changes, SecretChange{Key: key, Change: ChangeAdded, To: snapshot(toSpec)})
+		case !inTo:
+			changes = append(changes, SecretChange{Key: key, Change: ChangeRemoved, From: snapshot(fromSpec)})
+		default:
+			fromSnapshot, toSnapshot := snapshot(fromSpec), snapshot(toSpec)
+			if fromSnapshot.String() != toSnapshot.String() {
+				changes = append(changes, SecretChange{Key: key, Change: ChangeChanged, From: fromSnapshot, To: toSnapshot})
+			}
+		}
+	}
+	return changes
+}
+
+// diffFileFields compares the settings of two summon.files entries.
+func diffFileFields(from, to FileConfig) []FieldChange {
+	var changes []FieldChange
+	compare := func(name, fromValue, toValue string) {
+		if fromValue != toValue {
+			changes = append(changes, FieldChange{Name: name, From: fromValue, To: toValue})
+		}
+	}
+
+	compare("format", from.Format, to.Format)
+	compare("template", from.Template, to.Template)
+	compare("overwrite", fmt.Sprint(from.Overwrite), fmt.Sprint(to.Overwrite))
+	compare("permissions", permissionsString(from.Permissions), permissionsString(to.Permissions))
+	compare("order", from.Order, to.Order)
+	return changes
+}
+
+// snapshot describes spec for a SecretChange.
+func snapshot(spec SecretSpec) *SecretSnapshot {
+	var tags []string
+	if spec.IsAlias() {
+		tags = append(tags, "alias")
+	}
+	if spec.IsVar() {
+		tags = append(tags, "var")
+	}
+	if spec.IsFile() {
+		tags = append(tags, "file")
+	}
+
+	s := &SecretSnapshot{
+		Path:     spec.Path,
+		Default:  spec.DefaultValue,
+		Provider: spec.Provider,
+		Optional: spec.Optional,
+	}
+	if len(tags) > 0 {
+		s.Tag = "!" + strings.Join(tags, ":")
+	}
+	for _, constraint := range spec.Constraints {
+		s.Constraints = append(s.Constraints, constraint.String())
+	}
+	slices.Sort(s.Constraints)
+	return s
+}
+
+// String describes the secret in a single line, e.g.
+// `!var prod/db/password (default="x", optional)`.
+func (s *SecretSnapshot) String() string {
+	var b strings.Builder
+	if s.Tag != "" {
+		b.WriteString(s.Tag + " ")
+	}
+	b.WriteString(s.Path)
+
+	var attributes []string
+	if s.Default != "" {
+		attributes = append(attributes, fmt.Sprintf("default=%q", s.Default))
+	}
+	if s.Provider != "" {
+		attributes = append(attributes, fmt.Sprintf("provider=%q", s.Provider))
+	}
+	if s.Optional {
+		attributes = append(attributes, "optional")
+	}
+	attributes = append(attributes, s.Constraints...)
+	if len(attributes) > 0 {
+		b.WriteString(" (" + strings.Join(attributes, ", ") + ")")
+	}
+	return b.String()
+}
+
+// filesByPath indexes summon.files entries by their path.
+func filesByPath(files []FileConfig) map[string]FileConfig {
+	byPath := make(map[string]FileConfig, len(files))
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	return byPath
+}
+
+// fileSecrets returns the parsed secrets of a summon.files entry.
+func fileSecrets(file FileConfig) SecretsMap {
+	secrets, _ := file.Secrets.(SecretsMap)
+	return secrets
+}
+
+// permissionsString formats file permissions the way they are written in
+// secrets.yml.
+func permissionsString(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", uint32(mode))
+}
+
+// unionKeys returns the keys of a and b, sorted.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package secretsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	content := `common:
+  DB_HOST: db.internal
+staging:
+  DB_PASSWORD: !var staging/$app/db/password
+  DEBUG: "true"
+  API_KEY: !var:min_len=16 staging/api/key
+production:
+  DB_PASSWORD: !var production/$app/db/password
+  API_KEY: !var:min_len=32 production/api/key
+  SSL_CERT: !var:file production/ssl/cert
+summon.files:
+  - path: config.json
+    format: json
+    secrets:
+      staging:
+        TOKEN: !var staging/token
+      production:
+        TOKEN: !var staging/token
+        EXTRA: !var production/extra
+  - path: staging.env
+    format: dotenv
+    secrets:
+      A: a
+`
+	parse := func(env string) *ParsedConfig {
+		config, err := ParseFromString(content, env, nil)
+		require.NoError(t, err)
+		return config
+	}
+
+	t.Run("compares environments", func(t *testing.T) {
+		diff := Diff(parse("staging"), parse("production"))
+
+		assert.Equal(t, []SecretChange{
+			{
+				Key:    "API_KEY",
+				Change: ChangeChanged,
+				From:   &SecretSnapshot{Tag: "!var", Path: "staging/api/key", Constraints: []string{"min_len=16"}},
+				To:     &SecretSnapshot{Tag: "!var", Path: "production/api/key", Constraints: []string{"min_len=32"}},
+			},
+			{
+				Key:    "DB_PASSWORD",
+				Change: ChangeChanged,
+				From:   &SecretSnapshot{Tag: "!var", Path: "staging/$app/db/password"},
+				To:     &SecretSnapshot{Tag: "!var", Path: "production/$app/db/password"},
+			},
+			{Key: "DEBUG", Change: ChangeRemoved, From: &SecretSnapshot{Path: "true"}},
+			{Key: "SSL_CERT", Change: ChangeAdded, To: &SecretSnapshot{Tag: "!var:file", Path: "production/ssl/cert"}},
+		}, diff.Secrets)
+
+		assert.Equal(t, []FileChange{
+			{
+				Path:    "config.json",
+				Change:  ChangeChanged,
+				Secrets: []SecretChange{{Key: "EXTRA", Change: ChangeAdded, To: &SecretSnapshot{Tag: "!var", Path: "production/extra"}}},
+			},
+		}, diff.Files)
+	})
+
+	t.Run("compares file settings", func(t *testing.T) {
+		from, err := ParseFromString("summon.files:\n  - path: a.env\n    format: dotenv\n    secrets:\n      A: a\n", "", nil)
+		require.NoError(t, err)
+		to, err := ParseFromString("summon.files:\n  - path: a.env\n    format: json\n    permissions: 0640\n    secrets:\n      A: a\n  - path: b.env\n    secrets:\n      B: b\n", "", nil)
+		require.NoError(t, err)
+
+		diff := Diff(from, to)
+		assert.Empty(t, diff.Secrets)
+		assert.Equal(t, []FileChange{
+			{
+				Path:   "a.env",
+				Change: ChangeChanged,
+				Fields: []FieldChange{
+					{Name: "format", From: "dotenv", To: "json"},
+					{Name: "permissions", From: "0600", To: "0640"},
+				},
+			},
+			{Path: "b.env", Change: ChangeAdded},
+		}, diff.Files)
+	})
+
+	t.Run("is empty for equivalent configurations", func(t *testing.T) {
+		diff := Diff(parse("production"), parse("production"))
+		assert.True(t, diff.Empty())
+	})
+}
+
+func TestSecretSnapshot_String(t *testing.T) {
+	snapshot := &SecretSnapshot{
+		Tag:         "!var:file",
+		Path:        "prod/cert",
+		Default:     "none",
+		Provider:    "summon-aws",
+		Optional:    true,
+		Constraints: []string{"min_len=8"},
+	}
+	assert.Equal(t, `!var:file prod/cert (default="none", provider="summon-aws", optional, min_len=8)`, snapshot.String())
+	assert.Equal(t, "literal", (&SecretSnapshot{Path: "literal"}).String())
+}
@@ -0,0 +1,137 @@
+package secretsyml
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// versionKey is the top-level key declaring the secrets.yml format version.
+const versionKey = "summon.version"
+
+// Top-level keys configuring the names of the exported variables.
+const (
+	envPrefixKey = "summon.env_prefix"
+	envCaseKey   = "summon.env_case"
+)
+
+// latestVersion is the most recent secrets.yml format version.
+const latestVersion = 2
+
+// entryFields lists the fields of a structured secrets.yml entry.
+var entryFields = []string{"path", "type", "default", "provider", "optional", "file", "constraints"}
+
+// secretEntry is a structured secrets.yml entry, available from version 2 on:
+//
+//	DB_PASSWORD: {path: prod/db/password, default: "it's", file: true}
+type secretEntry struct {
+	Path        string   `yaml:"path"`
+	Type        string   `yaml:"type"` // "var" (default), "literal" or "alias"
+	Default     string   `yaml:"default"`
+	Provider    string   `yaml:"provider"`
+	Optional    bool     `yaml:"optional"`
+	File        bool     `yaml:"file"`
+	Constraints []string `yaml:"constraints"` // e.g. ["min_len=32", "type=int"]
+}
+
+// configVersion returns the format version declared by the root mapping,
+// defaulting to 1 when summon.version is absent.
+func configVersion(root *yaml.Node) (int, error) {
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value != versionKey {
+			continue
+		}
+
+		value := root.Content[i+1]
+		version, err := strconv.Atoi(value.Value)
+		if err != nil || value.Kind != yaml.ScalarNode || version < 1 || version > latestVersion {
+			return 0, fmt.Errorf("unsupported %s %q (expected 1 or %d)", versionKey, value.Value, latestVersion)
+		}
+		return version, nil
+	}
+	return 1, nil
+}
+
+// isEntryNode returns true if node is a mapping of entry fields that
+// includes a path, i.e. a structured entry rather than an environment section.
+func isEntryNode(node *yaml.Node) bool {
+	keys := mappingKeys(node)
+	if !slices.Contains(keys, "path") {
+		return false
+	}
+	for _, key := range keys {
+		if !slices.Contains(entryFields, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// specFromNode converts the value of a secrets.yml entry into a SecretSpec.
+// Structured entries are only recognized from version 2 on.
+func specFromNode(node *yaml.Node, version int) (SecretSpec, error) {
+	var spec SecretSpec
+
+	if version < 2 || node.Kind != yaml.MappingNode {
+		err := spec.setYAML(node.Tag, node.Value)
+		return spec, err
+	}
+
+	if !isEntryNode(node) {
+		return spec, fmt.Errorf("structured entry must have a path and only the fields %s",
+			strings.Join(entryFields, ", "))
+	}
+
+	var entry secretEntry
+	if err := node.Decode(&entry); err != nil {
+		return spec, err
+	}
+	return entry.spec()
+}
+
+// spec converts a structured entry into a SecretSpec.
+func (entry *secretEntry) spec() (SecretSpec, error) {
+	spec := SecretSpec{
+		Path:         entry.Path,
+		DefaultValue: entry.Default,
+		Provider:     entry.Provider,
+		Optional:     entry.Optional,
+	}
+
+	// Tags match those of the equivalent version 1 entries, where a literal
+	// written to a file (`!file content`) carries only the File tag.
+	switch entry.Type {
+	case "", "var":
+		spec.Tags = append(spec.Tags, Var)
+	case "literal":
+		if !entry.File {
+			spec.Tags = append(spec.Tags, Literal)
+		}
+	case "alias":
+		if entry.Default != "" || entry.Provider != "" || entry.Optional || entry.File || len(entry.Constraints) > 0 {
+			return spec, fmt.Errorf("alias entries only take a path, the name of the secret they refer to")
+		}
+		spec.Tags = append(spec.Tags, Alias)
+		return spec, nil
+	default:
+		return spec, fmt.Errorf("unknown entry type %q (expected var, literal or alias)", entry.Type)
+	}
+
+	if entry.File {
+		spec.Tags = append(spec.Tags, File)
+	}
+
+	for _, constraint := range entry.Constraints {
+		name, arg, _ := strings.Cut(constraint, "=")
+		c, err := newConstraint(name, arg)
+		if err != nil {
+			return spec, err
+		}
+		spec.Constraints = append(spec.Constraints, c)
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,119 @@
+package secretsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFromString_Version2(t *testing.T) {
+	t.Run("Structured entries parse into the same specs as tags", func(t *testing.T) {
+		v1 := `
+API_KEY: !var prod/api_key
+CERT: !var:file prod/cert
+CONTENT: !file my content
+PORT: !var:default='8080':type=int prod/port
+LITERAL: plain value
+`
+		v2 := `
+summon.version: 2
+API_KEY: {path: prod/api_key}
+CERT: {path: prod/cert, file: true}
+CONTENT: {path: my content, type: literal, file: true}
+PORT: {path: prod/port, default: "8080", constraints: [type=int]}
+LITERAL: plain value
+`
+		config1, err := ParseFromString(v1, "", nil)
+		assert.NoError(t, err)
+		config2, err := ParseFromString(v2, "", nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, config1.Version)
+		assert.Equal(t, 2, config2.Version)
+		assert.Equal(t, config1.EnvSecrets, config2.EnvSecrets)
+		assert.Equal(t, config1.EnvKeys, config2.EnvKeys)
+	})
+
+	t.Run("Structured entries support quotes, providers and optional secrets", func(t *testing.T) {
+		input := `
+summon.version: 2
+GREETING: {path: app/greeting, default: "it's here", provider: summon-aws, optional: true}
+`
+		config, err := ParseFromString(input, "", nil)
+		assert.NoError(t, err)
+
+		spec := config.EnvSecrets["GREETING"]
+		assert.True(t, spec.IsVar())
+		assert.Equal(t, "it's here", spec.DefaultValue)
+		assert.Equal(t, "summon-aws", spec.Provider)
+		assert.True(t, spec.Optional)
+	})
+
+	t.Run("Environment sections may contain structured entries", func(t *testing.T) {
+		input := `
+summon.version: 2
+common:
+  DB_USER: db-user
+prod:
+  DB_PASS:
+    path: prod/db/pass
+    file: true
+summon.files:
+  - path: out.yml
+    secrets:
+      prod:
+        TOKEN: {path: prod/token}
+`
+		config, err := ParseFromString(input, "prod", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"DB_USER", "DB_PASS"}, config.EnvKeys)
+		dbPass := config.EnvSecrets["DB_PASS"]
+		assert.True(t, dbPass.IsFile())
+		token := requireFileSecrets(t, config, 0)["TOKEN"]
+		assert.True(t, token.IsVar())
+	})
+
+	t.Run("Substitutions apply to structured entries", func(t *testing.T) {
+		input := `
+summon.version: 2
+KEY: {path: $env/key}
+`
+		config, err := ParseFromString(input, "", map[string]string{"env": "prod"})
+		assert.NoError(t, err)
+		assert.Equal(t, "prod/key", config.EnvSecrets["KEY"].Path)
+	})
+
+	errorTests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "Unsupported version",
+			input:         "summon.version: 3\nKEY: value",
+			expectedError: `unsupported summon.version "3"`,
+		},
+		{
+			name:          "Unknown entry type",
+			input:         "summon.version: 2\nKEY: {path: a, type: secret}",
+			expectedError: `unknown entry type "secret"`,
+		},
+		{
+			name:          "Invalid constraint",
+			input:         "summon.version: 2\nKEY: {path: a, constraints: [type=uuid]}",
+			expectedError: `unknown type constraint "uuid"`,
+		},
+		{
+			name:          "Entry with unknown field",
+			input:         "summon.version: 2\nKEY: {path: a, colour: blue}\nOTHER: value",
+			expectedError: "structured entry must have a path",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFromString(tt.input, "", nil)
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
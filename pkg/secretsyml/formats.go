@@ -0,0 +1,244 @@
+package secretsyml
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax a secrets configuration is written in.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// tagObjectPrefix starts the single key of the JSON and TOML objects that
+// stand in for YAML tags, e.g. {"$var:file": "path"} for `!var:file path`.
+const tagObjectPrefix = "$"
+
+// DetectFormat determines the format of a configuration from the extension
+// of filename and, failing that, from its content.
+func DetectFormat(filename string, content []byte) Format {
+	format, _ := detectFormat(filename, content)
+	return format
+}
+
+// detectFormat is DetectFormat, also returning the document node when the
+// content had to be parsed to tell its format, so that it isn't parsed twice.
+func detectFormat(filename string, content []byte) (Format, *yaml.Node) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".toml":
+		return FormatTOML, nil
+	case ".yml", ".yaml":
+		return FormatYAML, nil
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatJSON, nil
+	}
+
+	// Anything that isn't a YAML mapping but is valid TOML is taken as TOML
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err == nil &&
+		(len(doc.Content) == 0 || doc.Content[0].Kind == yaml.MappingNode) {
+		return FormatYAML, &doc
+	}
+	if root, err := tomlToNode(string(content)); err == nil {
+		return FormatTOML, tomlDocument(root)
+	}
+	return FormatYAML, nil
+}
+
+// documentNode parses content in the given format into a YAML document node,
+// so that every format goes through the same node-based parsing.
+func documentNode(content string, format Format) (*yaml.Node, error) {
+	if format == FormatTOML {
+		root, err := tomlToNode(content)
+		if err != nil {
+			return nil, err
+		}
+		return tomlDocument(root), nil
+	}
+
+	// JSON is a subset of YAML, so the YAML parser handles both
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, err
+	}
+	if format == FormatJSON {
+		decodeTagObjects(&doc)
+	}
+	return &doc, nil
+}
+
+// tomlDocument wraps the root node of a TOML configuration in a document
+// node, decoding its tag objects.
+func tomlDocument(root *yaml.Node) *yaml.Node {
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	decodeTagObjects(doc)
+	return doc
+}
+
+// decodeTagObjects replaces the tag objects of a document by the equivalent
+// tagged scalars. Only the positions of secret values are considered: the
+// top-level entries, the entries of environment sections and the same
+// positions within the secrets of summon.files.
+func decodeTagObjects(doc *yaml.Node) {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+
+	root := doc.Content[0]
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i].Value, root.Content[i+1]
+		switch {
+		case key == "summon.files" && value.Kind == yaml.SequenceNode:
+			for _, fileNode := range value.Content {
+				if secrets := mappingValue(fileNode, "secrets"); secrets != nil {
+					decodeSecretTagObjects(secrets, true)
+				}
+			}
+		case !strings.HasPrefix(key, "summon."):
+			decodeSecretTagObject(value, true)
+		}
+	}
+}
+
+// decodeSecretTagObjects decodes the tag objects among the values of a
+// secrets mapping, descending into environment sections when sections is
+// set.
+func decodeSecretTagObjects(node *yaml.Node, sections bool) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(node.Content); i += 2 {
+		decodeSecretTagObject(node.Content[i], sections)
+	}
+}
+
+// decodeSecretTagObject replaces node, a secret value, by the equivalent
+// tagged scalar if it is a tag object: a single-key mapping whose key starts
+// with "$". Any other mapping is taken as an environment section when
+// sections is set.
+func decodeSecretTagObject(node *yaml.Node, sections bool) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	if len(node.Content) == 2 {
+		key, value := node.Content[0], node.Content[1]
+		if strings.HasPrefix(key.Value, tagObjectPrefix) && value.Kind == yaml.ScalarNode {
+			*node = yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!" + strings.TrimPrefix(key.Value, tagObjectPrefix),
+				Value: value.Value,
+			}
+			return
+		}
+	}
+	if sections {
+		decodeSecretTagObjects(node, false)
+	}
+}
+
+// tomlToNode decodes TOML content into a YAML mapping node, keeping keys in
+// document order.
+func tomlToNode(content string) (*yaml.Node, error) {
+	var data map[string]interface{}
+	md, err := toml.Decode(content, &data)
+	if err != nil {
+		return nil, err
+	}
+	return tomlValueToNode(data, nil, md.Keys())
+}
+
+// tomlValueToNode converts a decoded TOML value found at path into a node.
+// keys lists every key of the document in order.
+func tomlValueToNode(value interface{}, path []string, keys []toml.Key) (*yaml.Node, error) {
+	scalar := func(tag, v string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, name := range tomlChildKeys(v, path, keys) {
+			child, err := tomlValueToNode(v[name], append(slices.Clone(path), name), keys)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, scalar("!!str", name), child)
+		}
+		return node, nil
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			child, err := tomlValueToNode(item, path, keys)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			child, err := tomlValueToNode(item, path, keys)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case string:
+		return scalar("!!str", v), nil
+	case int64:
+		return scalar("!!int", strconv.FormatInt(v, 10)), nil
+	case float64:
+		return scalar("!!float", strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		return scalar("!!bool", strconv.FormatBool(v)), nil
+	case time.Time:
+		return scalar("!!str", v.Format(time.RFC3339Nano)), nil
+	default:
+		return nil, fmt.Errorf("unsupported TOML value of type %T at %q", value, strings.Join(path, "."))
+	}
+}
+
+// tomlChildKeys returns the keys of table, which is found at path, in
+// document order.
+func tomlChildKeys(table map[string]interface{}, path []string, keys []toml.Key) []string {
+	ordered := make([]string, 0, len(table))
+	for _, key := range keys {
+		if len(key) != len(path)+1 || !slices.Equal(key[:len(path)], path) {
+			continue
+		}
+		name := key[len(path)]
+		if _, ok := table[name]; ok && !slices.Contains(ordered, name) {
+			ordered = append(ordered, name)
+		}
+	}
+
+	// Keys missing from the document listing are kept in a predictable order
+	var rest []string
+	for name := range table {
+		if !slices.Contains(ordered, name) {
+			rest = append(rest, name)
+		}
+	}
+	slices.Sort(rest)
+
+	return append(ordered, rest...)
+}
@@ -0,0 +1,179 @@
+package secretsyml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const conformanceYAML = `
+common:
+  DB_USER: db-user
+  DB_HOST: !var:default='localhost' $env/db/host
+production:
+  DB_PASS: !var $env/db/password
+  SSL_CERT: !var:file $env/ssl/cert
+  PORT: !var:type=int $env/db/port
+  RETRIES: 3
+summon.files:
+  - path: "config/db.env"
+    format: dotenv
+    order: declared
+    overwrite: true
+    permissions: 0640
+    secrets:
+      DB_USER: !var $env/db/user
+      DB_PASS: !var $env/db/password
+`
+
+const conformanceJSON = `{
+  "common": {
+    "DB_USER": "db-user",
+    "DB_HOST": {"$var:default='localhost'": "$env/db/host"}
+  },
+  "production": {
+    "DB_PASS": {"$var": "$env/db/password"},
+    "SSL_CERT": {"$var:file": "$env/ssl/cert"},
+    "PORT": {"$var:type=int": "$env/db/port"},
+    "RETRIES": 3
+  },
+  "summon.files": [
+    {
+      "path": "config/db.env",
+      "format": "dotenv",
+      "order": "declared",
+      "overwrite": true,
+      "permissions": 416,
+      "secrets": {
+        "DB_USER": {"$var": "$env/db/user"},
+        "DB_PASS": {"$var": "$env/db/password"}
+      }
+    }
+  ]
+}`
+
+const conformanceTOML = `
+[common]
+DB_USER = "db-user"
+DB_HOST = { "$var:default='localhost'" = "$env/db/host" }
+
+[production]
+DB_PASS = { "$var" = "$env/db/password" }
+SSL_CERT = { "$var:file" = "$env/ssl/cert" }
+PORT = { "$var:type=int" = "$env/db/port" }
+RETRIES = 3
+
+[["summon.files"]]
+path = "config/db.env"
+format = "dotenv"
+order = "declared"
+overwrite = true
+permissions = 0o640
+
+["summon.files".secrets]
+DB_USER = { "$var" = "$env/db/user" }
+DB_PASS = { "$var" = "$env/db/password" }
+`
+
+// withoutNodes drops the raw YAML nodes kept on file configs, which differ
+// between formats in position and style only.
+func withoutNodes(config *ParsedConfig) *ParsedConfig {
+	for i := range config.Files {
+		config.Files[i].secretsNode = nil
+	}
+	return config
+}
+
+func TestFormatConformance(t *testing.T) {
+	subs := map[string]string{"env": "prod"}
+
+	expected, err := ParseFromString(conformanceYAML, "production", subs)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		format  Format
+		content string
+	}{
+		{FormatJSON, conformanceJSON},
+		{FormatTOML, conformanceTOML},
+	} {
+		t.Run(string(tc.format), func(t *testing.T) {
+			assert.Equal(t, tc.format, DetectFormat("", []byte(tc.content)))
+
+			actual, err := ParseFromString(tc.content, "production", subs)
+			require.NoError(t, err)
+			assert.Equal(t, withoutNodes(expected), withoutNodes(actual))
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	testCases := []struct {
+		description string
+		filename    string
+		content     string
+		expected    Format
+	}{
+		{"json extension", "secrets.json", "", FormatJSON},
+		{"toml extension", "secrets.TOML", "", FormatTOML},
+		{"yml extension wins over content", "secrets.yml", `{"A": "a"}`, FormatYAML},
+		{"json content", "", "\n  {\"A\": \"a\"}", FormatJSON},
+		{"yaml content", "", "A: !var path", FormatYAML},
+		{"toml content", "", "[production]\nA = \"a\"", FormatTOML},
+		{"toml content with unknown extension", "secrets.conf", "A = \"a\"", FormatTOML},
+		{"empty content", "", "", FormatYAML},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DetectFormat(tc.filename, []byte(tc.content)))
+		})
+	}
+}
+
+func TestParseFromFile_Formats(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"secrets.json": `{"SECRET": {"$var": "path/to/secret"}, "LITERAL": "value"}`,
+		"secrets.toml": "SECRET = { \"$var\" = \"path/to/secret\" }\nLITERAL = \"value\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+			config, err := ParseFromFile(path, "", nil)
+			require.NoError(t, err)
+
+			secret := config.EnvSecrets["SECRET"]
+			assert.True(t, secret.IsVar())
+			assert.Equal(t, "path/to/secret", secret.Path)
+			literal := config.EnvSecrets["LITERAL"]
+			assert.True(t, literal.IsLiteral())
+			assert.Equal(t, []string{"SECRET", "LITERAL"}, config.EnvKeys)
+		})
+	}
+}
+
+func TestDecodeTagObjects(t *testing.T) {
+	doc, err := documentNode(`{
+  "summon.version": 2,
+  "TOP": {"$var": "top"},
+  "prod": {"SECTION": {"$var": "section"}, "ENTRY": {"path": {"$var": "entry"}}},
+  "summon.files": [{"path": {"$var": "out"}, "secrets": {"FILE": {"$var": "file"}}}]
+}`, FormatJSON)
+	require.NoError(t, err)
+	root := doc.Content[0]
+
+	assert.Equal(t, "!var", mappingValue(root, "TOP").Tag)
+	prod := mappingValue(root, "prod")
+	assert.Equal(t, "!var", mappingValue(prod, "SECTION").Tag)
+	assert.Equal(t, yaml.MappingNode, mappingValue(mappingValue(prod, "ENTRY"), "path").Kind, "entry fields are not secret values")
+	file := mappingValue(root, "summon.files").Content[0]
+	assert.Equal(t, yaml.MappingNode, mappingValue(file, "path").Kind, "file settings are not secret values")
+	assert.Equal(t, "!var", mappingValue(mappingValue(file, "secrets"), "FILE").Tag)
+}
@@ -0,0 +1,378 @@
+package secretsyml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportEntry is a variable read from a .env or docker-compose file.
+type ImportEntry struct {
+	Key   string
+	Value string
+	// Unset is true for variables declared without a value, such as `- KEY`
+	// in a compose file, whose value comes from elsewhere.
+	Unset bool
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	Environment string // Section to add the entries to; the top level when empty
+	PathPrefix  string // Prefix of the variable paths of secret-looking entries
+}
+
+// importHeader is the comment at the top of configurations created by Import.
+const importHeader = "Generated by summon tool init. Store the values of the !var entries in your\nsecrets provider, and check that no secret is left as a literal value."
+
+var (
+	dotenvKeyRegex    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	composeFileRegex  = regexp.MustCompile(`(?i)(^|[-_.])compose([-_.].*)?\.ya?ml$`)
+	interpolatedRegex = regexp.MustCompile(`^\$\{?[A-Za-z_]\w*(:?[-?][^}]*)?\}?$`)
+	credentialURLRe   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`)
+)
+
+// secretKeyWords are words of variable names that hint at secret values.
+var secretKeyWords = []string{
+	"APIKEY", "AUTH", "CERT", "CREDENTIAL", "CREDENTIALS", "DSN", "KEY",
+	"PASS", "PASSPHRASE", "PASSWD", "PASSWORD", "PRIVATE", "PWD", "SALT", "SECRET", "TOKEN",
+}
+
+// ReadImportSource reads the variables of a .env file or, when filename or
+// content identify one, of a docker-compose file. For compose files, service
+// selects the service whose environment is read; it may be empty when a
+// single service declares an environment.
+func ReadImportSource(filename string, content []byte, service string) ([]ImportEntry, error) {
+	if isComposeFile(filename, content) {
+		return ParseComposeEnvironment(content, service)
+	}
+	return ParseDotenv(content)
+}
+
+// isComposeFile reports whether a file is a docker-compose file.
+func isComposeFile(filename string, content []byte) bool {
+	if composeFileRegex.MatchString(filepath.Base(filename)) {
+		return true
+	}
+
+	var doc struct {
+		Services map[string]interface{} `yaml:"services"`
+	}
+	return yaml.Unmarshal(content, &doc) == nil && len(doc.Services) > 0
+}
+
+// ParseDotenv reads the variables of a .env file, in order. Values may be
+// single-quoted (taken as-is), double-quoted (with \n, \t, \" and \\
+// escapes) or unquoted, where a ` #` starts a comment. Lines may start with
+// `export`.
+func ParseDotenv(content []byte) ([]ImportEntry, error) {
+	var entries []ImportEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || !dotenvKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+
+		value, err := unquoteDotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		entries = addImportEntry(entries, ImportEntry{Key: key, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// unquoteDotenvValue returns the value of a .env assignment.
+func unquoteDotenvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return value[1 : end+1], nil
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; c {
+			case '"':
+				return b.String(), nil
+			case '\\':
+				i++
+				if i == len(value) {
+					break
+				}
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+	default:
+		if strings.HasPrefix(value, "#") {
+			return "", nil
+		}
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// ParseComposeEnvironment reads the `environment:` block of a service in a
+// docker-compose file, written either as a mapping or as a list of
+// KEY=value items. service may be empty when a single service declares an
+// environment.
+func ParseComposeEnvironment(content []byte, service string) ([]ImportEntry, error) {
+	var doc struct {
+		Services yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Services.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("compose file has no services")
+	}
+
+	var names []string
+	var environment *yaml.Node
+	for i := 0; i+1 < len(doc.Services.Content); i += 2 {
+		name, serviceNode := doc.Services.Content[i].Value, doc.Services.Content[i+1]
+		env := mappingValue(serviceNode, "environment")
+		if env == nil {
+			continue
+		}
+		names = append(names, name)
+		if service == "" || service == name {
+			environment = env
+		}
+	}
+
+	switch {
+	case service != "" && !slices.Contains(names, service):
+		return nil, fmt.Errorf("compose service %q has no environment", service)
+	case service == "" && len(names) == 0:
+		return nil, fmt.Errorf("no compose service has an environment")
+	case service == "" && len(names) > 1:
+		return nil, fmt.Errorf("several compose services have an environment (%s), pick one", strings.Join(names, ", "))
+	}
+
+	var entries []ImportEntry
+	switch environment.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(environment.Content); i += 2 {
+			key, value := environment.Content[i].Value, environment.Content[i+1]
+			unset := value.Tag == "!!null"
+			entries = addImportEntry(entries, ImportEntry{Key: key, Value: composeValue(value), Unset: unset})
+		}
+	case yaml.SequenceNode:
+		for _, item := range environment.Content {
+			key, value, found := strings.Cut(item.Value, "=")
+			entries = addImportEntry(entries, ImportEntry{Key: key, Value: value, Unset: !found})
+		}
+	default:
+		return nil, fmt.Errorf("environment must be a mapping or a list")
+	}
+
+	return entries, nil
+}
+
+// composeValue returns the value of a compose environment mapping entry.
+func composeValue(node *yaml.Node) string {
+	if node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+// addImportEntry adds entry to entries, replacing an earlier entry with the
+// same key in place.
+func addImportEntry(entries []ImportEntry, entry ImportEntry) []ImportEntry {
+	i := slices.IndexFunc(entries, func(e ImportEntry) bool { return e.Key == entry.Key })
+	if i >= 0 {
+		entries[i] = entry
+		return entries
+	}
+	return append(entries, entry)
+}
+
+// LooksSecret reports whether an imported variable probably holds a secret:
+// its name contains a word such as PASSWORD or TOKEN, its value is a
+// credential URL, a PEM block or a long random-looking string, or it has
+// no value of its own.
+func LooksSecret(entry ImportEntry) bool {
+	value := entry.Value
+	if entry.Unset || interpolatedRegex.MatchString(value) {
+		return true
+	}
+	if value == "" {
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseBool(value); err == nil {
+		return false
+	}
+
+	words := strings.FieldsFunc(strings.ToUpper(entry.Key), func(r rune) bool {
+		return r == '_' || r == '-' || r == '.'
+	})
+	for _, word := range words {
+		if slices.Contains(secretKeyWords, word) {
+			return true
+		}
+	}
+
+	return credentialURLRe.MatchString(value) ||
+		strings.Contains(value, "-----BEGIN ") ||
+		looksRandom(value)
+}
+
+// looksRandom reports whether value looks like a generated token: long,
+// without spaces, mixing letters and digits.
+func looksRandom(value string) bool {
+	if len(value) < 24 || strings.ContainsFunc(value, unicode.IsSpace) {
+		return false
+	}
+	return strings.ContainsFunc(value, unicode.IsLetter) && strings.ContainsFunc(value, unicode.IsDigit)
+}
+
+// Import adds entries to the secrets.yml content existing, which may be
+// empty, and returns the result along with the keys that were left alone
+// because existing already declares them. Secret-looking entries (see
+// LooksSecret) become !var entries named after their key under
+// opts.PathPrefix; the rest are kept as literals. When several entries
+// share a key, the last one wins. Comments in existing are preserved.
+func Import(existing []byte, entries []ImportEntry, opts ImportOptions) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("invalid YAML structure: expected mapping")
+	}
+	version, err := configVersion(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := importTarget(root, opts.Environment, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var merged []ImportEntry
+	for _, entry := range entries {
+		merged = addImportEntry(merged, entry)
+	}
+
+	var skipped []string
+	for _, entry := range merged {
+		if mappingValue(target, entry.Key) != nil {
+			skipped = append(skipped, entry.Key)
+			continue
+		}
+
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.ReplaceAll(entry.Value, "$", "$$")}
+		if LooksSecret(entry) {
+			value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!var", Value: opts.PathPrefix + entry.Key}
+		}
+		insertBeforeFiles(target, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Key}, value)
+	}
+
+	if len(existing) == 0 && len(root.Content) > 0 {
+		root.Content[0].HeadComment = importHeader
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), skipped, nil
+}
+
+// importTarget returns the mapping imported entries are added to: the
+// environment section, created if needed, or the root itself.
+func importTarget(root *yaml.Node, environment string, version int) (*yaml.Node, error) {
+	var sections, secrets []string
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i].Value, root.Content[i+1]
+		if strings.HasPrefix(key, "summon.") {
+			continue
+		}
+		if value.Kind == yaml.MappingNode && !(version >= 2 && isEntryNode(value)) {
+			sections = append(sections, key)
+		} else {
+			secrets = append(secrets, key)
+		}
+	}
+
+	if environment == "" {
+		if len(sections) > 0 {
+			return nil, fmt.Errorf("secrets.yml declares environment sections (%s), pick one to import into", strings.Join(sections, ", "))
+		}
+		return root, nil
+	}
+
+	if len(secrets) > 0 {
+		return nil, fmt.Errorf("secrets.yml declares secrets outside of environment sections, can't add environment %q", environment)
+	}
+	if section := mappingValue(root, environment); section != nil {
+		return section, nil
+	}
+	section := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	insertBeforeFiles(root, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: environment}, section)
+	return section, nil
+}
+
+// insertBeforeFiles adds a key/value pair to a mapping, before summon.files
+// if the mapping has it.
+func insertBeforeFiles(node *yaml.Node, key, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "summon.files" {
+			node.Content = slices.Insert(node.Content, i, key, value)
+			return
+		}
+	}
+	node.Content = append(node.Content, key, value)
+}
@@ -0,0 +1,198 @@
+package secretsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenv(t *testing.T) {
+	content := `# Database settings
+DB_HOST=localhost
+export DB_PASSWORD='p@ss #1'
+GREETING="Hello\n\"world\""
+EMPTY=
+LOG_LEVEL=debug # verbose while developing
+DB_HOST=db.internal
+`
+	entries, err := ParseDotenv([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, []ImportEntry{
+		{Key: "DB_HOST", Value: "db.internal"},
+		{Key: "DB_PASSWORD", Value: "p@ss #1"},
+		{Key: "GREETING", Value: "Hello\n\"world\""},
+		{Key: "EMPTY", Value: ""},
+		{Key: "LOG_LEVEL", Value: "debug"},
+	}, entries)
+
+	t.Run("rejects malformed lines", func(t *testing.T) {
+		_, err := ParseDotenv([]byte("OK=1\nnot an assignment\n"))
+		assert.EqualError(t, err, "line 2: expected KEY=value")
+
+		_, err = ParseDotenv([]byte(`KEY="unterminated`))
+		assert.EqualError(t, err, "line 1: unterminated double-quoted value")
+	})
+}
+
+func TestParseComposeEnvironment(t *testing.T) {
+	content := `services:
+  web:
+    image: web
+    environment:
+      - API_URL=https://api.example.com
+      - API_TOKEN
+  db:
+    image: postgres
+    environment:
+      POSTGRES_USER: app
+      POSTGRES_PORT: 5432
+      POSTGRES_PASSWORD:
+  cache:
+    image: redis
+`
+
+	t.Run("reads a list environment", func(t *testing.T) {
+		entries, err := ParseComposeEnvironment([]byte(content), "web")
+		require.NoError(t, err)
+		assert.Equal(t, []ImportEntry{
+			{Key: "API_URL", Value: "https://api.example.com"},
+			{Key: "API_TOKEN", Unset: true},
+		}, entries)
+	})
+
+	t.Run("reads a mapping environment", func(t *testing.T) {
+		entries, err := ParseComposeEnvironment([]byte(content), "db")
+		require.NoError(t, err)
+		assert.Equal(t, []ImportEntry{
+			{Key: "POSTGRES_USER", Value: "app"},
+			{Key: "POSTGRES_PORT", Value: "5432"},
+			{Key: "POSTGRES_PASSWORD", Unset: true},
+		}, entries)
+	})
+
+	t.Run("needs a service when several have an environment", func(t *testing.T) {
+		_, err := ParseComposeEnvironment([]byte(content), "")
+		assert.EqualError(t, err, "several compose services have an environment (web, db), pick one")
+	})
+
+	t.Run("rejects services without environment", func(t *testing.T) {
+		_, err := ParseComposeEnvironment([]byte(content), "cache")
+		assert.EqualError(t, err, `compose service "cache" has no environment`)
+	})
+}
+
+func TestReadImportSource(t *testing.T) {
+	compose := []byte("services:\n  app:\n    environment:\n      - KEY=value\n")
+	expected := []ImportEntry{{Key: "KEY", Value: "value"}}
+
+	entries, err := ReadImportSource("docker-compose.override.yml", compose, "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, entries)
+
+	entries, err = ReadImportSource("stack.yaml", compose, "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, entries)
+
+	entries, err = ReadImportSource(".env.production", []byte("KEY=value\n"), "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, entries)
+}
+
+func TestLooksSecret(t *testing.T) {
+	testCases := []struct {
+		entry    ImportEntry
+		expected bool
+	}{
+		{ImportEntry{Key: "DB_PASSWORD", Value: "hunter2"}, true},
+		{ImportEntry{Key: "github-token", Value: "abc"}, true},
+		{ImportEntry{Key: "STRIPE_API_KEY", Value: "sk_live"}, true},
+		{ImportEntry{Key: "DATABASE_URL", Value: "postgres://app:hunter2@db/app"}, true},
+		{ImportEntry{Key: "TLS_BUNDLE", Value: "-----BEGIN CERTIFICATE-----"}, true},
+		{ImportEntry{Key: "SESSION", Value: "c2VjcmV0LXRva2VuLXZhbHVlMTIzNDU2"}, true},
+		{ImportEntry{Key: "UPSTREAM", Value: "${UPSTREAM_CREDS}"}, true},
+		{ImportEntry{Key: "FROM_HOST", Unset: true}, true},
+		{ImportEntry{Key: "DB_HOST", Value: "localhost"}, false},
+		{ImportEntry{Key: "AUTH_ENABLED", Value: "true"}, false},
+		{ImportEntry{Key: "TOKEN_TTL", Value: "3600"}, false},
+		{ImportEntry{Key: "HOMEPAGE", Value: "https://example.com/about"}, false},
+		{ImportEntry{Key: "MESSAGE", Value: "a long sentence with 3 words and more"}, false},
+		{ImportEntry{Key: "EMPTY", Value: ""}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.entry.Key, func(t *testing.T) {
+			assert.Equal(t, tc.expected, LooksSecret(tc.entry))
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	entries := []ImportEntry{
+		{Key: "DB_HOST", Value: "localhost"},
+		{Key: "DB_PASSWORD", Value: "hunter2"},
+		{Key: "PRICE", Value: "$5"},
+	}
+
+	t.Run("creates a new configuration", func(t *testing.T) {
+		content, skipped, err := Import(nil, entries, ImportOptions{PathPrefix: "prod/app/"})
+		require.NoError(t, err)
+		assert.Empty(t, skipped)
+		assert.Equal(t, `# Generated by summon tool init. Store the values of the !var entries in your
+# secrets provider, and check that no secret is left as a literal value.
+DB_HOST: localhost
+DB_PASSWORD: !var prod/app/DB_PASSWORD
+PRICE: $$5
+`, string(content))
+
+		config, err := ParseFromString(string(content), "", map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, "$5", config.EnvSecrets["PRICE"].Path)
+	})
+
+	t.Run("keeps declared keys and comments", func(t *testing.T) {
+		existing := "# Keep me\nDB_HOST: !var vault/db/host\nsummon.files:\n  - path: out.json\n    secrets:\n      A: a\n"
+		content, skipped, err := Import([]byte(existing), entries, ImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"DB_HOST"}, skipped)
+		assert.Equal(t, `# Keep me
+DB_HOST: !var vault/db/host
+DB_PASSWORD: !var DB_PASSWORD
+PRICE: $$5
+summon.files:
+  - path: out.json
+    secrets:
+      A: a
+`, string(content))
+	})
+
+	t.Run("merges environments", func(t *testing.T) {
+		content, _, err := Import(nil, entries[:2], ImportOptions{Environment: "development"})
+		require.NoError(t, err)
+		content, _, err = Import(content, []ImportEntry{{Key: "DB_PASSWORD", Value: "prod"}}, ImportOptions{Environment: "production", PathPrefix: "prod/"})
+		require.NoError(t, err)
+
+		config, err := ParseFromString(string(content), "production", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "prod/DB_PASSWORD", config.EnvSecrets["DB_PASSWORD"].Path)
+
+		config, err = ParseFromString(string(content), "development", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "localhost", config.EnvSecrets["DB_HOST"].Path)
+	})
+
+	t.Run("last entry wins", func(t *testing.T) {
+		content, skipped, err := Import(nil, []ImportEntry{{Key: "A", Value: "1"}, {Key: "A", Value: "2"}}, ImportOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, skipped)
+		assert.Contains(t, string(content), "A: \"2\"\n")
+	})
+
+	t.Run("rejects mixing flat secrets and environments", func(t *testing.T) {
+		_, _, err := Import([]byte("KEY: value\n"), entries, ImportOptions{Environment: "production"})
+		assert.EqualError(t, err, `secrets.yml declares secrets outside of environment sections, can't add environment "production"`)
+
+		_, _, err = Import([]byte("production:\n  KEY: value\n"), entries, ImportOptions{})
+		assert.EqualError(t, err, "secrets.yml declares environment sections (production), pick one to import into")
+	})
+}
@@ -2,9 +2,11 @@ package secretsyml
 
 import (
 	"fmt"
+	"io"
 	"maps"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 
 	"gopkg.in/yaml.v3"
@@ -13,33 +15,51 @@ import (
 // Compiled regexes for YAML tag parsing.
 var (
 	defaultValueRegex = regexp.MustCompile(`default='(?P<defaultValue>.*)'`)
-	tagRegex          = regexp.MustCompile("(var|file|str|int|bool|float|" + defaultValueRegex.String() + ")")
+	tagRegex          = regexp.MustCompile("(alias|var|file|str|int|bool|float|" + defaultValueRegex.String() + ")")
 )
 
-// ParseFromString parses a secrets.yml string into a ParsedConfig.
+// ParseFromString parses a secrets.yml string into a ParsedConfig. The
+// content may also be written in JSON or TOML (see DetectFormat).
 func ParseFromString(content, env string, subs map[string]string) (*ParsedConfig, error) {
-	return parseConfig(content, env, subs)
+	return parseConfig([]byte(content), "", env, subs)
 }
 
 // ParseFromFile reads and parses a secrets.yml file into a ParsedConfig.
+// JSON and TOML files are recognized by their extension or content.
 func ParseFromFile(filepath, env string, subs map[string]string) (*ParsedConfig, error) {
 	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
-	return parseConfig(string(data), env, subs)
+	return parseConfig(data, filepath, env, subs)
 }
 
-// parseConfig parses a YAML configuration that may contain both environment
-// variable secrets and file-based secrets (summon.files section).
-func parseConfig(ymlContent, env string, subs map[string]string) (*ParsedConfig, error) {
-	// Parse as yaml.Node to preserve tags
-	var rootNode yaml.Node
-	if err := yaml.Unmarshal([]byte(ymlContent), &rootNode); err != nil {
+// ParseFromReader reads and parses secrets.yml content, e.g. from stdin, into
+// a ParsedConfig.
+func ParseFromReader(r io.Reader, env string, subs map[string]string) (*ParsedConfig, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
+	return parseConfig(data, "", env, subs)
+}
+
+// parseConfig parses a configuration that may contain both environment
+// variable secrets and file-based secrets (summon.files section). Its format
+// is detected from filename and content, see DetectFormat.
+func parseConfig(content []byte, filename string, env string, subs map[string]string) (*ParsedConfig, error) {
+	// Parse as yaml.Node to preserve tags
+	format, rootNode := detectFormat(filename, content)
+	if rootNode == nil {
+		var err error
+		rootNode, err = documentNode(string(content), format)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	config := &ParsedConfig{
+		Version:    1,
 		EnvSecrets: SecretsMap{},
 		Files:      []FileConfig{},
 	}
@@ -54,6 +74,12 @@ func parseConfig(ymlContent, env string, subs map[string]string) (*ParsedConfig,
 		return nil, fmt.Errorf("invalid YAML structure: expected mapping")
 	}
 
+	version, err := configVersion(contentNode)
+	if err != nil {
+		return nil, err
+	}
+	config.Version = version
+
 	// Process the mapping to separate files from env secrets
 	envSecretsNode := yaml.Node{Kind: yaml.MappingNode}
 
@@ -61,12 +87,28 @@ func parseConfig(ymlContent, env string, subs map[string]string) (*ParsedConfig,
 		keyNode := contentNode.Content[i]
 		valueNode := contentNode.Content[i+1]
 
-		if keyNode.Value == "summon.files" {
+		switch keyNode.Value {
+		case versionKey:
+			// Already handled by configVersion
+		case envPrefixKey:
+			if valueNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s must be a string", envPrefixKey)
+			}
+			config.EnvPrefix = valueNode.Value
+		case envCaseKey:
+			if valueNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s must be a string", envCaseKey)
+			}
+			if err := ValidateEnvCase(valueNode.Value); err != nil {
+				return nil, err
+			}
+			config.EnvCase = valueNode.Value
+		case "summon.files":
 			// Process files section
-			if err := parseFilesSectionFromNode(valueNode, &config.Files, env, subs); err != nil {
+			if err := parseFilesSectionFromNode(valueNode, &config.Files, env, subs, version); err != nil {
 				return nil, err
 			}
-		} else {
+		default:
 			// Add to env secrets node
 			envSecretsNode.Content = append(envSecretsNode.Content, keyNode, valueNode)
 		}
@@ -74,16 +116,30 @@ func parseConfig(ymlContent, env string, subs map[string]string) (*ParsedConfig,
 
 	// Parse environment variable secrets if any exist
 	if len(envSecretsNode.Content) > 0 {
-		var err error
-		config.EnvSecrets, err = parseEnvSecretsFromNode(&envSecretsNode, env, subs)
+		config.EnvSecrets, err = parseEnvSecretsFromNode(&envSecretsNode, env, subs, version)
 		if err != nil {
 			return nil, err
 		}
+		config.EnvKeys = declaredKeys(&envSecretsNode, env, version)
+	}
+
+	if err := resolveAliasOptions(config); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
+// ValidateEnvCase checks that nameCase is a supported summon.env_case value.
+func ValidateEnvCase(nameCase string) error {
+	switch nameCase {
+	case "", EnvCaseUpper, EnvCaseLower:
+		return nil
+	default:
+		return fmt.Errorf("unknown %s %q (expected %q or %q)", envCaseKey, nameCase, EnvCaseUpper, EnvCaseLower)
+	}
+}
+
 // --- YAML unmarshaling ---
 
 // UnmarshalYAML preserves the raw YAML node for secrets so tags aren't lost.
@@ -95,6 +151,7 @@ func (fc *FileConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		Secrets     yaml.Node   `yaml:"secrets"`
 		Overwrite   bool        `yaml:"overwrite"`
 		Permissions os.FileMode `yaml:"permissions"`
+		Order       string      `yaml:"order"`
 	}
 
 	var raw rawFileConfig
@@ -107,6 +164,7 @@ func (fc *FileConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	fc.Template = raw.Template
 	fc.Overwrite = raw.Overwrite
 	fc.Permissions = raw.Permissions
+	fc.Order = raw.Order
 
 	if raw.Secrets.Kind != 0 {
 		fc.secretsNode = &raw.Secrets
@@ -138,6 +196,11 @@ func (secretMap *SecretsMap) UnmarshalYAML(unmarshal func(interface{}) error) er
 
 // setYAML parses a YAML tag string and value into the SecretSpec's fields.
 func (spec *SecretSpec) setYAML(tag string, value interface{}) error {
+	tag, err := spec.extractConstraints(tag)
+	if err != nil {
+		return err
+	}
+
 	tags := tagRegex.FindAllString(tag, -1)
 	if len(tags) == 0 {
 		spec.Tags = append(spec.Tags, Literal)
@@ -151,6 +214,8 @@ func (spec *SecretSpec) setYAML(tag string, value interface{}) error {
 			spec.Tags = append(spec.Tags, File)
 		case t == "var":
 			spec.Tags = append(spec.Tags, Var)
+		case t == "alias":
+			spec.Tags = append(spec.Tags, Alias)
 		case defaultValueRegex.MatchString(t):
 			match := defaultValueRegex.FindStringSubmatch(t)
 			spec.DefaultValue = match[1]
@@ -162,6 +227,9 @@ func (spec *SecretSpec) setYAML(tag string, value interface{}) error {
 			return fmt.Errorf("unknown tag type: %s", t)
 		}
 	}
+	if spec.IsAlias() && (len(spec.Tags) > 1 || spec.DefaultValue != "" || len(spec.Constraints) > 0) {
+		return fmt.Errorf("!alias can't be combined with other tags")
+	}
 
 	switch v := value.(type) {
 	case int:
@@ -181,18 +249,18 @@ func (spec *SecretSpec) setYAML(tag string, value interface{}) error {
 
 // --- Node-level parsing helpers ---
 
-func parseEnvSecretsFromNode(node *yaml.Node, env string, subs map[string]string) (SecretsMap, error) {
+func parseEnvSecretsFromNode(node *yaml.Node, env string, subs map[string]string, version int) (SecretsMap, error) {
 	if env == "" {
-		return parseSimpleSecretsFromNode(node, subs)
+		return parseSimpleSecretsFromNode(node, subs, version)
 	}
-	if isEnvironmentBasedNode(node) {
-		return parseEnvironmentBasedSecretsFromNode(node, env, subs, "secrets file")
+	if isEnvironmentBasedNode(node, version) {
+		return parseEnvironmentBasedSecretsFromNode(node, env, subs, version, "secrets file")
 	}
 	return nil, fmt.Errorf("No such environment '%s' found in secrets file", env)
 }
 
 // parseFilesSectionFromNode parses the summon.files section from a yaml.Node.
-func parseFilesSectionFromNode(node *yaml.Node, files *[]FileConfig, env string, subs map[string]string) error {
+func parseFilesSectionFromNode(node *yaml.Node, files *[]FileConfig, env string, subs map[string]string, version int) error {
 	if node.Kind != yaml.SequenceNode {
 		return fmt.Errorf("summon.files must be a sequence/array")
 	}
@@ -205,7 +273,7 @@ func parseFilesSectionFromNode(node *yaml.Node, files *[]FileConfig, env string,
 			return fmt.Errorf("failed to decode file config: %w", err)
 		}
 
-		if err := processFileConfigWithNode(&fc, env, subs); err != nil {
+		if err := processFileConfigWithNode(&fc, env, subs, version); err != nil {
 			return fmt.Errorf("failed to process file config: %w", err)
 		}
 
@@ -215,7 +283,7 @@ func parseFilesSectionFromNode(node *yaml.Node, files *[]FileConfig, env string,
 	return nil
 }
 
-func processFileConfigWithNode(fc *FileConfig, env string, subs map[string]string) error {
+func processFileConfigWithNode(fc *FileConfig, env string, subs map[string]string, version int) error {
 	if fc.Permissions == 0 {
 		fc.Permissions = 0600
 	}
@@ -223,32 +291,85 @@ func processFileConfigWithNode(fc *FileConfig, env string, subs map[string]strin
 	if fc.Format == "" {
 		fc.Format = "yaml"
 	}
+	// Default to alphabetical ordering of the rendered secrets
+	if fc.Order == "" {
+		fc.Order = OrderAlphabetical
+	}
+	if err := fc.validateOrder(); err != nil {
+		return err
+	}
 	if fc.secretsNode == nil {
 		return fmt.Errorf("no secrets defined")
 	}
 
 	var secretsMap SecretsMap
 	var err error
-	if isEnvironmentBasedNode(fc.secretsNode) {
-		secretsMap, err = parseEnvironmentBasedSecretsFromNode(fc.secretsNode, env, subs, "file config")
+	if isEnvironmentBasedNode(fc.secretsNode, version) {
+		secretsMap, err = parseEnvironmentBasedSecretsFromNode(fc.secretsNode, env, subs, version, "file config")
 	} else {
-		secretsMap, err = parseSimpleSecretsFromNode(fc.secretsNode, subs)
+		secretsMap, err = parseSimpleSecretsFromNode(fc.secretsNode, subs, version)
 	}
 	if err != nil {
 		return err
 	}
 	fc.Secrets = secretsMap
+	fc.Keys = declaredKeys(fc.secretsNode, env, version)
 	return nil
 }
 
+// declaredKeys returns the secret keys of a mapping node in document order.
+// For environment-based nodes only the keys of the env section and of the
+// common section merged into it are returned.
+func declaredKeys(node *yaml.Node, env string, version int) []string {
+	if env == "" || !isEnvironmentBasedNode(node, version) {
+		return mappingKeys(node)
+	}
+
+	common := ""
+	for _, section := range commonSections {
+		if slices.Contains(mappingKeys(node), section) {
+			common = section
+			break
+		}
+	}
+
+	var keys []string
+	for i := 0; i < len(node.Content); i += 2 {
+		section := node.Content[i].Value
+		if section != env && section != common {
+			continue
+		}
+		for _, key := range mappingKeys(node.Content[i+1]) {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// mappingKeys returns the keys of a mapping node in document order.
+func mappingKeys(node *yaml.Node) []string {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	keys := make([]string, 0, len(node.Content)/2)
+	for i := 0; i < len(node.Content); i += 2 {
+		keys = append(keys, node.Content[i].Value)
+	}
+	return keys
+}
+
 // isEnvironmentBasedNode returns true if all top-level values are mappings
 // (indicating environment sections like "production:", "staging:", etc.).
-func isEnvironmentBasedNode(node *yaml.Node) bool {
+// From version 2 on, mappings that are structured entries are not sections.
+func isEnvironmentBasedNode(node *yaml.Node, version int) bool {
 	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
 		return false
 	}
 	for i := 1; i < len(node.Content); i += 2 {
-		if node.Content[i].Kind != yaml.MappingNode {
+		value := node.Content[i]
+		if value.Kind != yaml.MappingNode || (version >= 2 && isEntryNode(value)) {
 			return false
 		}
 	}
@@ -257,7 +378,17 @@ func isEnvironmentBasedNode(node *yaml.Node) bool {
 
 // parseSimpleSecretsFromNode processes a mapping node's key-value pairs
 // directly to preserve YAML tags (e.g. !var, !file).
-func parseSimpleSecretsFromNode(node *yaml.Node, subs map[string]string) (SecretsMap, error) {
+func parseSimpleSecretsFromNode(node *yaml.Node, subs map[string]string, version int) (SecretsMap, error) {
+	secretsMap, err := parseSecretsMapFromNode(node, version)
+	if err != nil {
+		return nil, err
+	}
+	return applySubstitutionsToMap(secretsMap, subs)
+}
+
+// parseSecretsMapFromNode converts a mapping node into a SecretsMap without
+// applying substitutions.
+func parseSecretsMapFromNode(node *yaml.Node, version int) (SecretsMap, error) {
 	if node.Kind != yaml.MappingNode {
 		return nil, fmt.Errorf("expected mapping node for secrets, got kind %d", node.Kind)
 	}
@@ -265,22 +396,25 @@ func parseSimpleSecretsFromNode(node *yaml.Node, subs map[string]string) (Secret
 	secretsMap := make(SecretsMap, len(node.Content)/2)
 	for i := 0; i < len(node.Content); i += 2 {
 		key := node.Content[i].Value
-		val := node.Content[i+1]
 
-		var spec SecretSpec
-		if err := spec.setYAML(val.Tag, val.Value); err != nil {
+		spec, err := specFromNode(node.Content[i+1], version)
+		if err != nil {
 			return nil, fmt.Errorf("failed to parse secret %q: %w", key, err)
 		}
 		secretsMap[key] = spec
 	}
-	return applySubstitutionsToMap(secretsMap, subs)
+	return secretsMap, nil
 }
 
 // parseEnvironmentBasedSecretsFromNode parses environment-based secrets from a yaml.Node.
-func parseEnvironmentBasedSecretsFromNode(node *yaml.Node, env string, subs map[string]string, context string) (SecretsMap, error) {
+func parseEnvironmentBasedSecretsFromNode(node *yaml.Node, env string, subs map[string]string, version int, context string) (SecretsMap, error) {
 	envSecrets := make(map[string]SecretsMap)
-	if err := node.Decode(&envSecrets); err != nil {
-		return nil, fmt.Errorf("failed to parse secrets for %s: %w", context, err)
+	for i := 0; i < len(node.Content); i += 2 {
+		section, err := parseSecretsMapFromNode(node.Content[i+1], version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse secrets for %s: %w", context, err)
+		}
+		envSecrets[node.Content[i].Value] = section
 	}
 
 	if env == "" {
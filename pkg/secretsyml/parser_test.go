@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -456,6 +457,73 @@ summon.files:
 	assert.Len(t, config.EnvSecrets, 0)
 }
 
+func TestParseFromString_DeclaredOrder(t *testing.T) {
+	t.Run("Env secrets keep declaration order", func(t *testing.T) {
+		input := `
+ZULU: !var z
+ALPHA: !var a
+MIKE: literal
+`
+		config, err := ParseFromString(input, "", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ZULU", "ALPHA", "MIKE"}, config.EnvKeys)
+	})
+
+	t.Run("Environment sections follow document order", func(t *testing.T) {
+		input := `
+common:
+  SHARED: !var shared
+  OVERRIDDEN: common-value
+
+prod:
+  ZULU: !var z
+  OVERRIDDEN: prod-value
+  ALPHA: !var a
+
+dev:
+  DEV_ONLY: !var d
+`
+		config, err := ParseFromString(input, "prod", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"SHARED", "OVERRIDDEN", "ZULU", "ALPHA"}, config.EnvKeys)
+		assert.Equal(t, "prod-value", config.EnvSecrets["OVERRIDDEN"].Path)
+	})
+
+	t.Run("File secrets keep declaration order and order option", func(t *testing.T) {
+		input := `
+summon.files:
+  - path: "/path/to/file1"
+    secrets:
+      ZULU: !var z
+      ALPHA: !var a
+  - path: "/path/to/file2"
+    order: declared
+    secrets:
+      MIKE: !var m
+      BRAVO: !var b
+`
+		config, err := ParseFromString(input, "", nil)
+		assert.NoError(t, err)
+		assert.Len(t, config.Files, 2)
+		assert.Equal(t, OrderAlphabetical, config.Files[0].Order)
+		assert.Equal(t, []string{"ZULU", "ALPHA"}, config.Files[0].Keys)
+		assert.Equal(t, OrderDeclared, config.Files[1].Order)
+		assert.Equal(t, []string{"MIKE", "BRAVO"}, config.Files[1].Keys)
+	})
+
+	t.Run("Unknown order is rejected", func(t *testing.T) {
+		input := `
+summon.files:
+  - path: "/path/to/file"
+    order: random
+    secrets:
+      VAR: !var my/var
+`
+		_, err := ParseFromString(input, "", nil)
+		assert.ErrorContains(t, err, `unknown order "random"`)
+	})
+}
+
 func TestParseFromString_DefaultPermissions(t *testing.T) {
 	input := `
 summon.files:
@@ -599,3 +667,31 @@ summon.files:
 	_, err = ParseFromFile("/nonexistent/file.yml", "", nil)
 	assert.Error(t, err)
 }
+
+func TestParseFromReader(t *testing.T) {
+	config, err := ParseFromReader(strings.NewReader("ENV_VAR: !var my/var\n"), "", nil)
+	assert.NoError(t, err)
+	assert.Contains(t, config.EnvSecrets, "ENV_VAR")
+
+	// Test with a failing reader
+	_, err = ParseFromReader(iotest.ErrReader(fmt.Errorf("read failed")), "", nil)
+	assert.EqualError(t, err, "read failed")
+}
+
+func TestParseEnvNameSettings(t *testing.T) {
+	config, err := ParseFromString("summon.env_prefix: MYAPP_\nsummon.env_case: upper\nDB_URL: !var db/url\n", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "MYAPP_", config.EnvPrefix)
+	assert.Equal(t, EnvCaseUpper, config.EnvCase)
+	assert.Equal(t, []string{"DB_URL"}, config.EnvKeys)
+	assert.Len(t, config.EnvSecrets, 1)
+
+	_, err = ParseFromString("summon.env_case: title\nDB_URL: !var db/url\n", "", nil)
+	assert.EqualError(t, err, `unknown summon.env_case "title" (expected "upper" or "lower")`)
+
+	_, err = ParseFromString("summon.env_prefix: {MYAPP: _}\nDB_URL: !var db/url\n", "", nil)
+	assert.EqualError(t, err, "summon.env_prefix must be a string")
+
+	_, err = ParseFromString("summon.env_case: [upper]\nDB_URL: !var db/url\n", "", nil)
+	assert.EqualError(t, err, "summon.env_case must be a string")
+}
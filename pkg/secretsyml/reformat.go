@@ -0,0 +1,230 @@
+package secretsyml
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ranks of tag segments, in the order they are written in a canonical tag.
+const (
+	tagRankType = iota
+	tagRankFile
+	tagRankConstraint
+	tagRankMatch
+	tagRankDefault
+)
+
+// Reformat rewrites secrets.yml content in its canonical layout: two-space
+// indentation, summon.version first, summon.files last and tags spelled in a
+// canonical order (e.g. `!var:file` rather than `!file:var`). With sortKeys,
+// secrets and environment sections are also sorted by name; this changes the
+// declaration order that @SUMMONENVFILE and `order: declared` files follow.
+// Comments are preserved, and reformatting is idempotent.
+func Reformat(content []byte, sortKeys bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return content, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid YAML structure: expected mapping")
+	}
+
+	version, err := configVersion(root)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		switch key.Value {
+		case versionKey, envPrefixKey, envCaseKey:
+		case "summon.files":
+			if value.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("summon.files must be a sequence/array")
+			}
+			for _, fileNode := range value.Content {
+				if secrets := mappingValue(fileNode, "secrets"); secrets != nil {
+					if err := reformatSecretsNode(secrets, version, sortKeys); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			if err := reformatSecretOrSection(key.Value, value, version, sortKeys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	reorderRoot(root, sortKeys)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// reformatSecretsNode reformats every entry of a secrets mapping, descending
+// into environment sections.
+func reformatSecretsNode(node *yaml.Node, version int, sortKeys bool) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected mapping node for secrets, got kind %d", node.Kind)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if err := reformatSecretOrSection(node.Content[i].Value, node.Content[i+1], version, sortKeys); err != nil {
+			return err
+		}
+	}
+	if sortKeys {
+		sortMappingPairs(node, nil)
+	}
+	return nil
+}
+
+// reformatSecretOrSection reformats a single entry, or each entry of an
+// environment section.
+func reformatSecretOrSection(key string, node *yaml.Node, version int, sortKeys bool) error {
+	if node.Kind == yaml.MappingNode {
+		if version >= 2 && isEntryNode(node) {
+			return nil
+		}
+		return reformatSecretsNode(node, version, sortKeys)
+	}
+
+	if node.Kind != yaml.ScalarNode || !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return nil
+	}
+	tag, err := canonicalTag(node.Tag)
+	if err != nil {
+		return fmt.Errorf("failed to format secret %q: %w", key, err)
+	}
+	node.Tag = tag
+	return nil
+}
+
+// canonicalTag returns tag with its segments in canonical order: the value
+// type (var, str, ...), file, constraints (match last, as its pattern runs to
+// the end of the tag) and finally the default value.
+// Duplicate segments are dropped.
+func canonicalTag(tag string) (string, error) {
+	// Reject tags that wouldn't parse
+	var spec SecretSpec
+	if err := spec.setYAML(tag, ""); err != nil {
+		return "", err
+	}
+
+	head, defaultSegment, tail := strings.TrimPrefix(tag, "!"), "", ""
+	if span := defaultValueRegex.FindStringIndex(head); span != nil {
+		head, defaultSegment, tail = strings.TrimSuffix(head[:span[0]], ":"), head[span[0]:span[1]], head[span[1]:]
+	}
+
+	var segments []string
+	for _, part := range []string{head, tail} {
+		// A match pattern takes the rest of the part, colons included
+		part, pattern, _ := strings.Cut(part, "match=")
+		for _, segment := range strings.Split(part, ":") {
+			if segment != "" && !slices.Contains(segments, segment) {
+				segments = append(segments, segment)
+			}
+		}
+		if pattern != "" {
+			segments = append(segments, "match="+pattern)
+		}
+	}
+	if defaultSegment != "" {
+		segments = append(segments, defaultSegment)
+	}
+
+	slices.SortStableFunc(segments, func(a, b string) int {
+		return tagSegmentRank(a) - tagSegmentRank(b)
+	})
+	return "!" + strings.Join(segments, ":"), nil
+}
+
+// tagSegmentRank returns the position class of a tag segment.
+func tagSegmentRank(segment string) int {
+	switch {
+	case segment == "file":
+		return tagRankFile
+	case defaultValueRegex.MatchString(segment):
+		return tagRankDefault
+	case constraintRegex.MatchString(":" + segment):
+		return tagRankConstraint
+	case strings.HasPrefix(segment, "match="):
+		return tagRankMatch
+	default:
+		return tagRankType
+	}
+}
+
+// reorderRoot moves summon.version, followed by the other summon settings,
+// to the top of the document and summon.files to the bottom, keeping the
+// document's leading comment in place. With sortKeys, the keys in between are
+// sorted, common sections first.
+func reorderRoot(root *yaml.Node, sortKeys bool) {
+	if len(root.Content) == 0 {
+		return
+	}
+	leadingComment := root.Content[0].HeadComment
+	root.Content[0].HeadComment = ""
+
+	rank := func(key string) int {
+		switch {
+		case key == versionKey:
+			return 0
+		case key == envPrefixKey, key == envCaseKey:
+			return 1
+		case key == "summon.files":
+			return 4
+		case sortKeys && slices.Contains(commonSections, key):
+			return 2
+		default:
+			return 3
+		}
+	}
+	sortMappingPairs(root, func(a, b string) int {
+		if d := rank(a) - rank(b); d != 0 || !sortKeys {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+
+	first := root.Content[0]
+	first.HeadComment = strings.TrimSpace(leadingComment + "\n" + first.HeadComment)
+}
+
+// sortMappingPairs stably sorts the key/value pairs of a mapping node with
+// compare, or by key when compare is nil.
+func sortMappingPairs(node *yaml.Node, compare func(a, b string) int) {
+	if compare == nil {
+		compare = strings.Compare
+	}
+
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	slices.SortStableFunc(pairs, func(a, b [2]*yaml.Node) int {
+		return compare(a[0].Value, b[0].Value)
+	})
+
+	node.Content = node.Content[:0]
+	for _, pair := range pairs {
+		node.Content = append(node.Content, pair[0], pair[1])
+	}
+}
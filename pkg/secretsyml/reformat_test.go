@@ -0,0 +1,130 @@
+package secretsyml
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReformat(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		sortKeys    bool
+		expected    string
+	}{
+		{
+			description: "normalises indentation",
+			input:       "production:\n    DB_PASS: !var db/pass\nsummon.files:\n    -   path: out.env\n        secrets:\n            KEY: !var key\n",
+			expected:    "production:\n  DB_PASS: !var db/pass\nsummon.files:\n  - path: out.env\n    secrets:\n      KEY: !var key\n",
+		},
+		{
+			description: "normalises tag spelling",
+			input:       "A: !file:var a\nB: !default='x:y':var:min_len=3 b\nC: !file:str:file c\n",
+			expected:    "A: !var:file a\nB: !var:min_len=3:default='x:y' b\nC: !str:file c\n",
+		},
+		{
+			description: "keeps match patterns whole and last",
+			input:       "URL: !default='http://x':var:match=https?://.+ url\nB: !file:match=a:b:default='ab':var b\n",
+			expected:    "URL: !var:match=https?://.+:default='http://x' url\nB: !var:file:match=a:b:default='ab' b\n",
+		},
+		{
+			description: "moves summon.version first and summon.files last",
+			input:       "# Secrets for the app\nsummon.files:\n  - path: out.env\n    secrets:\n      KEY: !var key\nDB_PASS: !var db/pass\nsummon.version: 2\n",
+			expected:    "# Secrets for the app\nsummon.version: 2\nDB_PASS: !var db/pass\nsummon.files:\n  - path: out.env\n    secrets:\n      KEY: !var key\n",
+		},
+		{
+			description: "moves summon settings after summon.version",
+			input:       "ZULU: z\nsummon.env_prefix: MYAPP_\nALPHA: a\nsummon.version: 2\n",
+			sortKeys:    true,
+			expected:    "summon.version: 2\nsummon.env_prefix: MYAPP_\nALPHA: a\nZULU: z\n",
+		},
+		{
+			description: "preserves comments",
+			input:       "# Header\n\n# Database\nDB_PASS: !file:var db/pass # rotated weekly\n",
+			expected:    "# Header\n\n# Database\nDB_PASS: !var:file db/pass # rotated weekly\n",
+		},
+		{
+			description: "keeps declaration order by default",
+			input:       "ZULU: z\nALPHA: !var a\n",
+			expected:    "ZULU: z\nALPHA: !var a\n",
+		},
+		{
+			description: "sorts keys within environments when asked",
+			input:       "production:\n  ZULU: z\n  ALPHA: !var a\ncommon:\n  MIKE: m\n  BRAVO: b\n",
+			sortKeys:    true,
+			expected:    "common:\n  BRAVO: b\n  MIKE: m\nproduction:\n  ALPHA: !var a\n  ZULU: z\n",
+		},
+		{
+			description: "leaves structured entries as they are",
+			input:       "summon.version: 2\nKEY: {path: key, file: true}\nSECTION:\n  OTHER: !file:var other\n",
+			expected:    "summon.version: 2\nKEY: {path: key, file: true}\nSECTION:\n  OTHER: !var:file other\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			formatted, err := Reformat([]byte(tc.input), tc.sortKeys)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(formatted))
+
+			// Reformatting is idempotent
+			again, err := Reformat(formatted, tc.sortKeys)
+			require.NoError(t, err)
+			assert.Equal(t, string(formatted), string(again))
+		})
+	}
+
+	t.Run("parses to the same configuration", func(t *testing.T) {
+		input := `
+common:
+    DB_HOST: !default='localhost':var db/host
+production:
+    DB_PASS: !file:var:min_len=8 db/pass
+    PORT: 5432
+summon.files:
+    - path: out.env
+      format: dotenv
+      order: declared
+      secrets:
+          TOKEN: !file:var token
+          USER: admin
+`
+		formatted, err := Reformat([]byte(input), false)
+		require.NoError(t, err)
+
+		expected, err := ParseFromString(input, "production", nil)
+		require.NoError(t, err)
+		actual, err := ParseFromString(string(formatted), "production", nil)
+		require.NoError(t, err)
+		assert.Equal(t, sortedTags(withoutNodes(expected)), sortedTags(withoutNodes(actual)))
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		_, err := Reformat([]byte("KEY: !var:min_len=abc key\n"), false)
+		assert.EqualError(t, err, `failed to format secret "KEY": invalid min_len value "abc": expected a non-negative integer`)
+	})
+
+	t.Run("rejects documents that aren't mappings", func(t *testing.T) {
+		_, err := Reformat([]byte("- KEY\n"), false)
+		assert.EqualError(t, err, "invalid YAML structure: expected mapping")
+	})
+}
+
+// sortedTags sorts the tags of every spec in config, whose order carries no
+// meaning.
+func sortedTags(config *ParsedConfig) *ParsedConfig {
+	sortSpecs := func(secrets SecretsMap) {
+		for key, spec := range secrets {
+			slices.Sort(spec.Tags)
+			secrets[key] = spec
+		}
+	}
+	sortSpecs(config.EnvSecrets)
+	for _, file := range config.Files {
+		sortSpecs(file.Secrets.(SecretsMap))
+	}
+	return config
+}
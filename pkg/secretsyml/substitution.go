@@ -9,8 +9,9 @@ import (
 var varSubstRegex = regexp.MustCompile(`\$(\$|\w+)`)
 
 // applySubstitutions replaces $variable references in the spec's Path.
+// Aliases name a secret rather than a path and are left as they are.
 func (spec *SecretSpec) applySubstitutions(subs map[string]string) error {
-	if subs == nil {
+	if subs == nil || spec.IsAlias() {
 		return nil
 	}
 
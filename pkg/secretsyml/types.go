@@ -7,6 +7,7 @@ import (
 	"maps"
 	"os"
 	"slices"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,6 +22,7 @@ const (
 	File YamlTag = iota
 	Var
 	Literal
+	Alias
 )
 
 func (t YamlTag) String() string {
@@ -31,6 +33,8 @@ func (t YamlTag) String() string {
 		return "Var"
 	case Literal:
 		return "Literal"
+	case Alias:
+		return "Alias"
 	default:
 		panic("unreachable!")
 	}
@@ -44,6 +48,10 @@ type SecretSpec struct {
 	Tags         []YamlTag // How to treat the value: variable lookup, file, or literal.
 	Path         string    // Provider path to fetch, or a literal value.
 	DefaultValue string    // Fallback if the provider returns an empty string.
+	Provider     string    // Provider to fetch from instead of the default one.
+	Optional     bool      // Whether a failure to fetch the secret is ignored.
+
+	Constraints []Constraint // Expectations the resolved value must satisfy.
 }
 
 func (spec *SecretSpec) IsFile() bool {
@@ -58,9 +66,48 @@ func (spec *SecretSpec) IsLiteral() bool {
 	return slices.Contains(spec.Tags, Literal)
 }
 
+// IsAlias reports whether the secret takes the value of the secret named by
+// its Path.
+func (spec *SecretSpec) IsAlias() bool {
+	return slices.Contains(spec.Tags, Alias)
+}
+
 // SecretsMap maps environment variable names or aliases to their SecretSpec.
 type SecretsMap map[string]SecretSpec
 
+// Supported values for the `order` field of a summon.files entry.
+const (
+	OrderAlphabetical = "alphabetical"
+	OrderDeclared     = "declared"
+)
+
+// OrderedKeys returns the keys of secrets (a SecretsMap, or the values
+// resolved for one) in the requested order. With OrderDeclared, keys follow
+// their position in declared; keys missing from declared are appended
+// alphabetically. Any other order sorts all keys alphabetically.
+func OrderedKeys[V any](secrets map[string]V, declared []string, order string) []string {
+	keys := make([]string, 0, len(secrets))
+	seen := make(map[string]bool, len(secrets))
+	if order == OrderDeclared {
+		for _, key := range declared {
+			if _, ok := secrets[key]; ok && !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+
+	var rest []string
+	for key := range secrets {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
 // FileConfig represents a single file to be created with secrets.
 type FileConfig struct {
 	Path        string      `yaml:"path"`
@@ -69,6 +116,10 @@ type FileConfig struct {
 	Secrets     interface{} `yaml:"secrets"`     // Will be parsed as SecretsMap or map[string]SecretsMap
 	Overwrite   bool        `yaml:"overwrite"`   // Whether to overwrite existing files
 	Permissions os.FileMode `yaml:"permissions"` // File permissions (e.g., 0644)
+	Order       string      `yaml:"order"`       // "alphabetical" (default) or "declared"
+
+	// Keys lists the secret aliases in the order they are declared.
+	Keys []string `yaml:"-"`
 
 	// secretsNode stores the raw YAML node to preserve tags during parsing
 	secretsNode *yaml.Node
@@ -80,17 +131,43 @@ func (fileConfig *FileConfig) Validate() error {
 		return fmt.Errorf("file config is missing required 'path' field")
 	}
 
+	if err := fileConfig.validateOrder(); err != nil {
+		return err
+	}
+
 	// Further validation is performed in secret_file.go's SecretFile.validate()
 	// and validateSecretsAgainstSpecs() during processing
 
 	return nil
 }
 
+// validateOrder checks that the order field, if set, has a supported value.
+func (fileConfig *FileConfig) validateOrder() error {
+	switch fileConfig.Order {
+	case "", OrderAlphabetical, OrderDeclared:
+		return nil
+	default:
+		return fmt.Errorf("file config for path %q has unknown order %q (expected %q or %q)",
+			fileConfig.Path, fileConfig.Order, OrderDeclared, OrderAlphabetical)
+	}
+}
+
+// Supported values for summon.env_case.
+const (
+	EnvCaseUpper = "upper"
+	EnvCaseLower = "lower"
+)
+
 // ParsedConfig holds the parsed secrets.yml content: environment variable
 // secrets and file-based secret configurations.
 type ParsedConfig struct {
+	Version    int // The secrets.yml format version (summon.version).
 	EnvSecrets SecretsMap
+	EnvKeys    []string // EnvSecrets keys in declaration order.
 	Files      []FileConfig
+
+	EnvPrefix string // Prefix of the exported variable names (summon.env_prefix).
+	EnvCase   string // Case of the exported variable names (summon.env_case), if set.
 }
 
 func (config *ParsedConfig) HasEnvSecrets() bool {
@@ -193,3 +193,25 @@ func TestParsedConfig_FileSecrets(t *testing.T) {
 		assert.Equal(t, "second", config.FileSecrets()["X"].Path)
 	})
 }
+
+func TestOrderedKeys(t *testing.T) {
+	secrets := SecretsMap{"C": {}, "A": {}, "B": {}, "D": {}}
+
+	tests := []struct {
+		name     string
+		declared []string
+		order    string
+		expected []string
+	}{
+		{"Alphabetical ignores declared order", []string{"C", "A", "B", "D"}, OrderAlphabetical, []string{"A", "B", "C", "D"}},
+		{"Empty order is alphabetical", []string{"C", "A", "B", "D"}, "", []string{"A", "B", "C", "D"}},
+		{"Declared order", []string{"C", "A", "B", "D"}, OrderDeclared, []string{"C", "A", "B", "D"}},
+		{"Undeclared keys are appended alphabetically", []string{"D", "UNKNOWN", "B"}, OrderDeclared, []string{"D", "B", "A", "C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, OrderedKeys(secrets, tt.declared, tt.order))
+		})
+	}
+}
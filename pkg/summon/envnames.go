@@ -0,0 +1,86 @@
+package summon
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cyberark/summon/pkg/pushtofile"
+	"github.com/cyberark/summon/pkg/secretsyml"
+)
+
+// envNameTransform renames exported variables: it prepends a prefix and
+// changes the case of the names, see summon.env_prefix and summon.env_case.
+type envNameTransform struct {
+	prefix   string
+	nameCase string
+}
+
+// envNames returns the transform configured by config, with opts taking
+// precedence.
+func envNames(config *secretsyml.ParsedConfig, opts Options) (envNameTransform, error) {
+	transform := envNameTransform{prefix: config.EnvPrefix, nameCase: config.EnvCase}
+	if opts.EnvPrefix != "" {
+		transform.prefix = opts.EnvPrefix
+	}
+	if opts.EnvCase != "" {
+		if err := secretsyml.ValidateEnvCase(opts.EnvCase); err != nil {
+			return transform, err
+		}
+		transform.nameCase = opts.EnvCase
+	}
+	return transform, nil
+}
+
+// name returns the name key is exported as.
+func (t envNameTransform) name(key string) string {
+	name := t.prefix + key
+	switch t.nameCase {
+	case secretsyml.EnvCaseUpper:
+		return strings.ToUpper(name)
+	case secretsyml.EnvCaseLower:
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
+
+// apply renames the variables of env and the declared keys. It fails when
+// two keys would be exported under the same name, or when a name isn't a
+// valid variable name. Without prefix or case, env and keys are returned
+// as they are.
+func (t envNameTransform) apply(env map[string]string, keys []string) (map[string]string, []string, error) {
+	if t == (envNameTransform{}) {
+		return env, keys, nil
+	}
+
+	all := slices.Sorted(maps.Keys(env))
+	for _, key := range keys {
+		if !slices.Contains(all, key) {
+			all = append(all, key)
+		}
+	}
+
+	sources := make(map[string]string, len(all))
+	for _, key := range all {
+		name := t.name(key)
+		if other, ok := sources[name]; ok {
+			return nil, nil, fmt.Errorf("variables %s and %s would both be exported as %s", other, key, name)
+		}
+		if err := pushtofile.ValidateEnvVarName(name); err != nil {
+			return nil, nil, err
+		}
+		sources[name] = key
+	}
+
+	renamed := make(map[string]string, len(env))
+	for key, value := range env {
+		renamed[t.name(key)] = value
+	}
+	renamedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		renamedKeys = append(renamedKeys, t.name(key))
+	}
+	return renamed, renamedKeys, nil
+}
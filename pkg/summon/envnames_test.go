@@ -0,0 +1,66 @@
+package summon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvNameTransform(t *testing.T) {
+	env := map[string]string{"DB_URL": "postgres://db", "api_key": "k"}
+	keys := []string{"api_key", "DB_URL"}
+
+	testCases := []struct {
+		description  string
+		transform    envNameTransform
+		expectedEnv  map[string]string
+		expectedKeys []string
+		err          string
+	}{
+		{
+			description:  "leaves names alone by default",
+			expectedEnv:  env,
+			expectedKeys: keys,
+		},
+		{
+			description:  "prepends the prefix",
+			transform:    envNameTransform{prefix: "MYAPP_"},
+			expectedEnv:  map[string]string{"MYAPP_DB_URL": "postgres://db", "MYAPP_api_key": "k"},
+			expectedKeys: []string{"MYAPP_api_key", "MYAPP_DB_URL"},
+		},
+		{
+			description:  "changes the case after prefixing",
+			transform:    envNameTransform{prefix: "myapp_", nameCase: "upper"},
+			expectedEnv:  map[string]string{"MYAPP_DB_URL": "postgres://db", "MYAPP_API_KEY": "k"},
+			expectedKeys: []string{"MYAPP_API_KEY", "MYAPP_DB_URL"},
+		},
+		{
+			description: "rejects invalid names",
+			transform:   envNameTransform{prefix: "MY-APP_"},
+			err:         `invalid variable name "MY-APP_DB_URL": variable names can only include alphanumerics and underscores, with first char being a non-digit`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			renamed, renamedKeys, err := tc.transform.apply(env, keys)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedEnv, renamed)
+			assert.Equal(t, tc.expectedKeys, renamedKeys)
+		})
+	}
+
+	t.Run("rejects collisions", func(t *testing.T) {
+		_, _, err := envNameTransform{nameCase: "lower"}.apply(map[string]string{"TOKEN": "a", "token": "b"}, nil)
+		assert.EqualError(t, err, "variables TOKEN and token would both be exported as token")
+	})
+
+	t.Run("checks declared keys that weren't fetched", func(t *testing.T) {
+		_, _, err := envNameTransform{nameCase: "upper"}.apply(map[string]string{"TOKEN": "a"}, []string{"TOKEN", "token"})
+		assert.EqualError(t, err, "variables TOKEN and token would both be exported as TOKEN")
+	})
+}
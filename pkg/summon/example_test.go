@@ -0,0 +1,95 @@
+package summon_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/cyberark/summon/pkg/summon"
+)
+
+// mapProvider is an in-process provider.Provider serving secrets from a map.
+type mapProvider map[string]string
+
+func (p mapProvider) Name() string {
+	return "map"
+}
+
+func (p mapProvider) Fetch(_ context.Context, requests []prov.Request) ([]prov.Result, error) {
+	results := make([]prov.Result, 0, len(requests))
+	for _, request := range requests {
+		value, ok := p[request.Path]
+		if !ok {
+			results = append(results, prov.Result{Key: request.Key, Error: fmt.Errorf("%s not found", request.Path)})
+			continue
+		}
+		results = append(results, prov.Result{Key: request.Key, Value: value})
+	}
+	return results, nil
+}
+
+func ExampleResolve() {
+	resolved, err := summon.Resolve(context.Background(), summon.Options{
+		Provider: mapProvider{"prod/db/password": "s3cr3t"},
+		YamlInline: `
+DB_USER: admin
+DB_PASS: !var $env/db/password
+`,
+		Subs: map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resolved.Cleanup()
+
+	for _, kv := range resolved.Environ() {
+		fmt.Println(kv)
+	}
+	// Output:
+	// DB_USER=admin
+	// DB_PASS=s3cr3t
+}
+
+func ExampleResolve_files() {
+	resolved, err := summon.Resolve(context.Background(), summon.Options{
+		Provider: mapProvider{"db/password": "s3cr3t"},
+		YamlInline: `
+summon.files:
+  - path: config/db.env
+    format: dotenv
+    secrets:
+      DB_PASS: !var db/password
+`,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resolved.Cleanup()
+
+	// Files are rendered in memory; call Write to write them to their path.
+	for _, file := range resolved.Files {
+		fmt.Printf("%s:\n%s\n", file.Config.Path, file.Content)
+	}
+	// Output:
+	// config/db.env:
+	// DB_PASS="s3cr3t"
+}
+
+func ExampleResolve_execProvider() {
+	path, err := prov.Resolve("summon-conjur")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resolved, err := summon.Resolve(context.Background(), summon.Options{
+		Provider: prov.NewExec(path),
+		Filepath: "secrets.yml",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resolved.Cleanup()
+
+	fmt.Println(resolved.Keys)
+}
@@ -1,40 +1,138 @@
 package summon
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os/exec"
-	"sort"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 
 	prov "github.com/cyberark/summon/pkg/provider"
 	"github.com/cyberark/summon/pkg/secretsyml"
 )
 
-// fetchSecrets encapsulates the logic of fetching secrets from the provider, including filtering non-variable secrets,
-// handling results from the provider, and falling back to non-interactive mode if necessary.
-func fetchSecrets(secrets secretsyml.SecretsMap, sc *SubprocessConfig, tempFactory *TempFactory) ([]prov.Result, error) {
+// fetchSecrets fetches secrets from provider. Variables that name their own
+// provider (see secretsyml.SecretSpec.Provider) are fetched from that provider
+// instead, created by newProvider or provider.New when nil: it must be one of
+// the provider path or an in-process one, see entryProvider. The providers
+// are called in the order of their names, the main one first. Aliases are
+// left out, see resolveAliases.
+func fetchSecrets(ctx context.Context, secrets secretsyml.SecretsMap, provider prov.Provider, newProvider func(string) (prov.Provider, error), tempFactory *TempFactory) ([]prov.Result, error) {
+	if newProvider == nil {
+		newProvider = prov.New
+	}
+
+	groups := map[string]secretsyml.SecretsMap{"": {}}
+	for key, spec := range secrets {
+		if spec.IsAlias() {
+			continue
+		}
+		name := ""
+		if spec.IsVar() {
+			name = spec.Provider
+		}
+		if groups[name] == nil {
+			groups[name] = secretsyml.SecretsMap{}
+		}
+		groups[name][key] = spec
+	}
+
 	var results []prov.Result
+	for _, name := range slices.Sorted(maps.Keys(groups)) {
+		group := groups[name]
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-	slog.Debug("Fetching secrets", "count", len(secrets), "provider", sc.Provider)
+		groupProvider := provider
+		if name != "" {
+			var err error
+			groupProvider, err = entryProvider(name, newProvider)
+			if err != nil {
+				return nil, err
+			}
+		} else if len(group) == 0 {
+			continue
+		}
 
+		groupResults, err := fetchSecretsFromProvider(ctx, group, groupProvider, tempFactory)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, groupResults...)
+	}
+
+	return results, nil
+}
+
+// entryProvider creates the provider named by an entry with newProvider.
+// Secrets files come with the projects they configure, so entries may only
+// name providers installed in the provider path, which newProvider runs
+// with their settings (checksum pin, environment), or in-process ones: not
+// executables given by path.
+func entryProvider(name string, newProvider func(string) (prov.Provider, error)) (prov.Provider, error) {
+	inProcess := strings.HasPrefix(name, prov.BuiltinPrefix) || strings.HasPrefix(name, prov.MockPrefix)
+	if !inProcess && (filepath.Base(name) != name || name == "." || name == "..") {
+		return nil, fmt.Errorf("Unable to resolve provider %q: entries may only name providers of the provider path, or builtin: ones", name)
+	}
+	resolved, err := prov.Resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to resolve provider %q: %w", name, err)
+	}
+	return newProvider(resolved)
+}
+
+// fetchSecretsFromProvider encapsulates the logic of fetching secrets from the provider: non-variable
+// secrets are resolved as-is, variables are fetched from the provider and every value is then resolved
+// against its spec.
+func fetchSecretsFromProvider(ctx context.Context, secrets secretsyml.SecretsMap, provider prov.Provider, tempFactory *TempFactory) ([]prov.Result, error) {
 	// Filter out non variables
-	filteredResults, filteredSecrets := filterNonVariables(secrets, tempFactory)
-	results = append(results, filteredResults...)
+	results, variables := filterNonVariables(secrets, tempFactory)
+	if len(variables) == 0 {
+		return results, nil
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("Unable to fetch secrets: no provider configured")
+	}
 
-	// Call provider with no arguments
-	resultsCh, errorsCh, cleanup := prov.CallInteractiveMode(sc.Provider, filteredSecrets)
-	defer cleanup()
+	slog.Debug("Fetching secrets", "count", len(variables), "provider", provider.Name())
 
-	// This extracts the logic of handling results from provider interactive mode
-	resultsFromProvider, err := handleResultsFromProvider(resultsCh, errorsCh, filteredSecrets, tempFactory)
-	results = append(results, resultsFromProvider...)
+	keys := slices.Sorted(maps.Keys(variables))
+	requests := make([]prov.Request, 0, len(keys))
+	for _, key := range keys {
+		requests = append(requests, prov.Request{Key: key, Path: variables[key].Path})
+	}
 
+	fetched, err := provider.Fetch(ctx, requests)
 	if err != nil {
-		results = nonInteractiveProviderFallback(secrets, sc, tempFactory)
+		return nil, fmt.Errorf("Unable to fetch secrets from provider %s: %w", provider.Name(), err)
+	}
+
+	for _, result := range fetched {
+		spec, ok := variables[result.Key]
+		if !ok {
+			slog.Debug("Ignoring unrequested secret from provider", "name", result.Key, "provider", provider.Name())
+			continue
+		}
+		delete(variables, result.Key)
+
+		if result.Error != nil {
+			results = append(results, prov.Result{Key: result.Key, Value: "", Error: result.Error})
+			continue
+		}
+		results = append(results, resolveResult(result.Key, result.Value, spec, tempFactory))
+	}
+
+	// Every requested secret must have a result
+	for _, key := range keys {
+		if _, missing := variables[key]; missing {
+			results = append(results, prov.Result{Key: key, Value: "", Error: fmt.Errorf("provider %s returned no value for %s", provider.Name(), key)})
+		}
 	}
 
 	return results, nil
@@ -45,98 +143,70 @@ func filterNonVariables(secrets secretsyml.SecretsMap, tempFactory *TempFactory)
 	results := []prov.Result{}
 
 	for key, spec := range secrets {
+		if spec.IsAlias() {
+			continue
+		}
 		if spec.IsVar() {
 			filteredSecrets[key] = spec
 		} else {
-			k, v, err := formatForEnv(key, spec.Path, spec, tempFactory)
-			var result prov.Result
-			if err != nil {
-				result = prov.Result{Key: key, Value: "", Error: err}
-			} else {
-				result = prov.Result{Key: k, Value: v, Error: nil}
-			}
-			results = append(results, result)
+			results = append(results, resolveResult(key, spec.Path, spec, tempFactory))
 		}
 	}
 
 	return results, filteredSecrets
 }
 
-func handleResultsFromProvider(resultsCh chan prov.Result, errorsCh chan error,
-	filteredSecrets secretsyml.SecretsMap, tempFactory *TempFactory) (results []prov.Result, err error) {
-	for {
-		select {
-		case result, ok := <-resultsCh:
-			if !ok {
-				return results, nil
-			}
-
-			spec := filteredSecrets[result.Key]
+// resolveAliases returns a result for each alias in secrets, copied from
+// the result of the secret the alias refers to, so that the secret is only
+// fetched once. Targets are looked up in results or, for targets found in
+// fallback (see secretsyml.AliasTarget), in fallbackResults.
+func resolveAliases(secrets, fallback secretsyml.SecretsMap, results, fallbackResults []prov.Result) []prov.Result {
+	var aliases []prov.Result
+	for _, key := range slices.Sorted(maps.Keys(secrets)) {
+		if spec := secrets[key]; !spec.IsAlias() {
+			continue
+		}
 
-			// Set a default value if the provider didn't return one for the item
-			if result.Value == "" && spec.DefaultValue != "" {
-				result.Value = spec.DefaultValue
-			}
-			k, v, err := formatForEnv(result.Key, result.Value, spec, tempFactory)
-			if err != nil {
-				result = prov.Result{Key: result.Key, Value: "", Error: err}
-			} else {
-				result = prov.Result{Key: k, Value: v, Error: nil}
-			}
-			results = append(results, result)
+		target, inFallback, err := secretsyml.AliasTarget(key, secrets, fallback)
+		if err != nil {
+			aliases = append(aliases, prov.Result{Key: key, Value: "", Error: err})
+			continue
+		}
 
-		// Fallback to the old implementation if either provider doesn't support interactive mode or an error occured
-		case err = <-errorsCh:
-			return nil, err
+		targetResults := results
+		if inFallback {
+			targetResults = fallbackResults
+		}
+		i := slices.IndexFunc(targetResults, func(r prov.Result) bool { return r.Key == target })
+		if i < 0 {
+			aliases = append(aliases, prov.Result{Key: key, Value: "", Error: fmt.Errorf("no value for %s, aliased by %s", target, key)})
+			continue
 		}
+
+		slog.Debug("Resolved alias", "name", key, "target", target)
+		aliases = append(aliases, prov.Result{Key: key, Value: targetResults[i].Value, Error: targetResults[i].Error})
 	}
+	return aliases
 }
 
-func nonInteractiveProviderFallback(secrets secretsyml.SecretsMap, sc *SubprocessConfig, tempFactory *TempFactory) []prov.Result {
-	results := make(chan prov.Result, len(secrets))
-	var wg sync.WaitGroup
-
-	for key, spec := range secrets {
-		wg.Add(1)
-		go func(key string, spec secretsyml.SecretSpec) {
-			defer wg.Done()
-
-			var value string
-			if spec.IsVar() {
-				slog.Debug("Fetching secret", "name", key, "provider", sc.Provider)
-				valueBytes, err := sc.FetchSecret(spec.Path)
-				if err != nil {
-					results <- prov.Result{Key: key, Value: "", Error: err}
-					return
-				}
-				value = string(valueBytes)
-				clear(valueBytes)
-			} else {
-				// If the spec isn't a variable, use its value as-is
-				value = spec.Path
-			}
-
-			// Set a default value if the provider didn't return one for the item
-			if value == "" && spec.DefaultValue != "" {
-				value = spec.DefaultValue
-			}
+// resolveResult turns a value fetched for key into a result: it applies the
+// spec's default value when the value is empty, checks the value against the
+// spec's constraints and formats it for the environment.
+func resolveResult(key string, value string, spec secretsyml.SecretSpec, tempFactory *TempFactory) prov.Result {
+	// Set a default value if the provider didn't return one for the item
+	if value == "" && spec.DefaultValue != "" {
+		value = spec.DefaultValue
+	}
 
-			k, v, err := formatForEnv(key, value, spec, tempFactory)
-			if err != nil {
-				results <- prov.Result{Key: key, Value: "", Error: err}
-			} else {
-				results <- prov.Result{Key: k, Value: v, Error: nil}
-			}
-		}(key, spec)
+	if err := spec.CheckValue(value); err != nil {
+		return prov.Result{Key: key, Value: "", Error: fmt.Errorf("invalid value for %s: %w", key, err)}
 	}
-	wg.Wait()
-	close(results)
 
-	resultsSlice := make([]prov.Result, 0, len(secrets))
-	for result := range results {
-		resultsSlice = append(resultsSlice, result)
+	k, v, err := formatForEnv(key, value, spec, tempFactory)
+	if err != nil {
+		return prov.Result{Key: key, Value: "", Error: err}
 	}
-	return resultsSlice
+	return prov.Result{Key: k, Value: v, Error: nil}
 }
 
 func returnStatusOfError(err error) (int, error) {
@@ -164,17 +234,17 @@ func formatForEnv(key string, value string, spec secretsyml.SecretSpec, tempFact
 	return key, value, nil
 }
 
-func joinEnv(env map[string]string) string {
+// joinEnv renders env as KEY=VALUE lines. Keys listed in order come first,
+// in that order; any remaining keys follow alphabetically.
+func joinEnv(env map[string]string, order []string) string {
 	var envs []string
-	for k, v := range env {
+	for _, k := range secretsyml.OrderedKeys(env, order, secretsyml.OrderDeclared) {
+		v := env[k]
 		if strings.ContainsAny(v, " \t\n\r\"'\\") {
 			v = strconv.Quote(v)
 		}
 		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	// Sort to ensure predictable results
-	sort.Strings(envs)
-
 	return strings.Join(envs, "\n") + "\n"
 }
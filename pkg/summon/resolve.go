@@ -0,0 +1,268 @@
+package summon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/cyberark/summon/pkg/pushtofile"
+	"github.com/cyberark/summon/pkg/secretsyml"
+)
+
+// Options configures Resolve.
+type Options struct {
+	// Provider fetches the variables of the configuration. Entries naming
+	// their own provider are fetched from that provider instead, created by
+	// NewProvider.
+	Provider prov.Provider
+	// NewProvider creates the providers named by entries, from a name
+	// returned by provider.Resolve. Defaults to provider.New, which runs
+	// executables with the default provider.ExecOptions: pass a function
+	// applying the pin and environment policy of the main provider instead.
+	// Entries may only name providers of the provider path, or builtin ones.
+	NewProvider func(name string) (prov.Provider, error)
+
+	Filepath    string            // Path to the configuration, or "-" to read it from stdin
+	YamlInline  string            // Configuration content, used instead of Filepath when set
+	Environment string            // Environment section to use
+	Subs        map[string]string // Values for $-substitutions in the configuration
+	Ignores     []string          // Keys whose fetch failures are ignored
+	IgnoreAll   bool              // Ignore all fetch failures
+	RecurseUp   bool              // Look for Filepath in the parent directories
+	TempDir     string            // Directory for the files backing !file secrets; defaults to /dev/shm when available
+	EnvPrefix   string            // Prefix of the exported variable names, instead of summon.env_prefix
+	EnvCase     string            // Case of the exported variable names, instead of summon.env_case
+
+	// Stats, when set, records the duration of each phase and how secrets
+	// are fetched.
+	Stats *Stats
+}
+
+// Resolved holds the secrets resolved from a configuration. Call Cleanup
+// once they are no longer needed.
+type Resolved struct {
+	// Env maps environment variable names to their values. Secrets tagged
+	// !file map to the path of a temporary file holding the value. SUMMON_ENV
+	// is set when an environment was selected.
+	Env map[string]string
+	// Keys lists the names in Env, in the order they are declared.
+	Keys []string
+	// Files holds the rendered content of each summon.files entry.
+	Files []ResolvedFile
+
+	tempFactory *TempFactory
+}
+
+// ResolvedFile is a summon.files entry rendered with its secrets.
+type ResolvedFile struct {
+	Config  secretsyml.FileConfig
+	Content []byte
+}
+
+// Resolve parses the secrets configuration described by opts and fetches
+// its secrets, without writing any files or running a command. Temporary
+// files are only created for !file secrets. Cancelling ctx stops providers
+// and removes the temporary files. Environment variables are renamed as
+// configured by summon.env_prefix and summon.env_case once fetched, so
+// ignores and aliases refer to the names declared in the configuration.
+func Resolve(ctx context.Context, opts Options) (*Resolved, error) {
+	if opts.Stats != nil {
+		ctx = prov.WithStats(ctx, &opts.Stats.Stats)
+	}
+
+	start := time.Now()
+	config, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Stats.phase("parse", start)
+
+	tempFactory := NewTempFactory(opts.TempDir)
+	resolved := &Resolved{Env: map[string]string{}, tempFactory: &tempFactory}
+
+	// Note: This implementation will cause duplicate calls to the provider if
+	// there are secrets needed for both env and files. We can optimize this in
+	// the future by calling the provider once and then splitting the results
+	// based on whether they're needed for env or files. We can do this by
+	// creating a Set of all the secret paths to fetch, calling the provider
+	// once with that set, and then processing the results to populate both env
+	// and files as needed.
+
+	transform, err := envNames(config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch secrets needed for environment variables
+	var envResults []prov.Result
+	envKeys := config.EnvKeys
+	if config.HasEnvSecrets() {
+		start := time.Now()
+		envResults, err = fetchSecrets(ctx, config.EnvSecrets, opts.Provider, opts.NewProvider, &tempFactory)
+		if err != nil {
+			resolved.Cleanup()
+			return nil, err
+		}
+		envResults = append(envResults, resolveAliases(config.EnvSecrets, nil, envResults, nil)...)
+		resolved.Env, err = processEnvResults(envResults, config, opts)
+		if err != nil {
+			resolved.Cleanup()
+			return nil, err
+		}
+		resolved.Env, envKeys, err = transform.apply(resolved.Env, config.EnvKeys)
+		if err != nil {
+			resolved.Cleanup()
+			return nil, err
+		}
+		opts.Stats.phase("fetch", start)
+	}
+
+	// Append environment variable if one is specified
+	if opts.Environment != "" {
+		resolved.Env[summonEnvKeyName] = opts.Environment
+	}
+	resolved.Keys = secretsyml.OrderedKeys(resolved.Env, envKeys, secretsyml.OrderDeclared)
+
+	if config.HasFileSecrets() {
+		start := time.Now()
+		fileSecrets := config.FileSecrets()
+		fileResults, err := fetchSecrets(ctx, fileSecrets, opts.Provider, opts.NewProvider, &tempFactory)
+		if err != nil {
+			resolved.Cleanup()
+			return nil, err
+		}
+		// Aliases of environment variables reuse the values fetched above
+		fileResults = append(fileResults, resolveAliases(fileSecrets, config.EnvSecrets, fileResults, envResults)...)
+		opts.Stats.phase("fetch-files", start)
+
+		start = time.Now()
+		resolved.Files, err = renderFiles(fileResults, config.Files, opts)
+		if err != nil {
+			resolved.Cleanup()
+			return nil, err
+		}
+		opts.Stats.phase("render", start)
+	}
+
+	return resolved, nil
+}
+
+// Environ returns Env as "KEY=VALUE" strings in the order of Keys, as used
+// by os/exec.
+func (r *Resolved) Environ() []string {
+	env := make([]string, 0, len(r.Keys))
+	for _, k := range r.Keys {
+		env = append(env, fmt.Sprintf("%s=%s", k, r.Env[k]))
+	}
+	return env
+}
+
+// Cleanup removes the temporary files backing !file secrets.
+func (r *Resolved) Cleanup() {
+	r.tempFactory.Cleanup()
+}
+
+// Write writes the file to its configured path with its configured
+// permissions and overwrite setting, and returns the absolute path written.
+// Nothing is written once ctx is done.
+func (f *ResolvedFile) Write(ctx context.Context) (string, error) {
+	secretFile := pushtofile.SecretFile{FileConfig: f.Config}
+	return secretFile.WriteContent(ctx, f.Content)
+}
+
+// LocateConfig returns the path of the secrets configuration file summon
+// reads for filePath, looking for it in the parent directories of the
+// current directory with recurseUp (the --up flag). There is nothing to
+// search for when the configuration comes from stdin or from a pipe or
+// device such as /dev/fd/N (process substitution).
+func LocateConfig(filePath string, recurseUp bool) (string, error) {
+	if !recurseUp || filePath == stdinFilepath || isSpecialFile(filePath) {
+		return filePath, nil
+	}
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return findInParentTree(filePath, currentDir)
+}
+
+// loadConfig locates and parses the secrets configuration described by opts.
+func loadConfig(opts Options) (*secretsyml.ParsedConfig, error) {
+	// RecurseUp applies even to inline YAML: Filepath must then exist in the
+	// current or a parent directory
+	filePath, err := LocateConfig(opts.Filepath, opts.RecurseUp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the secrets configuration from a file or inline YAML
+	switch {
+	case opts.YamlInline != "":
+		slog.Debug("Loading summon configuration from inline YAML")
+		config, err := secretsyml.ParseFromString(opts.YamlInline, opts.Environment, opts.Subs)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse configuration from inline YAML: %w", err)
+		}
+		return config, nil
+	case filePath == stdinFilepath:
+		slog.Debug("Loading summon configuration from stdin")
+		config, err := secretsyml.ParseFromReader(configStdin, opts.Environment, opts.Subs)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse configuration from stdin: %w", err)
+		}
+		return config, nil
+	default:
+		slog.Debug("Loading summon configuration", "filename", filePath)
+		config, err := secretsyml.ParseFromFile(filePath, opts.Environment, opts.Subs)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse configuration from %s: %w", filePath, err)
+		}
+		return config, nil
+	}
+}
+
+// processEnvResults collects provider results into an environment map. It
+// handles error cases with ignore logic.
+func processEnvResults(results []prov.Result, config *secretsyml.ParsedConfig, opts Options) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, envvar := range results {
+		if envvar.Error == nil {
+			env[envvar.Key] = envvar.Value
+			continue
+		}
+
+		if opts.IgnoreAll || slices.Contains(opts.Ignores, envvar.Key) || config.EnvSecrets[envvar.Key].Optional {
+			continue
+		}
+
+		slog.Debug("Error fetching secret", "name", envvar.Key, "error", envvar.Error)
+		return nil, fmt.Errorf("Error fetching secret: %w", envvar.Error)
+	}
+	return env, nil
+}
+
+// renderFiles renders each of filesConfig with the provider results.
+func renderFiles(results []prov.Result, filesConfig []secretsyml.FileConfig, opts Options) ([]ResolvedFile, error) {
+	files := make([]ResolvedFile, 0, len(filesConfig))
+	for _, file := range filesConfig {
+		if err := file.Validate(); err != nil {
+			return nil, err
+		}
+
+		secretFile := pushtofile.SecretFile{
+			FileConfig: file,
+			Ignores:    opts.Ignores,
+			IgnoreAll:  opts.IgnoreAll,
+		}
+		content, err := secretFile.Render(results)
+		if err != nil {
+			return nil, fmt.Errorf("error writing secret file for path %s: %v", file.Path, err)
+		}
+		files = append(files, ResolvedFile{Config: file, Content: content})
+	}
+	return files, nil
+}
@@ -0,0 +1,225 @@
+package summon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/summon/pkg/localstore"
+	prov "github.com/cyberark/summon/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	provider := &fakeProvider{values: map[string]string{
+		"db/password": "s3cr3t",
+		"db/cert":     "-----CERT-----",
+	}}
+
+	t.Run("Resolves env and files in memory", func(t *testing.T) {
+		dir := t.TempDir()
+		filePath := filepath.Join(dir, "db.env")
+
+		resolved, err := Resolve(context.Background(), Options{
+			Provider: provider,
+			YamlInline: `
+production:
+  DB_USER: admin
+  DB_PASS: !var db/password
+  DB_CERT: !var:file db/cert
+summon.files:
+  - path: ` + filePath + `
+    format: dotenv
+    order: declared
+    secrets:
+      PASSWORD: !var db/password
+      USER: admin
+`,
+			Environment: "production",
+			TempDir:     dir,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"DB_USER", "DB_PASS", "DB_CERT", "SUMMON_ENV"}, resolved.Keys)
+		assert.Equal(t, "admin", resolved.Env["DB_USER"])
+		assert.Equal(t, "s3cr3t", resolved.Env["DB_PASS"])
+		assert.Equal(t, "production", resolved.Env["SUMMON_ENV"])
+		assert.Equal(t, []string{
+			"DB_USER=admin",
+			"DB_PASS=s3cr3t",
+			"DB_CERT=" + resolved.Env["DB_CERT"],
+			"SUMMON_ENV=production",
+		}, resolved.Environ())
+
+		certContent, err := os.ReadFile(resolved.Env["DB_CERT"])
+		require.NoError(t, err)
+		assert.Equal(t, "-----CERT-----", string(certContent))
+
+		require.Len(t, resolved.Files, 1)
+		assert.Equal(t, filePath, resolved.Files[0].Config.Path)
+		assert.Equal(t, "PASSWORD=\"s3cr3t\"\nUSER=\"admin\"", string(resolved.Files[0].Content))
+		assert.NoFileExists(t, filePath)
+
+		written, err := resolved.Files[0].Write(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, filePath, written)
+		assert.FileExists(t, filePath)
+
+		resolved.Cleanup()
+		assert.NoFileExists(t, resolved.Env["DB_CERT"])
+	})
+
+	t.Run("Returns fetch errors unless ignored", func(t *testing.T) {
+		opts := Options{
+			Provider:   provider,
+			YamlInline: "DB_PASS: !var db/password\nAPI_KEY: !var api/key",
+		}
+
+		_, err := Resolve(context.Background(), opts)
+		assert.EqualError(t, err, "Error fetching secret: no such secret: api/key")
+
+		opts.Ignores = []string{"API_KEY"}
+		resolved, err := Resolve(context.Background(), opts)
+		require.NoError(t, err)
+		defer resolved.Cleanup()
+		assert.Equal(t, []string{"DB_PASS=s3cr3t"}, resolved.Environ())
+	})
+
+	t.Run("Fetches aliased secrets once", func(t *testing.T) {
+		provider := &fakeProvider{values: map[string]string{"db/password": "s3cr3t"}}
+		filePath := filepath.Join(t.TempDir(), "pgpass.env")
+
+		resolved, err := Resolve(context.Background(), Options{
+			Provider: provider,
+			YamlInline: `
+DATABASE_PASSWORD: !var db/password
+PGPASSWORD: !alias DATABASE_PASSWORD
+LEGACY_PASSWORD: !alias PGPASSWORD
+summon.files:
+  - path: ` + filePath + `
+    format: dotenv
+    secrets:
+      PASSWORD: !alias DATABASE_PASSWORD
+`,
+		})
+		require.NoError(t, err)
+		defer resolved.Cleanup()
+
+		assert.Equal(t, []string{
+			"DATABASE_PASSWORD=s3cr3t",
+			"PGPASSWORD=s3cr3t",
+			"LEGACY_PASSWORD=s3cr3t",
+		}, resolved.Environ())
+		require.Len(t, resolved.Files, 1)
+		assert.Equal(t, "PASSWORD=\"s3cr3t\"", string(resolved.Files[0].Content))
+		assert.Equal(t, []prov.Request{{Key: "DATABASE_PASSWORD", Path: "db/password"}}, provider.requests)
+	})
+
+	t.Run("Aliases share the errors of their target", func(t *testing.T) {
+		opts := Options{
+			Provider:   provider,
+			YamlInline: "API_KEY: !var api/key\nLEGACY_API_KEY: !alias API_KEY",
+		}
+
+		_, err := Resolve(context.Background(), opts)
+		assert.EqualError(t, err, "Error fetching secret: no such secret: api/key")
+
+		opts.YamlInline = "summon.version: 2\nAPI_KEY: {path: api/key, optional: true}\nLEGACY_API_KEY: !alias API_KEY"
+		resolved, err := Resolve(context.Background(), opts)
+		require.NoError(t, err)
+		defer resolved.Cleanup()
+		assert.Empty(t, resolved.Environ())
+	})
+
+	t.Run("Renames exported variables", func(t *testing.T) {
+		opts := Options{
+			Provider:    provider,
+			YamlInline:  "summon.env_prefix: MYAPP_\nDB_PASS: !var db/password\ndb_user: admin",
+			Environment: "",
+			Ignores:     []string{"DB_PASS"},
+		}
+
+		resolved, err := Resolve(context.Background(), opts)
+		require.NoError(t, err)
+		defer resolved.Cleanup()
+		assert.Equal(t, []string{"MYAPP_DB_PASS=s3cr3t", "MYAPP_db_user=admin"}, resolved.Environ())
+
+		opts.EnvPrefix, opts.EnvCase = "OTHER_", "upper"
+		resolved, err = Resolve(context.Background(), opts)
+		require.NoError(t, err)
+		defer resolved.Cleanup()
+		assert.Equal(t, []string{"OTHER_DB_PASS=s3cr3t", "OTHER_DB_USER=admin"}, resolved.Environ())
+
+		opts.EnvCase = "camel"
+		_, err = Resolve(context.Background(), opts)
+		assert.EqualError(t, err, `unknown summon.env_case "camel" (expected "upper" or "lower")`)
+	})
+
+	t.Run("Fetches entries from builtin providers", func(t *testing.T) {
+		storePath := filepath.Join(t.TempDir(), "store.json")
+		t.Setenv(localstore.PassphraseEnvVar, "pw")
+		store, err := localstore.Open(storePath, "pw", false)
+		require.NoError(t, err)
+		store.Set("db/password", "from-store")
+		require.NoError(t, store.Save())
+
+		resolved, err := Resolve(context.Background(), Options{
+			Provider:   provider,
+			YamlInline: "summon.version: 2\nDB_PASS: {path: db/password, provider: builtin:localstore:" + storePath + "}\nDB_CERT: {path: db/cert}\n",
+		})
+		require.NoError(t, err)
+		defer resolved.Cleanup()
+		assert.Equal(t, map[string]string{"DB_PASS": "from-store", "DB_CERT": "-----CERT-----"}, resolved.Env)
+	})
+
+	t.Run("Only runs entry providers of the provider path, in order", func(t *testing.T) {
+		for _, name := range []string{"./provider", "/bin/sh", "..", "dir/provider"} {
+			_, err := Resolve(context.Background(), Options{
+				Provider:    provider,
+				YamlInline:  "summon.version: 2\nDB_PASS: {path: db/password, provider: \"" + name + "\"}\n",
+				NewProvider: func(string) (prov.Provider, error) { panic("not reached") },
+			})
+			assert.EqualError(t, err, `Unable to resolve provider "`+name+`": entries may only name providers of the provider path, or builtin: ones`)
+		}
+
+		dir := t.TempDir()
+		for _, name := range []string{"summon-a", "summon-b", "summon-c"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o755))
+		}
+		t.Setenv("SUMMON_PROVIDER_PATH", dir)
+		var created []string
+		_, err := Resolve(context.Background(), Options{
+			Provider:   provider,
+			YamlInline: "summon.version: 2\nC: {path: db/cert, provider: summon-c}\nA: {path: db/password, provider: summon-a}\nB: {path: db/password, provider: summon-b}\n",
+			NewProvider: func(name string) (prov.Provider, error) {
+				created = append(created, filepath.Base(name))
+				return provider, nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"summon-a", "summon-b", "summon-c"}, created)
+	})
+
+	t.Run("Requires a provider for variables", func(t *testing.T) {
+		_, err := Resolve(context.Background(), Options{YamlInline: "DB_PASS: !var db/password"})
+		assert.EqualError(t, err, "Unable to fetch secrets: no provider configured")
+	})
+
+	t.Run("Stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := Resolve(ctx, Options{
+			Provider:   provider,
+			YamlInline: "DB_PASS: !var db/password",
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Returns parse errors", func(t *testing.T) {
+		_, err := Resolve(context.Background(), Options{Filepath: filepath.Join(t.TempDir(), "missing.yml")})
+		assert.ErrorContains(t, err, "Unable to parse configuration from")
+	})
+}
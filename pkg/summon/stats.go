@@ -0,0 +1,48 @@
+package summon
+
+import (
+	"time"
+
+	prov "github.com/cyberark/summon/pkg/provider"
+)
+
+// Stats reports where the time of a run goes, see Options.Stats: the
+// duration of each phase, how the providers were used and how much was
+// written to each file. Secret values are never recorded.
+type Stats struct {
+	prov.Stats
+
+	Phases []PhaseStats `json:"phases"`
+	Files  []FileStats  `json:"files"`
+}
+
+// PhaseStats is the duration of a phase of a run: "parse" (locating and
+// parsing the configuration), "fetch" (fetching the variables),
+// "fetch-files" (fetching the secrets of summon.files), "render"
+// (rendering summon.files) and "write" (writing them and the
+// @SUMMONENVFILE file).
+type PhaseStats struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// FileStats is the size of a file written by a run.
+type FileStats struct {
+	Path  string `json:"path"`
+	Bytes int    `json:"bytes"`
+}
+
+// phase records the time since start as the duration of the phase called
+// name. It does nothing on a nil Stats.
+func (s *Stats) phase(name string, start time.Time) {
+	if s != nil {
+		s.Phases = append(s.Phases, PhaseStats{Name: name, Duration: time.Since(start)})
+	}
+}
+
+// file records the size of a file written. It does nothing on a nil Stats.
+func (s *Stats) file(path string, bytes int) {
+	if s != nil {
+		s.Files = append(s.Files, FileStats{Path: path, Bytes: bytes})
+	}
+}
@@ -1,9 +1,14 @@
 package summon
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
@@ -16,7 +21,12 @@ import (
 // SIGHUP) is forwarded to the child as-is. A second termination
 // signal force-kills the child with SIGKILL, ensuring that
 // runSubcommand always returns and deferred cleanup can run.
-func runSubcommand(command []string, env []string) error {
+//
+// stopCancel stops the cancellation of ctx on signals (see cancelOnSignal).
+// It is called once forwarding is set up, so that no signal goes unhandled in
+// between; if ctx was interrupted meanwhile, the command isn't started and
+// the *InterruptedError is returned.
+func runSubcommand(ctx context.Context, command []string, env []string, stopCancel func()) error {
 	binary, lookupErr := exec.LookPath(command[0])
 	if lookupErr != nil {
 		return lookupErr
@@ -32,6 +42,11 @@ func runSubcommand(command []string, env []string) error {
 	signal.Notify(signalChannel)
 	defer signal.Stop(signalChannel)
 
+	stopCancel()
+	if interrupted := interruption(ctx); interrupted != nil {
+		return interrupted
+	}
+
 	if startErr := runner.Start(); startErr != nil {
 		return startErr
 	}
@@ -74,3 +89,69 @@ func isTermSignal(sig os.Signal) bool {
 	}
 	return false
 }
+
+// InterruptedError is returned by RunSubprocess when a termination signal
+// arrives before the subprocess is started.
+type InterruptedError struct {
+	Signal os.Signal
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("Interrupted by %s before running the command", e.Signal)
+}
+
+// ExitCode returns the exit status for the interruption: 128 plus the signal
+// number, as shells report commands terminated by a signal.
+func (e *InterruptedError) ExitCode() int {
+	if sig, ok := e.Signal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return 128 + int(syscall.SIGINT)
+}
+
+// cancelOnSignal returns a copy of parent that is cancelled with an
+// *InterruptedError when SIGINT or SIGTERM is received, until the returned
+// stop function is called.
+func cancelOnSignal(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signalChannel:
+			slog.Debug("Received signal, cancelling", "signal", sig)
+			cancel(&InterruptedError{Signal: sig})
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signalChannel)
+			close(done)
+			cancel(nil)
+		})
+	}
+}
+
+// interruption returns the *InterruptedError ctx was cancelled with, if any.
+func interruption(ctx context.Context) *InterruptedError {
+	var interrupted *InterruptedError
+	if errors.As(context.Cause(ctx), &interrupted) {
+		return interrupted
+	}
+	return nil
+}
+
+// interruptedOr returns the exit code and error for an interruption of ctx,
+// or err when ctx wasn't interrupted.
+func interruptedOr(ctx context.Context, err error) (int, error) {
+	if interrupted := interruption(ctx); interrupted != nil {
+		return interrupted.ExitCode(), interrupted
+	}
+	return 0, err
+}
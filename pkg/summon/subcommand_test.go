@@ -1,7 +1,9 @@
 package summon
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"syscall"
 	"testing"
 	"time"
@@ -29,8 +31,10 @@ func TestRunSubcommand_SignalEscalation(t *testing.T) {
 	done := make(chan error, 1)
 	go func() {
 		done <- runSubcommand(
+			context.Background(),
 			[]string{"bash", "-c", script},
 			os.Environ(),
+			func() {},
 		)
 	}()
 
@@ -62,3 +66,16 @@ func TestRunSubcommand_SignalEscalation(t *testing.T) {
 		t.Fatal("runSubcommand did not return after second SIGTERM; signal escalation is broken")
 	}
 }
+
+func TestRunSubcommand_InterruptedBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&InterruptedError{Signal: syscall.SIGINT})
+	marker := filepath.Join(t.TempDir(), "started")
+	stopped := false
+
+	err := runSubcommand(ctx, []string{"touch", marker}, os.Environ(), func() { stopped = true })
+
+	assert.True(t, stopped, "cancellation is stopped once signals are forwarded")
+	assert.EqualError(t, err, "Interrupted by interrupt before running the command")
+	assert.NoFileExists(t, marker)
+}
@@ -1,23 +1,25 @@
 package summon
 
 import (
+	"context"
 	"fmt"
-	"log/slog"
+	"io"
 	"os"
 	"path/filepath"
-	"slices"
 	"strings"
+	"time"
 
+	// Register the builtin providers
+	_ "github.com/cyberark/summon/pkg/localstore"
 	prov "github.com/cyberark/summon/pkg/provider"
-	"github.com/cyberark/summon/pkg/pushtofile"
-	"github.com/cyberark/summon/pkg/secretsyml"
 )
 
 // SubprocessConfig is an object that holds all the info needed to run
 // a Summon instance
 type SubprocessConfig struct {
 	Args        []string
-	Provider    string
+	Provider    prov.Provider                            // Provider fetching the variables, see provider.New
+	NewProvider func(name string) (prov.Provider, error) // See Options.NewProvider
 	Filepath    string
 	YamlInline  string
 	Subs        []string
@@ -25,16 +27,23 @@ type SubprocessConfig struct {
 	IgnoreAll   bool
 	Environment string
 	RecurseUp   bool
-	FetchSecret secretFetcher
+	EnvPrefix   string
+	EnvCase     string
+	Stats       *Stats // See Options.Stats; also records the files written
 }
 
 const envFileMagic = "@SUMMONENVFILE"
 const summonEnvKeyName = "SUMMON_ENV"
 
-// secretFetcher is function signature for fetching a secret
-type secretFetcher func(string) ([]byte, error)
+// stdinFilepath is the Filepath that reads the configuration from stdin
+const stdinFilepath = "-"
+
+// configStdin is where the configuration is read from when Filepath is "-"
+var configStdin io.Reader = os.Stdin
 
 // RunSubprocess encapsulates the logic of fetching secrets, executing the subprocess with the secrets injected.
+// A SIGINT or SIGTERM received before the subprocess starts stops fetching secrets and writing files, cleans
+// up and returns an *InterruptedError.
 func RunSubprocess(sc *SubprocessConfig) (int, error) {
 	// Prepare substitutions map from command line arguments
 	subs, err := convertSubsToMap(sc.Subs)
@@ -42,70 +51,57 @@ func RunSubprocess(sc *SubprocessConfig) (int, error) {
 		return 0, err
 	}
 
-	// Optional recursive search for secrets file up the directory tree
-	if sc.RecurseUp {
-		currentDir, err := os.Getwd()
-		if err != nil {
-			return 0, err
-		}
-		sc.Filepath, err = findInParentTree(sc.Filepath, currentDir)
-		if err != nil {
-			return 0, err
-		}
+	// Until the subprocess starts, termination signals cancel ctx. From then
+	// on runSubcommand forwards them to the subprocess, and stops the
+	// cancellation itself.
+	ctx, stopSignals := cancelOnSignal(context.Background())
+	defer stopSignals()
+
+	resolved, err := Resolve(ctx, Options{
+		Provider:    sc.Provider,
+		NewProvider: sc.NewProvider,
+		Filepath:    sc.Filepath,
+		YamlInline:  sc.YamlInline,
+		Environment: sc.Environment,
+		Subs:        subs,
+		Ignores:     sc.Ignores,
+		IgnoreAll:   sc.IgnoreAll,
+		RecurseUp:   sc.RecurseUp,
+		EnvPrefix:   sc.EnvPrefix,
+		EnvCase:     sc.EnvCase,
+		Stats:       sc.Stats,
+	})
+	if err != nil {
+		return interruptedOr(ctx, err)
 	}
+	defer resolved.Cleanup()
 
-	// Parse the secrets configuration from a file or inline YAML
-	var config *secretsyml.ParsedConfig
-	if sc.YamlInline == "" {
-		slog.Debug("Loading summon configuration", "filename", sc.Filepath)
-		config, err = secretsyml.ParseFromFile(sc.Filepath, sc.Environment, subs)
-		if err != nil {
-			return 0, fmt.Errorf("Unable to parse configuration from %s: %w", sc.Filepath, err)
-		}
-	} else {
-		slog.Debug("Loading summon configuration from inline YAML")
-		config, err = secretsyml.ParseFromString(sc.YamlInline, sc.Environment, subs)
-		if err != nil {
-			return 0, fmt.Errorf("Unable to parse configuration from inline YAML: %w", err)
-		}
+	// Setup the environment file
+	start := time.Now()
+	envFile, err := setupEnvFile(sc.Args, resolved.Env, resolved.Keys, resolved.tempFactory)
+	if err != nil {
+		return interruptedOr(ctx, fmt.Errorf("Error creating %s: %v", envFileMagic, err))
 	}
-
-	tempFactory := NewTempFactory("")
-	defer tempFactory.Cleanup()
-
-	// Note: This implementation will cause duplicate calls to the provider if
-	// there are secrets needed for both env and files. We can optimize this in
-	// the future by calling the provider once and then splitting the results
-	// based on whether they're needed for env or files. We can do this by
-	// creating a Set of all the secret paths to fetch, calling the provider
-	// once with that set, and then processing the results to populate both env
-	// and files as needed.
-
-	env := []string{}
-	// Fetch secrets needed for environment variables
-	if config.HasEnvSecrets() {
-		envResults, err := fetchSecrets(config.EnvSecrets, sc, &tempFactory)
-		if err != nil {
-			return 0, err
-		}
-		env, err = processResultsAndSetupEnv(envResults, sc, &tempFactory)
-		if err != nil {
-			return 0, err
-		}
+	if envFile != "" {
+		sc.Stats.file(envFile, len(joinEnv(resolved.Env, resolved.Keys)))
 	}
 
-	if config.HasFileSecrets() {
-		fileResults, err := fetchSecrets(config.FileSecrets(), sc, &tempFactory)
+	for _, file := range resolved.Files {
+		filePath, err := file.Write(ctx)
 		if err != nil {
-			return 0, err
-		}
-		err = processResultsAndSetupFiles(fileResults, config.Files, sc, &tempFactory)
-		if err != nil {
-			return 0, err
+			return interruptedOr(ctx, fmt.Errorf("error writing secret file for path %s: %v", file.Config.Path, err))
 		}
+		resolved.tempFactory.AddFile(filePath)
+		sc.Stats.file(filePath, len(file.Content))
+	}
+	if envFile != "" || len(resolved.Files) > 0 {
+		sc.Stats.phase("write", start)
 	}
 
-	err = runSubcommand(sc.Args, append(os.Environ(), env...))
+	err = runSubcommand(ctx, sc.Args, append(os.Environ(), resolved.Environ()...), stopSignals)
+	if interrupted, ok := err.(*InterruptedError); ok {
+		return interrupted.ExitCode(), interrupted
+	}
 	if err != nil {
 		return returnStatusOfError(err)
 	}
@@ -113,6 +109,13 @@ func RunSubprocess(sc *SubprocessConfig) (int, error) {
 	return 0, nil
 }
 
+// isSpecialFile returns true if path exists and is not a regular file, such as
+// the pipe behind a /dev/fd/N path created by process substitution.
+func isSpecialFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.Mode().IsRegular() && !fi.IsDir()
+}
+
 // findInParentTree recursively searches for secretsFile starting at leafDir and in the
 // directories above leafDir until it is found or the root of the file system is reached.
 // If found, returns the absolute path to the file.
@@ -165,51 +168,12 @@ func convertSubsToMap(subs []string) (map[string]string, error) {
 	return out, nil
 }
 
-// processResultsAndSetupEnv processes provider results, populates the environment map,
-// and sets up the environment file. It handles error cases with ignore logic.
-func processResultsAndSetupEnv(results []prov.Result, sc *SubprocessConfig, tempFactory *TempFactory) ([]string, error) {
-	env := make(map[string]string)
-	// Process results from the provider and add them to the environment
-	for _, envvar := range results {
-		if envvar.Error == nil {
-			env[envvar.Key] = envvar.Value
-			continue
-		}
-
-		if sc.IgnoreAll || slices.Contains(sc.Ignores, envvar.Key) {
-			continue
-		}
-
-		slog.Debug("Error fetching secret", "name", envvar.Key, "error", envvar.Error)
-		return nil, fmt.Errorf("Error fetching secret: %w", envvar.Error)
-	}
-
-	// Append environment variable if one is specified
-	if sc.Environment != "" {
-		env[summonEnvKeyName] = sc.Environment
-	}
-
-	// Setup the environment file
-	_, err := setupEnvFile(sc.Args, env, tempFactory)
-	if err != nil {
-		return nil, fmt.Errorf("Error creating %s: %v", envFileMagic, err)
-	}
-
-	// Convert env map to slice of strings in "key=value" format for exec.Command
-	var e []string
-	for k, v := range env {
-		e = append(e, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	return e, nil
-}
-
 // scans arguments for the magic string; if found,
 // creates a tempfile to which all the environment mappings are dumped
 // and replaces the magic string with its path.
 // Returns the path if so, returns an empty string otherwise. Also
 // returns any error encountered during the process.
-func setupEnvFile(args []string, env map[string]string, tempFactory *TempFactory) (string, error) {
+func setupEnvFile(args []string, env map[string]string, order []string, tempFactory *TempFactory) (string, error) {
 	var envFile = ""
 	var err error
 
@@ -220,7 +184,7 @@ func setupEnvFile(args []string, env map[string]string, tempFactory *TempFactory
 		}
 
 		if envFile == "" {
-			envFile, err = tempFactory.Push(joinEnv(env))
+			envFile, err = tempFactory.Push(joinEnv(env, order))
 			if err != nil {
 				return "", err
 			}
@@ -230,33 +194,3 @@ func setupEnvFile(args []string, env map[string]string, tempFactory *TempFactory
 
 	return envFile, nil
 }
-
-func processResultsAndSetupFiles(results []prov.Result, filesConfig []secretsyml.FileConfig, sc *SubprocessConfig, tempFactory *TempFactory) error {
-	for _, file := range filesConfig {
-		if err := file.Validate(); err != nil {
-			return err
-		}
-
-		err := createFile(file, results, sc, tempFactory)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func createFile(fileConfig secretsyml.FileConfig, results []prov.Result, sc *SubprocessConfig, tempFactory *TempFactory) error {
-	secretFile := pushtofile.SecretFile{
-		FileConfig: fileConfig,
-		Ignores:    sc.Ignores,
-		IgnoreAll:  sc.IgnoreAll,
-	}
-
-	filePath, err := secretFile.Write(results)
-	if err != nil {
-		return fmt.Errorf("error writing secret file for path %s: %v", fileConfig.Path, err)
-	}
-	tempFactory.AddFile(filePath)
-	return nil
-}
@@ -1,18 +1,23 @@
 package summon
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	prov "github.com/cyberark/summon/pkg/provider"
 	"github.com/cyberark/summon/pkg/secretsyml"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRunSubprocess(t *testing.T) {
@@ -47,6 +52,119 @@ func TestRunSubprocess(t *testing.T) {
 		}
 	})
 
+	t.Run("Rejects values that violate a constraint", func(t *testing.T) {
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args:       []string{"true"},
+			YamlInline: "FOO: !str:min_len=10 short",
+		})
+
+		assert.EqualError(t, err, "Error fetching secret: invalid value for FOO: value does not satisfy constraint min_len=10")
+		assert.Equal(t, 0, code)
+	})
+
+	t.Run("Ignores failures of optional secrets", func(t *testing.T) {
+		dir := t.TempDir()
+		outFile := filepath.Join(dir, "output.txt")
+
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args:       []string{"bash", "-c", "echo -n \"${FOO-unset}:$BAR\" > " + outFile},
+			YamlInline: "summon.version: 2\nFOO: {path: path/to/foo, optional: true}\nBAR: bar",
+			Provider:   &fakeProvider{},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, code)
+
+		content, err := os.ReadFile(outFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "unset:bar", string(content))
+	})
+
+	t.Run("Fetches secrets from the provider named by an entry", func(t *testing.T) {
+		dir := t.TempDir()
+		outFile := filepath.Join(dir, "output.txt")
+		fixtures := filepath.Join(dir, "fixtures.yml")
+		err := os.WriteFile(fixtures, []byte("secrets: {path/to/bar: other:path/to/bar}\n"), 0o600)
+		assert.NoError(t, err)
+
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args:       []string{"bash", "-c", "echo -n \"$FOO,$BAR\" > " + outFile},
+			YamlInline: "summon.version: 2\nFOO: {path: path/to/foo}\nBAR: {path: path/to/bar, provider: " + prov.MockPrefix + fixtures + "}",
+			Provider:   &fakeProvider{values: map[string]string{"path/to/foo": "default:path/to/foo"}},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, code)
+
+		content, err := os.ReadFile(outFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "default:path/to/foo,other:path/to/bar", string(content))
+	})
+
+	t.Run("Fetches secrets from a mock provider", func(t *testing.T) {
+		dir := t.TempDir()
+		outFile := filepath.Join(dir, "output.txt")
+		fixtures := filepath.Join(dir, "fixtures.yml")
+		err := os.WriteFile(fixtures, []byte("secrets:\n  db/password: s3cr3t\n  db/user: {value: admin, latency: 10ms}\n"), 0o600)
+		assert.NoError(t, err)
+
+		provider, err := prov.New(prov.BuiltinPrefix + "mock:" + fixtures)
+		require.NoError(t, err)
+
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args:       []string{"bash", "-c", "echo -n \"$USER:$PASS\" > " + outFile},
+			YamlInline: "USER: !var db/user\nPASS: !var db/password",
+			Provider:   provider,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, code)
+
+		content, err := os.ReadFile(outFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin:s3cr3t", string(content))
+	})
+
+	t.Run("Records stats without values", func(t *testing.T) {
+		dir := t.TempDir()
+		fixtures := filepath.Join(dir, "fixtures.yml")
+		err := os.WriteFile(fixtures, []byte("secrets:\n  db/password: s3cr3t\n  db/user: admin\n"), 0o600)
+		require.NoError(t, err)
+		provider, err := prov.New(prov.BuiltinPrefix + "mock:" + fixtures)
+		require.NoError(t, err)
+		filePath := filepath.Join(dir, "db.env")
+		stats := &Stats{}
+
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args: []string{"test", "-f", envFileMagic},
+			YamlInline: "USER: !var db/user\nsummon.files:\n  - path: " + filePath +
+				"\n    format: dotenv\n    secrets: {PASSWORD: !var db/password}\n",
+			Provider: provider,
+			Stats:    stats,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0, code)
+
+		var phases []string
+		for _, phase := range stats.Phases {
+			phases = append(phases, phase.Name)
+		}
+		assert.Equal(t, []string{"parse", "fetch", "fetch-files", "render", "write"}, phases)
+		require.Len(t, stats.Fetches, 2)
+		assert.Equal(t, "builtin", stats.Fetches[0].Protocol)
+		assert.Equal(t, "USER", stats.Secrets[0].Key)
+		assert.Equal(t, "PASSWORD", stats.Secrets[1].Key)
+		assert.Zero(t, stats.Processes)
+		require.Len(t, stats.Files, 2)
+		assert.Equal(t, len("USER=admin\n"), stats.Files[0].Bytes, "the @SUMMONENVFILE file")
+		assert.Equal(t, FileStats{Path: filePath, Bytes: len(`PASSWORD="s3cr3t"`)}, stats.Files[1])
+
+		report, err := json.Marshal(stats)
+		require.NoError(t, err)
+		assert.NotContains(t, string(report), "s3cr3t")
+		assert.NotContains(t, string(report), "admin")
+	})
+
 	t.Run("Finds and uses secrets file in a directory above the working directory", func(t *testing.T) {
 		topDir := t.TempDir()
 
@@ -75,115 +193,216 @@ func TestRunSubprocess(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "barvalue", string(content))
 	})
+
+	t.Run("Still looks for the secrets file with inline YAML", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+
+		_, err := RunSubprocess(&SubprocessConfig{
+			Args:       []string{"true"},
+			YamlInline: "FOO: bar\n",
+			RecurseUp:  true,
+			Filepath:   "secrets.yml",
+		})
+
+		assert.ErrorContains(t, err, "unable to locate file specified (secrets.yml)")
+	})
 }
 
-func TestHandleResultsFromProvider(t *testing.T) {
-	t.Run("Returns results when provider returns results", func(t *testing.T) {
-		secretPath := "path/to/secret"
-		expectedValue := "secretvalue"
-		expectedKey := "SERVICE_KEY"
-		resultsCh := make(chan prov.Result)
-		errorsCh := make(chan error, 1)
+func TestRunSubprocess_ConfigFromStdinOrPipe(t *testing.T) {
+	t.Run("Reads the configuration from stdin with -f -", func(t *testing.T) {
+		originalStdin := configStdin
+		defer func() { configStdin = originalStdin }()
+		configStdin = strings.NewReader("FOO: from-stdin\n")
 
-		tempFactory := NewTempFactory("")
-		defer tempFactory.Cleanup()
+		// --up has nothing to search for and must not fail
+		t.Chdir(t.TempDir())
+		outFile := filepath.Join(t.TempDir(), "output.txt")
 
-		filteredSecrets := secretsyml.SecretsMap{
-			expectedKey: secretsyml.SecretSpec{
-				Path:         secretPath,
-				DefaultValue: "",
-				Tags:         []secretsyml.YamlTag{secretsyml.Var},
-			},
-		}
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args:      []string{"bash", "-c", "echo -n \"$FOO\" > " + outFile},
+			Filepath:  "-",
+			RecurseUp: true,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, code)
 
+		content, err := os.ReadFile(outFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "from-stdin", string(content))
+	})
+
+	t.Run("Reports parse errors from stdin", func(t *testing.T) {
+		originalStdin := configStdin
+		defer func() { configStdin = originalStdin }()
+		configStdin = strings.NewReader("- not a mapping\n")
+
+		_, err := RunSubprocess(&SubprocessConfig{
+			Args:     []string{"true"},
+			Filepath: "-",
+		})
+
+		assert.EqualError(t, err, "Unable to parse configuration from stdin: invalid YAML structure: expected mapping")
+	})
+
+	t.Run("Reads the configuration from a pipe, as with process substitution", func(t *testing.T) {
+		dir := t.TempDir()
+		fifo := filepath.Join(dir, "fifo")
+		assert.NoError(t, syscall.Mkfifo(fifo, 0o600))
 		go func() {
-			resultsCh <- prov.Result{Key: expectedKey, Value: expectedValue, Error: nil}
-			close(resultsCh)
+			_ = os.WriteFile(fifo, []byte("FOO: from-pipe\n"), 0o600)
 		}()
 
-		results, err := handleResultsFromProvider(resultsCh, errorsCh, filteredSecrets, &tempFactory)
+		outFile := filepath.Join(dir, "output.txt")
+		code, err := RunSubprocess(&SubprocessConfig{
+			Args:      []string{"bash", "-c", "echo -n \"$FOO\" > " + outFile},
+			Filepath:  fifo,
+			RecurseUp: true,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, code)
 
+		content, err := os.ReadFile(outFile)
 		assert.NoError(t, err)
-		assert.Equal(t, 1, len(results))
-		assert.Equal(t, expectedKey, results[0].Key)
-		assert.Equal(t, expectedValue, results[0].Value)
+		assert.Equal(t, "from-pipe", string(content))
 	})
+}
 
-	t.Run("Returns default value when provider returns empty value", func(t *testing.T) {
-		secretPath := "path/to/secret"
-		expectedValue := "defaultVal"
-		expectedKey := "SERVICE_KEY"
-		resultsCh := make(chan prov.Result)
-		errorsCh := make(chan error, 1)
+func TestRunSubprocess_Interrupted(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		t.Run("Stops fetching and cleans up on "+sig.String(), func(t *testing.T) {
+			// Temp files for !file secrets are created in the overridden devSHM
+			shm := t.TempDir()
+			original := devSHM
+			devSHM = shm
+			t.Cleanup(func() { devSHM = original })
+
+			// The provider signals summon, then hangs until it is killed
+			dir := t.TempDir()
+			provider := filepath.Join(dir, "provider")
+			script := fmt.Sprintf("#!/bin/sh\nkill -%d $PPID\nexec sleep 30\n", sig)
+			assert.NoError(t, os.WriteFile(provider, []byte(script), 0o755))
+			outFile := filepath.Join(dir, "output.txt")
+
+			start := time.Now()
+			code, err := RunSubprocess(&SubprocessConfig{
+				Args:       []string{"bash", "-c", "touch " + outFile},
+				YamlInline: "CERT: !str:file literal-cert\nFOO: !var path/to/foo",
+				Provider:   prov.NewExec(provider),
+			})
 
-		tempFactory := NewTempFactory("")
-		defer tempFactory.Cleanup()
+			assert.EqualError(t, err, "Interrupted by "+sig.String()+" before running the command")
+			assert.Equal(t, 128+int(sig), code)
+			assert.Less(t, time.Since(start), 10*time.Second)
+			assert.NoFileExists(t, outFile)
 
-		filteredSecrets := secretsyml.SecretsMap{
-			expectedKey: secretsyml.SecretSpec{
-				Path:         secretPath,
-				DefaultValue: expectedValue,
-				Tags:         []secretsyml.YamlTag{secretsyml.Var},
-			},
+			leftovers, err := os.ReadDir(shm)
+			assert.NoError(t, err)
+			assert.Empty(t, leftovers)
+		})
+	}
+}
+
+// fakeProvider is an in-process Provider answering requests from a map of
+// paths to values.
+type fakeProvider struct {
+	values   map[string]string
+	err      error
+	requests []prov.Request
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Fetch(_ context.Context, requests []prov.Request) ([]prov.Result, error) {
+	p.requests = append(p.requests, requests...)
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	results := make([]prov.Result, 0, len(requests))
+	for _, request := range requests {
+		value, ok := p.values[request.Path]
+		if !ok {
+			results = append(results, prov.Result{Key: request.Key, Error: fmt.Errorf("no such secret: %s", request.Path)})
+			continue
 		}
+		results = append(results, prov.Result{Key: request.Key, Value: value})
+	}
+	return results, nil
+}
 
-		go func() {
-			resultsCh <- prov.Result{Key: expectedKey, Value: "", Error: nil}
-			close(resultsCh)
-		}()
+func TestFetchSecretsFromProvider(t *testing.T) {
+	t.Run("Returns results when provider returns results", func(t *testing.T) {
+		tempFactory := NewTempFactory("")
+		defer tempFactory.Cleanup()
+
+		config, err := secretsyml.ParseFromString("SERVICE_KEY: !var path/to/secret\nLITERAL: value", "", nil)
+		assert.NoError(t, err)
+		provider := &fakeProvider{values: map[string]string{"path/to/secret": "secretvalue"}}
 
-		results, err := handleResultsFromProvider(resultsCh, errorsCh, filteredSecrets, &tempFactory)
+		results, err := fetchSecretsFromProvider(context.Background(), config.EnvSecrets, provider, &tempFactory)
 
 		assert.NoError(t, err)
-		assert.Equal(t, 1, len(results))
-		assert.Equal(t, expectedKey, results[0].Key)
-		assert.Equal(t, expectedValue, results[0].Value)
+		assert.ElementsMatch(t, []prov.Result{
+			{Key: "SERVICE_KEY", Value: "secretvalue"},
+			{Key: "LITERAL", Value: "value"},
+		}, results)
+		assert.Equal(t, []prov.Request{{Key: "SERVICE_KEY", Path: "path/to/secret"}}, provider.requests)
 	})
 
-	t.Run("Returns error when provider cannot handle interactive mode", func(t *testing.T) {
-		resultsCh := make(chan prov.Result, 1)
-		errorsCh := make(chan error, 1)
-
+	t.Run("Returns default value when provider returns empty value", func(t *testing.T) {
 		tempFactory := NewTempFactory("")
 		defer tempFactory.Cleanup()
 
-		filteredSecrets := secretsyml.SecretsMap{
+		secrets := secretsyml.SecretsMap{
 			"SERVICE_KEY": secretsyml.SecretSpec{
 				Path:         "path/to/secret",
-				DefaultValue: "",
-				Tags:         []secretsyml.YamlTag{secretsyml.Var}},
+				DefaultValue: "defaultVal",
+				Tags:         []secretsyml.YamlTag{secretsyml.Var},
+			},
 		}
+		provider := &fakeProvider{values: map[string]string{"path/to/secret": ""}}
 
-		errorsCh <- prov.ErrInteractiveModeNotSupported
+		results, err := fetchSecretsFromProvider(context.Background(), secrets, provider, &tempFactory)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []prov.Result{{Key: "SERVICE_KEY", Value: "defaultVal"}}, results)
+	})
+
+	t.Run("Returns redacted error result when value violates a constraint", func(t *testing.T) {
+		tempFactory := NewTempFactory("")
+		defer tempFactory.Cleanup()
+
+		config, err := secretsyml.ParseFromString("PORT: !var:type=int path/to/port", "", nil)
+		assert.NoError(t, err)
+		provider := &fakeProvider{values: map[string]string{"path/to/port": "not-a-number"}}
 
-		results, err := handleResultsFromProvider(resultsCh, errorsCh, filteredSecrets, &tempFactory)
+		results, err := fetchSecretsFromProvider(context.Background(), config.EnvSecrets, provider, &tempFactory)
 
-		assert.Error(t, err)
-		assert.Equal(t, prov.ErrInteractiveModeNotSupported, err)
-		assert.Nil(t, results)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, "PORT", results[0].Key)
+		assert.Empty(t, results[0].Value)
+		assert.EqualError(t, results[0].Error, "invalid value for PORT: value does not satisfy constraint type=int")
 	})
 
 	t.Run("Returns error result when formatForEnv fails", func(t *testing.T) {
-		resultsCh := make(chan prov.Result, 1)
-		errorsCh := make(chan error, 1)
-
 		// Use an invalid path so tempFactory.Push() fails
 		tempFactory := NewTempFactory("/nonexistent/dir")
 		defer tempFactory.Cleanup()
 
-		filteredSecrets := secretsyml.SecretsMap{
+		secrets := secretsyml.SecretsMap{
 			"FILE_KEY": secretsyml.SecretSpec{
-				Path: "some-value",
+				Path: "path/to/secret",
 				Tags: []secretsyml.YamlTag{secretsyml.Var, secretsyml.File},
 			},
 		}
+		provider := &fakeProvider{values: map[string]string{"path/to/secret": "content"}}
 
-		go func() {
-			resultsCh <- prov.Result{Key: "FILE_KEY", Value: "content", Error: nil}
-			close(resultsCh)
-		}()
-
-		results, err := handleResultsFromProvider(resultsCh, errorsCh, filteredSecrets, &tempFactory)
+		results, err := fetchSecretsFromProvider(context.Background(), secrets, provider, &tempFactory)
 
 		assert.NoError(t, err)
 		assert.Len(t, results, 1)
@@ -191,6 +410,48 @@ func TestHandleResultsFromProvider(t *testing.T) {
 		assert.Empty(t, results[0].Value)
 		assert.ErrorContains(t, results[0].Error, "/nonexistent/dir")
 	})
+
+	t.Run("Returns error results for failed and missing secrets", func(t *testing.T) {
+		tempFactory := NewTempFactory("")
+		defer tempFactory.Cleanup()
+
+		secrets := secretsyml.SecretsMap{
+			"MISSING": secretsyml.SecretSpec{Path: "path/to/missing", Tags: []secretsyml.YamlTag{secretsyml.Var}},
+		}
+		provider := &fakeProvider{}
+
+		results, err := fetchSecretsFromProvider(context.Background(), secrets, provider, &tempFactory)
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.EqualError(t, results[0].Error, "no such secret: path/to/missing")
+	})
+
+	t.Run("Returns error when the provider can't be used", func(t *testing.T) {
+		tempFactory := NewTempFactory("")
+		defer tempFactory.Cleanup()
+
+		secrets := secretsyml.SecretsMap{
+			"KEY": secretsyml.SecretSpec{Path: "path/to/secret", Tags: []secretsyml.YamlTag{secretsyml.Var}},
+		}
+		provider := &fakeProvider{err: errors.New("backend unreachable")}
+
+		_, err := fetchSecretsFromProvider(context.Background(), secrets, provider, &tempFactory)
+
+		assert.EqualError(t, err, "Unable to fetch secrets from provider fake: backend unreachable")
+	})
+
+	t.Run("Doesn't call the provider without variables", func(t *testing.T) {
+		tempFactory := NewTempFactory("")
+		defer tempFactory.Cleanup()
+
+		secrets := secretsyml.SecretsMap{"LITERAL": secretsyml.SecretSpec{Path: "value"}}
+
+		results, err := fetchSecretsFromProvider(context.Background(), secrets, nil, &tempFactory)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []prov.Result{{Key: "LITERAL", Value: "value"}}, results)
+	})
 }
 
 func TestFilterNonVariables(t *testing.T) {
@@ -330,29 +591,29 @@ func TestFormatForEnvString(t *testing.T) {
 
 func TestJoinEnv(t *testing.T) {
 	t.Run("adds a trailing newline", func(t *testing.T) {
-		result := joinEnv(map[string]string{"foo": "bar", "baz": "qux"})
+		result := joinEnv(map[string]string{"foo": "bar", "baz": "qux"}, nil)
 		assert.Equal(t, "baz=qux\nfoo=bar\n", result)
 	})
 
 	t.Run("quotes values with spaces", func(t *testing.T) {
-		result := joinEnv(map[string]string{"key": "value with spaces"})
+		result := joinEnv(map[string]string{"key": "value with spaces"}, nil)
 		assert.Equal(t, "key=\"value with spaces\"\n", result)
 	})
 
 	t.Run("quotes and escapes multi-line values", func(t *testing.T) {
 		multiLineValue := "-----BEGIN KEY-----\nCERT_DATA...\n-----END KEY-----"
-		result := joinEnv(map[string]string{"CERT": multiLineValue})
+		result := joinEnv(map[string]string{"CERT": multiLineValue}, nil)
 		expected := "CERT=\"-----BEGIN KEY-----\\nCERT_DATA...\\n-----END KEY-----\"\n"
 		assert.Equal(t, expected, result)
 	})
 
 	t.Run("escapes quotes in values", func(t *testing.T) {
-		result := joinEnv(map[string]string{"key": "value with \"quotes\""})
+		result := joinEnv(map[string]string{"key": "value with \"quotes\""}, nil)
 		assert.Equal(t, "key=\"value with \\\"quotes\\\"\"\n", result)
 	})
 
 	t.Run("escapes backslashes in values", func(t *testing.T) {
-		result := joinEnv(map[string]string{"key": "value\\with\\backslashes"})
+		result := joinEnv(map[string]string{"key": "value\\with\\backslashes"}, nil)
 		assert.Equal(t, "key=\"value\\\\with\\\\backslashes\"\n", result)
 	})
 
@@ -360,10 +621,20 @@ func TestJoinEnv(t *testing.T) {
 		result := joinEnv(map[string]string{
 			"SIMPLE":  "value",
 			"COMPLEX": "value with spaces",
-		})
+		}, nil)
 		assert.Contains(t, result, "SIMPLE=value\n")
 		assert.Contains(t, result, "COMPLEX=\"value with spaces\"\n")
 	})
+
+	t.Run("follows the declared order, then sorts remaining keys", func(t *testing.T) {
+		result := joinEnv(map[string]string{
+			"SUMMON_ENV": "dev",
+			"ZULU":       "z",
+			"ALPHA":      "a",
+			"MIKE":       "m",
+		}, []string{"ZULU", "MIKE", "ALPHA", "UNKNOWN"})
+		assert.Equal(t, "ZULU=z\nMIKE=m\nALPHA=a\nSUMMON_ENV=dev\n", result)
+	})
 }
 
 func TestLocateFileRecurseUp(t *testing.T) {
@@ -454,70 +725,3 @@ func TestReturnStatusOfError(t *testing.T) {
 		assert.Equal(t, expected, err)
 	})
 }
-
-func TestNonInteractiveProviderFallback(t *testing.T) {
-	tests := []struct {
-		name        string
-		secrets     secretsyml.SecretsMap
-		fetchSecret func(string) ([]byte, error)
-		tempPath    string
-		assertFunc  func(t *testing.T, results []prov.Result)
-	}{
-		{
-			name: "returns results for all secrets",
-			secrets: secretsyml.SecretsMap{
-				"key1": secretsyml.SecretSpec{Path: "path1"},
-				"key2": secretsyml.SecretSpec{Path: "path2"},
-			},
-			fetchSecret: func(path string) ([]byte, error) { return []byte(path), nil },
-			assertFunc: func(t *testing.T, results []prov.Result) {
-				assert.Len(t, results, 2)
-				for _, r := range results {
-					assert.NoError(t, r.Error)
-					// The stub returns the path as the value, so value should equal the spec's path
-					assert.NotEmpty(t, r.Value)
-				}
-			},
-		},
-		{
-			name: "returns error when fetch fails",
-			secrets: secretsyml.SecretsMap{
-				"FAILING_KEY": {Path: "path/to/secret", Tags: []secretsyml.YamlTag{secretsyml.Var}},
-			},
-			fetchSecret: func(path string) ([]byte, error) {
-				return nil, fmt.Errorf("provider error for %s", path)
-			},
-			assertFunc: func(t *testing.T, results []prov.Result) {
-				assert.Len(t, results, 1)
-				assert.Equal(t, "FAILING_KEY", results[0].Key)
-				assert.Empty(t, results[0].Value)
-				assert.ErrorContains(t, results[0].Error, "provider error for path/to/secret")
-			},
-		},
-		{
-			name: "returns error when formatForEnv fails",
-			secrets: secretsyml.SecretsMap{
-				"FILE_KEY": {Path: "path/to/secret", Tags: []secretsyml.YamlTag{secretsyml.Var, secretsyml.File}},
-			},
-			fetchSecret: func(path string) ([]byte, error) { return []byte("secret-content"), nil },
-			tempPath:    "/nonexistent/dir",
-			assertFunc: func(t *testing.T, results []prov.Result) {
-				assert.Len(t, results, 1)
-				assert.Equal(t, "FILE_KEY", results[0].Key)
-				assert.Empty(t, results[0].Value)
-				assert.ErrorContains(t, results[0].Error, "/nonexistent/dir")
-			},
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			sc := &SubprocessConfig{FetchSecret: tc.fetchSecret}
-			tempFactory := NewTempFactory(tc.tempPath)
-			defer tempFactory.Cleanup()
-
-			results := nonInteractiveProviderFallback(tc.secrets, sc, &tempFactory)
-			tc.assertFunc(t, results)
-		})
-	}
-}
@@ -26,12 +26,22 @@ func NewTempFactory(path string) TempFactory {
 	return TempFactory{path: path}
 }
 
-// defaultTempPath returns the best possible temp folder path for temp files
-func defaultTempPath() string {
+// SharedMemoryDir returns the shared-memory directory temporary files are
+// created in by default, which keeps secrets off disk, or "" when there is
+// none and they are created in a directory of the home directory instead.
+func SharedMemoryDir() string {
 	fi, err := os.Stat(devSHM)
 	if err == nil && fi.Mode().IsDir() {
 		return devSHM
 	}
+	return ""
+}
+
+// defaultTempPath returns the best possible temp folder path for temp files
+func defaultTempPath() string {
+	if dir := SharedMemoryDir(); dir != "" {
+		return dir
+	}
 	home, err := os.UserHomeDir()
 	if err == nil {
 		dir, err := os.MkdirTemp(home, ".tmp")